@@ -52,7 +52,7 @@ type Call struct {
 	Pos lexer.Position
 
 	Name string        `@Ident`
-	Args []*Expression `"(" ( @@ ( "," @@ )* )? ")"`
+	Args []*Expression `"(" @@*%"," ")"`
 }
 
 type Print struct {
@@ -11,6 +11,7 @@ import (
 	"github.com/alecthomas/kong"
 
 	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
 )
 
 var cli struct {
@@ -30,8 +31,12 @@ const (
 
 var operatorMap = map[string]Operator{"+": OpAdd, "-": OpSub, "*": OpMul, "/": OpDiv}
 
-func (o *Operator) Capture(s []string) error {
-	*o = operatorMap[s[0]]
+func (o *Operator) CaptureTokens(tokens []lexer.Token) error {
+	op, ok := operatorMap[tokens[0].Value]
+	if !ok {
+		return participle.Errorf(tokens[0].Pos, "unknown operator %q", tokens[0].Value)
+	}
+	*o = op
 	return nil
 }
 
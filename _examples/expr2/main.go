@@ -27,13 +27,13 @@ type Expression struct {
 
 type Equality struct {
 	Comparison *Comparison `@@`
-	Op         string      `( @( "!" "=" | "=" "=" )`
+	Op         string      `( @( "!=" | "==" )`
 	Next       *Equality   `  @@ )*`
 }
 
 type Comparison struct {
 	Addition *Addition   `@@`
-	Op       string      `( @( ">" | ">" "=" | "<" | "<" "=" )`
+	Op       string      `( @( ">=" | ">" | "<=" | "<" )`
 	Next     *Comparison `  @@ )*`
 }
 
@@ -70,7 +70,11 @@ func (b *Boolean) Capture(values []string) error {
 	return nil
 }
 
-var parser = participle.MustBuild[Expression](participle.UseLookahead(2))
+var parser = participle.MustBuild[Expression](
+	participle.UseLookahead(2),
+	// The lexer produces "!=" as "!" followed by "=", which must not be separated.
+	participle.AdjacentLiterals("!=", "==", ">=", "<="),
+)
 
 func main() {
 	var cli struct {
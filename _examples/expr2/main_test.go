@@ -13,6 +13,16 @@ func TestExe(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestExe_AdjacentOperators(t *testing.T) {
+	got, err := parser.ParseString("", `1 >= 2 != true`)
+	require.NoError(t, err)
+	require.Equal(t, "!=", got.Equality.Op)
+	require.Equal(t, ">=", got.Equality.Comparison.Op)
+
+	_, err = parser.ParseString("", `1 ! = 2`)
+	require.Error(t, err)
+}
+
 func toPtr[T any](x T) *T {
 	return &x
 }
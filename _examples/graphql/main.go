@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/alecthomas/kong"
 	"github.com/alecthomas/repr"
@@ -39,7 +38,7 @@ type Type struct {
 
 type Field struct {
 	Name       string      `@Ident`
-	Arguments  []*Argument `( "(" ( @@ ( "," @@ )* )? ")" )?`
+	Arguments  []*Argument `( "(" @@*%"," ")" )?`
 	Type       *TypeRef    `":" @@`
 	Annotation string      `( "@" @Ident )?`
 }
@@ -87,10 +86,7 @@ func main() {
 		ctx.Exit(0)
 	}
 	for _, file := range cli.Files {
-		r, err := os.Open(file)
-		ctx.FatalIfErrorf(err)
-		ast, err := parser.Parse("", r)
-		r.Close()
+		ast, err := parser.ParseFile(file)
 		repr.Println(ast)
 		ctx.FatalIfErrorf(err)
 	}
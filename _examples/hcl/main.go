@@ -20,7 +20,7 @@ type Value struct {
 	Identifier *string  `| @Ident ( @"." @Ident )*`
 	String     *string  `| @(String|Char|RawString)`
 	Number     *float64 `| @(Float|Int)`
-	Array      []*Value `| "[" ( @@ ","? )* "]"`
+	Array      []*Value `| "[" @@*%","? "]"`
 }
 
 func (l *Value) GoString() string {
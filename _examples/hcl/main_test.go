@@ -38,3 +38,12 @@ directory data {
 	repr.Println(ast)
 	require.NoError(t, err)
 }
+
+func TestArrayTrailingComma(t *testing.T) {
+	ast, err := parser.ParseString("", `exclude = ["*.hcl", "*.tmp",]`)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(ast.Entries[0].Value.Array))
+
+	_, err = parser.ParseString("", `exclude = ["*.hcl" "*.tmp"]`)
+	require.Error(t, err)
+}
@@ -28,6 +28,8 @@ var (
 		participle.Unquote("String"),
 		participle.Elide("Whitespace", "EOL"),
 		participle.UseLookahead(2),
+		// Guard against deeply nested input exhausting the stack.
+		participle.MaxDepth(1000),
 	)
 
 	cli struct {
@@ -59,7 +61,7 @@ type Json struct {
 type Object struct {
 	Pos lexer.Position
 
-	Pairs []*Pair `parser:"'{' @@ (',' @@)* '}'"`
+	Pairs []*Pair `parser:"'{' @@*%',' '}'"`
 }
 
 type Pair struct {
@@ -72,7 +74,7 @@ type Pair struct {
 type Array struct {
 	Pos lexer.Position
 
-	Items []*Json `parser:"'[' @@ (',' @@)* ']'"`
+	Items []*Json `parser:"'[' @@*%',' ']'"`
 }
 
 func main() {
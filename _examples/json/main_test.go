@@ -13,3 +13,15 @@ func TestParse(t *testing.T) {
 	_, err = Parse(src)
 	require.NoError(t, err)
 }
+
+func TestEmptyAndTrailingComma(t *testing.T) {
+	ast, err := Parse([]byte(`{"a": [], "b": {}}`))
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ast.Object.Pairs[0].Value.Array.Items))
+
+	// JSON does not allow trailing commas.
+	_, err = Parse([]byte(`[1, 2,]`))
+	require.Error(t, err)
+	_, err = Parse([]byte(`{"a": 1,}`))
+	require.Error(t, err)
+}
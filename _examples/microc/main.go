@@ -130,7 +130,7 @@ type FunDec struct {
 
 	ReturnType string       `@(Type | "void")`
 	Name       string       `@Ident`
-	Parameters []*Parameter `"(" ((@@ ("," @@)*) | "void") ")"`
+	Parameters []*Parameter `"(" (@@+%"," | "void") ")"`
 	FunBody    *FunBody     `(";" | "{" @@ "}")`
 }
 
@@ -223,7 +223,7 @@ type CallFunc struct {
 	Pos lexer.Position
 
 	Ident string  `@Ident`
-	Index []*Expr `"(" (@@ ("," @@)*)? ")"`
+	Index []*Expr `"(" @@*%"," ")"`
 }
 
 var (
@@ -3,7 +3,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/alecthomas/kong"
 	"github.com/alecthomas/repr"
@@ -73,13 +72,13 @@ type MapEntry struct {
 type Extensions struct {
 	Pos lexer.Position
 
-	Extensions []Range `"extensions" @@ ( "," @@ )*`
+	Extensions []Range `"extensions" @@+%","`
 }
 
 type Reserved struct {
 	Pos lexer.Position
 
-	Reserved []Range `"reserved" @@ ( "," @@ )*`
+	Reserved []Range `"reserved" @@+%","`
 }
 
 type Range struct {
@@ -141,7 +140,7 @@ type EnumValue struct {
 	Key   string `@Ident`
 	Value int    `"=" @( [ "-" ] Int )`
 
-	Options []*Option `( "[" @@ ( "," @@ )* "]" )?`
+	Options []*Option `( "[" @@+%"," "]" )?`
 }
 
 type Message struct {
@@ -189,7 +188,7 @@ type Field struct {
 	Name string `@Ident`
 	Tag  int    `"=" @Int`
 
-	Options []*Option `( "[" @@ ( "," @@ )* "]" )?`
+	Options []*Option `( "[" @@+%"," "]" )?`
 }
 
 type Scalar int
@@ -266,9 +265,7 @@ func main() {
 
 	for _, file := range cli.Files {
 		fmt.Println(file)
-		r, err := os.Open(file)
-		ctx.FatalIfErrorf(err, "")
-		proto, err := parser.Parse("", r)
+		proto, err := parser.ParseFile(file)
 		ctx.FatalIfErrorf(err, "")
 		repr.Println(proto, repr.Hide[lexer.Position]())
 	}
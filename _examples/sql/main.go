@@ -2,6 +2,8 @@
 package main
 
 import (
+	"strings"
+
 	"github.com/alecthomas/kong"
 
 	"github.com/alecthomas/repr"
@@ -21,7 +23,7 @@ func (b *Boolean) Capture(values []string) error {
 type Select struct {
 	Top        *Term             `"SELECT" ( "TOP" @@ )?`
 	Distinct   bool              `(  @"DISTINCT"`
-	All        bool              ` | @"ALL" )?`
+	All        bool              ` | @"ALL" )!:unique`
 	Expression *SelectExpression `@@`
 	From       *From             `"FROM" @@`
 	Limit      *Expression       `( "LIMIT" @@ )?`
@@ -30,20 +32,20 @@ type Select struct {
 }
 
 type From struct {
-	TableExpressions []*TableExpression `@@ ( "," @@ )*`
+	TableExpressions []*TableExpression `@@+%","`
 	Where            *Expression        `( "WHERE" @@ )?`
 }
 
 type TableExpression struct {
 	Table  string        `( @Ident ( "." @Ident )*`
 	Select *Select       `  | "(" @@ ")"`
-	Values []*Expression `  | "VALUES" "(" @@ ( "," @@ )* ")")`
+	Values []*Expression `  | "VALUES" "(" @@+%"," ")")`
 	As     string        `( "AS" @Ident )?`
 }
 
 type SelectExpression struct {
 	All         bool                 `  @"*"`
-	Expressions []*AliasedExpression `| @@ ( "," @@ )*`
+	Expressions []*AliasedExpression `| @@+%","`
 }
 
 type AliasedExpression struct {
@@ -109,7 +111,7 @@ type Between struct {
 
 type In struct {
 	Select      *Select       `  @@`
-	Expressions []*Expression `| @@ ( "," @@ )*`
+	Expressions []*Expression `| @@+%","`
 }
 
 type Operand struct {
@@ -137,7 +139,7 @@ type Term struct {
 
 type SymbolRef struct {
 	Symbol     string        `@Ident @( "." Ident )*`
-	Parameters []*Expression `( "(" @@ ( "," @@ )* ")" )?`
+	Parameters []*Expression `( "(" @@+%"," ")" )?`
 }
 
 type Value struct {
@@ -150,7 +152,7 @@ type Value struct {
 }
 
 type Array struct {
-	Expressions []*Expression `"(" @@ ( "," @@ )* ")"`
+	Expressions []*Expression `"(" @@+%"," ")"`
 }
 
 var (
@@ -162,20 +164,26 @@ var (
 		{`Keyword`, `(?i)\b(SELECT|FROM|TOP|DISTINCT|ALL|WHERE|GROUP|BY|HAVING|UNION|MINUS|EXCEPT|INTERSECT|ORDER|LIMIT|OFFSET|TRUE|FALSE|NULL|IS|NOT|ANY|SOME|BETWEEN|AND|OR|LIKE|AS|IN)\b`},
 		{`Ident`, `[a-zA-Z_][a-zA-Z0-9_]*`},
 		{`Number`, `[-+]?\d*\.?\d+([eE][-+]?\d+)?`},
-		{`String`, `'[^']*'|"[^"]*"`},
+		{`String`, `'(?:[^']|'')*'|"(?:[^"]|"")*"`},
 		{`Operators`, `<>|!=|<=|>=|[-+*/%,.()=<>]`},
 		{"whitespace", `\s+`},
 	})
 	parser = participle.MustBuild[Select](
 		participle.Lexer(sqlLexer),
-		participle.Unquote("String"),
-		participle.CaseInsensitive("Keyword"),
+		participle.UnquoteWith("String", unquoteSQL),
+		participle.CaseInsensitiveFold("Keyword", strings.ToUpper),
 		// participle.Elide("Comment"),
 		// Need to solve left recursion detection first, if possible.
 		// participle.UseLookahead(),
 	)
 )
 
+// Unquote SQL strings, in which quotes are escaped by doubling them.
+func unquoteSQL(s string) (string, error) {
+	quote := s[:1]
+	return strings.ReplaceAll(s[1:len(s)-1], quote+quote, quote), nil
+}
+
 func main() {
 	ctx := kong.Parse(&cli)
 	sql, err := parser.ParseString("", cli.SQL)
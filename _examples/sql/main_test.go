@@ -12,3 +12,17 @@ func TestExe(t *testing.T) {
 	require.NoError(t, err)
 	repr.Println(sel)
 }
+
+func TestMixedCaseKeywords(t *testing.T) {
+	sel, err := parser.ParseString("", `select * From table wHeRe attr = 10`)
+	require.NoError(t, err)
+	expected, err := parser.ParseString("", `SELECT * FROM table WHERE attr = 10`)
+	require.NoError(t, err)
+	require.Equal(t, repr.String(expected), repr.String(sel))
+}
+
+func TestQuotedString(t *testing.T) {
+	sel, err := parser.ParseString("", `SELECT * FROM table WHERE name = 'O''Brien'`)
+	require.NoError(t, err)
+	require.Contains(t, repr.String(sel), `"O'Brien"`)
+}
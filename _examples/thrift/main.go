@@ -17,6 +17,7 @@ import (
 
 	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/alecthomas/participle/v2/lexer/codegen"
 )
 
 type Namespace struct {
@@ -45,14 +46,14 @@ type Field struct {
 	Type        *Type         `@@`
 	Name        string        `@Ident`
 	Default     *Literal      `( "=" @@ )?`
-	Annotations []*Annotation `( "(" @@ ( "," @@ )* ")" )? ";"?`
+	Annotations []*Annotation `( "(" @@+%"," ")" )? ";"?`
 }
 
 type Exception struct {
 	Pos         lexer.Position
 	Name        string        `"exception" @Ident "{"`
 	Fields      []*Field      `@@ @@* "}"`
-	Annotations []*Annotation `( "(" @@ ( "," @@ )* ")" )?`
+	Annotations []*Annotation `( "(" @@+%"," ")" )?`
 }
 
 type Struct struct {
@@ -60,7 +61,7 @@ type Struct struct {
 	Union       bool          `( "struct" | @"union" )`
 	Name        string        `@Ident "{"`
 	Fields      []*Field      `@@* "}"`
-	Annotations []*Annotation `( "(" @@ ( "," @@ )* ")" )?`
+	Annotations []*Annotation `( "(" @@+%"," ")" )?`
 }
 
 type Argument struct {
@@ -81,9 +82,9 @@ type Method struct {
 	Pos         lexer.Position
 	ReturnType  *Type         `@@`
 	Name        string        `@Ident`
-	Arguments   []*Argument   `"(" ( @@ ( "," @@ )* )? ")"`
-	Throws      []*Throw      `( "throws" "(" @@ ( "," @@ )* ")" )?`
-	Annotations []*Annotation `( "(" @@ ( "," @@ )* ")" )?`
+	Arguments   []*Argument   `"(" @@*%"," ")"`
+	Throws      []*Throw      `( "throws" "(" @@+%"," ")" )?`
+	Annotations []*Annotation `( "(" @@+%"," ")" )?`
 }
 
 type Service struct {
@@ -91,7 +92,7 @@ type Service struct {
 	Name        string        `"service" @Ident`
 	Extends     string        `( "extends" @Ident ( @"." @Ident )* )?`
 	Methods     []*Method     `"{" ( @@ ";"? )* "}"`
-	Annotations []*Annotation `( "(" @@ ( "," @@ )* ")" )?`
+	Annotations []*Annotation `( "(" @@+%"," ")" )?`
 }
 
 // Literal is a "union" type, where only one matching value will be present.
@@ -147,7 +148,7 @@ func (m *MapItem) GoString() string {
 type Case struct {
 	Pos         lexer.Position
 	Name        string        `@Ident`
-	Annotations []*Annotation `( "(" @@ ( "," @@ )* ")" )?`
+	Annotations []*Annotation `( "(" @@+%"," ")" )?`
 	Value       *Literal      `( "=" @@ )? ( "," | ";" )?`
 }
 
@@ -155,7 +156,7 @@ type Enum struct {
 	Pos         lexer.Position
 	Name        string        `"enum" @Ident "{"`
 	Cases       []*Case       `@@* "}"`
-	Annotations []*Annotation `( "(" @@ ( "," @@ )* ")" )?`
+	Annotations []*Annotation `( "(" @@+%"," ")" )?`
 }
 
 type Typedef struct {
@@ -192,14 +193,15 @@ type Thrift struct {
 }
 
 var (
-	def = lexer.MustSimple([]lexer.SimpleRule{
+	rules = []lexer.SimpleRule{
 		{"Number", `\d+`},
 		{"Ident", `\w+`},
 		{"String", `"[^"]*"`},
 		{"Whitespace", `\s+`},
 		{"Punct", `[,.<>(){}=:]`},
 		{"Comment", `//.*`},
-	})
+	}
+	def    = lexer.MustSimple(rules)
 	parser = participle.MustBuild[Thrift](
 		participle.Lexer(def),
 		participle.Unquote(),
@@ -215,10 +217,14 @@ func main() {
 
 	ctx := kong.Parse(&cli)
 
+	if cli.Gen {
+		err := codegen.GenerateSimpleLexer(os.Stdout, "main", "Thrift", rules)
+		ctx.FatalIfErrorf(err)
+		return
+	}
+
 	for _, file := range cli.Files {
-		r, err := os.Open(file)
-		ctx.FatalIfErrorf(err, "")
-		thrift, err := parser.Parse("", r)
+		thrift, err := parser.ParseFile(file)
 		ctx.FatalIfErrorf(err, "")
 		repr.Println(thrift)
 	}
@@ -1,7 +1,9 @@
 package main
 
 import (
-	"os"
+	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/alecthomas/kong"
 
@@ -28,13 +30,34 @@ type Field struct {
 }
 
 type Value struct {
-	String   *string  `  @String`
-	DateTime *string  `| @DateTime`
-	Date     *string  `| @Date`
-	Time     *string  `| @Time`
-	Bool     *bool    `| (@"true" | "false")`
-	Number   *float64 `| @Number`
-	List     []*Value `| "[" ( @@ ( "," @@ )* )? "]"`
+	String   *string    `  @String`
+	DateTime *Timestamp `| @DateTime`
+	Date     *Timestamp `| @Date`
+	Time     *Timestamp `| @Time`
+	Bool     *bool      `| (@"true" | "false")`
+	Number   *float64   `| @Number`
+	List     []*Value   `| "[" @@*%","? "]"`
+}
+
+// Timestamp is a TOML date-time, local date-time, local date or local time.
+type Timestamp struct {
+	time.Time
+}
+
+var timestampLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+	"15:04:05.999999999",
+}
+
+func (t *Timestamp) UnmarshalText(text []byte) (err error) {
+	for _, layout := range timestampLayouts {
+		if t.Time, err = time.Parse(layout, string(text)); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid date or time %q", text)
 }
 
 type Section struct {
@@ -48,7 +71,7 @@ var (
 		{"Date", `\d\d\d\d-\d\d-\d\d`},
 		{"Time", `\d\d:\d\d:\d\d(\.\d+)?`},
 		{"Ident", `[a-zA-Z_][a-zA-Z_0-9]*`},
-		{"String", `"[^"]*"`},
+		{"String", `"(?:[^"\\]|\\.)*"|'[^']*'`},
 		{"Number", `[-+]?[.0-9]+\b`},
 		{"Punct", `\[|]|[-!()+/*=,]`},
 		{"comment", `#[^\n]+`},
@@ -56,7 +79,7 @@ var (
 	})
 	tomlParser = participle.MustBuild[TOML](
 		participle.Lexer(tomlLexer),
-		participle.Unquote("String"),
+		participle.UnquoteWith("String", unquoteTOML),
 	)
 
 	cli struct {
@@ -64,12 +87,17 @@ var (
 	}
 )
 
+// Unquote TOML basic strings, which have escapes, and literal strings, which do not.
+func unquoteTOML(s string) (string, error) {
+	if s[0] == '\'' {
+		return s[1 : len(s)-1], nil
+	}
+	return strconv.Unquote(s)
+}
+
 func main() {
 	ctx := kong.Parse(&cli)
-	r, err := os.Open(cli.File)
-	ctx.FatalIfErrorf(err)
-	defer r.Close()
-	toml, err := tomlParser.Parse(cli.File, r)
+	toml, err := tomlParser.ParseFile(cli.File)
 	ctx.FatalIfErrorf(err)
 	repr.Println(toml)
 }
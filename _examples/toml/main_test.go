@@ -2,6 +2,7 @@ package main
 
 import (
 	"testing"
+	"time"
 
 	require "github.com/alecthomas/assert/v2"
 	"github.com/alecthomas/repr"
@@ -47,3 +48,41 @@ hosts = [
 	require.NoError(t, err)
 	repr.Println(toml)
 }
+
+func TestArrayTrailingComma(t *testing.T) {
+	toml, err := tomlParser.ParseString("", `
+ports = [
+  8001,
+  8002,
+]
+`)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(toml.Entries[0].Field.Value.List))
+}
+
+func TestStrings(t *testing.T) {
+	toml, err := tomlParser.ParseString("", `
+basic = "tab\there"
+literal = 'C:\Users\tom'
+`)
+	require.NoError(t, err)
+	require.Equal(t, "tab\there", *toml.Entries[0].Field.Value.String)
+	require.Equal(t, `C:\Users\tom`, *toml.Entries[1].Field.Value.String)
+}
+
+func TestTimestamps(t *testing.T) {
+	toml, err := tomlParser.ParseString("", `
+dob = 1979-05-27T07:32:00-08:00
+local = 1979-05-27T07:32:00.5
+day = 1979-05-27
+lunch = 12:30:00
+`)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(1979, 5, 27, 15, 32, 0, 0, time.UTC), toml.Entries[0].Field.Value.DateTime.UTC())
+	require.Equal(t, time.Date(1979, 5, 27, 7, 32, 0, 500000000, time.UTC), toml.Entries[1].Field.Value.DateTime.Time)
+	require.Equal(t, time.Date(1979, 5, 27, 0, 0, 0, 0, time.UTC), toml.Entries[2].Field.Value.Date.Time)
+	require.Equal(t, time.Date(0, 1, 1, 12, 30, 0, 0, time.UTC), toml.Entries[3].Field.Value.Time.Time)
+
+	_, err = tomlParser.ParseString("", `day = 1979-13-27`)
+	require.EqualError(t, err, `1:7: Value.Date: invalid date or time "1979-13-27"`)
+}
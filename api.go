@@ -9,6 +9,35 @@ type Capture interface {
 	Capture(values []string) error
 }
 
+// TokenCapture can be implemented by fields in order to transform captured tokens into field values,
+// with access to the type and position of each token.
+//
+// The tokens exclude any elided tokens skipped within the capture. TokenCapture takes precedence over
+// Capture if both are implemented. Errors created with Errorf() are reported at their position.
+type TokenCapture interface {
+	CaptureTokens(tokens []lexer.Token) error
+}
+
+// AfterParse can be implemented by grammar structs to validate or normalise themselves once all
+// of their fields have been populated.
+//
+// Errors returned by AfterParse fail the match of the struct, in the same way as a grammar mismatch,
+// and will be reported at the position of the struct if they do not carry a position of their own.
+//
+// Note that due to backtracking, AfterParse may be called on nodes that are subsequently discarded.
+type AfterParse interface {
+	AfterParse() error
+}
+
+// BeforeParse can be implemented by grammar structs to run before their grammar is attempted.
+//
+// This can be used to reject a struct early based on upcoming tokens by returning NextMatch, in
+// which case the struct is treated as not matching, or to prepare the lexer. Other errors fail
+// the match of the struct, as with AfterParse.
+type BeforeParse interface {
+	BeforeParse(lex *lexer.PeekingLexer) error
+}
+
 // The Parseable interface can be implemented by any element in the grammar to provide custom parsing.
 type Parseable interface {
 	// Parse into the receiver.
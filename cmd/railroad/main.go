@@ -5,7 +5,9 @@ import (
 	"embed"
 	"flag"
 	"fmt"
+	"html"
 	"os"
+	"strings"
 
 	"github.com/alecthomas/repr"
 
@@ -57,6 +59,9 @@ h1 {
 			break
 		}
 		s += `<h1 id="` + n.Production + `">` + n.Production + "</h1>\n"
+		if len(n.Doc) > 0 {
+			s += "<p>" + html.EscapeString(strings.Join(n.Doc, " ")) + "</p>\n"
+		}
 		s += "<script>\n"
 		s += "Diagram("
 		s += generate(productions, n.Expression)
@@ -126,6 +131,9 @@ h1 {
 		if n.Negation {
 			s = fmt.Sprintf(`Group(%s, "~")`, s)
 		}
+		if n.Field != "" {
+			s = fmt.Sprintf(`Group(%s, %q)`, s, n.Field)
+		}
 
 	default:
 		panic(repr.String(n))
@@ -5,6 +5,7 @@ import (
 	"io"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/participle/v2/lexer"
 )
@@ -16,6 +17,71 @@ type contextFieldSet struct {
 	fieldValue []reflect.Value
 }
 
+// ParseContext exposes the state of an in-progress parse to custom parse functions
+// registered with ParseTypeWithContext.
+type ParseContext struct {
+	*lexer.PeekingLexer
+	ctx *parseContext
+}
+
+// MaybeUpdateError records err as the error to report if the parse ultimately fails,
+// provided the lexer has progressed at least as far as any previously recorded error.
+func (c *ParseContext) MaybeUpdateError(err error) {
+	c.ctx.MaybeUpdateError(err)
+}
+
+// State returns the value passed to the State parse option, or nil.
+func (c *ParseContext) State() any {
+	return c.ctx.state
+}
+
+// Enter a construct nested by the custom parse function, returning an error if the nesting limit set
+// by MaxDepth() has been exceeded.
+//
+// Every call must be paired with a call to Leave().
+func (c *ParseContext) Enter() error {
+	return c.ctx.enterProduction()
+}
+
+// Leave a construct entered with Enter().
+func (c *ParseContext) Leave() {
+	c.ctx.leaveProduction()
+}
+
+// CheckLimits returns an error if the parse has been cancelled, because the deadline set by Deadline()
+// has expired or a limit set by MaxAlternatives() or MaxDepth() has been exceeded.
+//
+// Custom parse functions that consume many tokens should check it periodically, and return the error.
+func (c *ParseContext) CheckLimits() error {
+	if err := c.ctx.checkDeadline(); err != nil {
+		return err
+	}
+	return c.ctx.limitError()
+}
+
+// Recovering returns true if the parse recovers from errors, with ContinueOnError() or InsertExpected().
+func (c *ParseContext) Recovering() bool {
+	return c.ctx.continueOnError || c.ctx.insertExpected
+}
+
+// RecordError records an error that the custom parse function has recovered from, eg. by pretending
+// that a missing token was present.
+//
+// Once parsing completes, an Errors value holding each recorded error is returned alongside the AST,
+// as with InsertExpected(). Errors recorded in an alternative that is not ultimately matched are
+// discarded.
+func (c *ParseContext) RecordError(err error) {
+	c.ctx.errors = append(c.ctx.errors, err)
+}
+
+// Tracef writes a message to the parse trace, if tracing is enabled.
+func (c *ParseContext) Tracef(format string, args ...interface{}) {
+	if c.ctx.trace == nil {
+		return
+	}
+	fmt.Fprintf(c.ctx.trace, "%s%s\n", strings.Repeat(" ", c.ctx.depth*2), fmt.Sprintf(format, args...))
+}
+
 // Context for a single parse.
 type parseContext struct {
 	lexer.PeekingLexer
@@ -25,15 +91,93 @@ type parseContext struct {
 	deepestErrorDepth int
 	lookahead         int
 	caseInsensitive   map[lexer.TokenType]bool
+	literalMatch      map[lexer.TokenType]func(literal, value string) bool
 	apply             []*contextFieldSet
 	allowTrailing     bool
+	captureElided     bool
+	matchEOF          bool // Set by MatchEOF().
+	matchedEOF        bool // Whether EOF has been matched, with MatchEOF().
+	state             any
+	continueOnError   bool
+	insertExpected    bool
+	rootRepetition    *group
+	productions       int // Depth of nested structs relative to the root, with ContinueOnError().
+	errors            []error
+	alternatives      *alternativeLimit
+	depthLimit        *depthLimit
+	deadline          *parseDeadline
+	expected          *expectedLiterals                   // Only tracked with SuggestAlternatives().
+	typeMismatch      *literalTypeMismatch                // The furthest typed literal whose value, but not type, matched, on the root context.
+	nesting           map[reflect.Type]int                // Depth of structs with a `depth:"true"` field, by type.
+	target            any                                 // Set by the Parse*Into() methods to the *G to populate.
+	reuse             reflect.Value                       // The root struct to populate, until consumed by the root strct.
+	parent            *parseContext                       // The context this was branched from, if any.
+	rawValues         map[int]string                      // Values of mapped tokens before mapping, keyed by offset.
+	stringCaptures    map[stringCaptureKey]*stringCapture // Applied captures into StrictStringCapture() fields.
+	captureSites      map[stringCaptureKey][]int          // The site that captured each exclusive field.
+	interned          *internPool                         // Only set with InternTokens().
+	interleaveSinks   map[reflect.Type]reflect.Value      // Set by InterleaveInto(), keyed by production type.
+	source            *string                             // The input, with CaptureSource().
+	contextualKeyword func(lexer.Token, string) bool      // Set by ContextualKeyword().
+	seeds             map[seedKey]*seed                   // Left-recursive productions being parsed, with AllowLeftRecursion().
+	validate          bool                                // Set by Validate(), which does not populate fields...
+	validateStrings   bool                                // ...other than string fields, if they may be backreferenced.
 }
 
-func newParseContext(lex *lexer.PeekingLexer, lookahead int, caseInsensitive map[lexer.TokenType]bool) parseContext {
+// A field of a particular struct.
+type stringCaptureKey struct {
+	strct any // Pointer to the struct.
+	field string
+}
+
+type stringCapture struct {
+	pos    lexer.Position
+	tokens int
+}
+
+// A left-recursive production at the position it is being parsed at.
+type seedKey struct {
+	strct  *strct
+	cursor lexer.RawCursor
+}
+
+// The longest match so far of a left-recursive production, which its reference to itself matches.
+type seed struct {
+	value      []reflect.Value // nil until the production has matched.
+	end        lexer.Checkpoint
+	matchedEOF bool // Whether the seed matched EOF, with MatchEOF().
+}
+
+// Shared by all branches of a parse, so that attempted alternatives are counted across backtracking.
+type alternativeLimit struct {
+	max      int
+	attempts map[int]int // Keyed by cursor.
+	err      error
+}
+
+// Shared by all branches of a parse, so that exceeding the limit is fatal regardless of backtracking.
+type depthLimit struct {
+	max   int
+	depth int
+	err   error
+}
+
+// Shared by all branches of a parse, so that expiry is fatal regardless of backtracking.
+type parseDeadline struct {
+	at     time.Time
+	checks int
+	err    error
+}
+
+func newParseContext(lex *lexer.PeekingLexer, lookahead int, caseInsensitive map[lexer.TokenType]bool, literalMatch map[lexer.TokenType]func(literal, value string) bool) parseContext {
 	return parseContext{
 		PeekingLexer:    *lex,
 		caseInsensitive: caseInsensitive,
+		literalMatch:    literalMatch,
 		lookahead:       lookahead,
+		nesting:         map[reflect.Type]int{},
+		stringCaptures:  map[stringCaptureKey]*stringCapture{},
+		captureSites:    map[stringCaptureKey][]int{},
 	}
 }
 
@@ -47,6 +191,60 @@ func (p *parseContext) DeepestError(err error) error {
 	return err
 }
 
+// Record that the value, but not the type, of token matched typed literal l. As this is rare, the
+// mismatch is only allocated once one occurs, on the root context so that all branches share it.
+func (p *parseContext) recordTypeMismatch(token lexer.Token, l *literal) {
+	root := p
+	for root.parent != nil {
+		root = root.parent
+	}
+	if root.typeMismatch == nil {
+		root.typeMismatch = &literalTypeMismatch{}
+	}
+	root.typeMismatch.add(token, l)
+}
+
+// The string value captured so far into a field of strct, including captures not yet applied.
+func (p *parseContext) capturedValue(strct reflect.Value, field structLexerField) string {
+	out := strct.FieldByIndex(field.Index).String()
+	if !strct.CanAddr() {
+		return out
+	}
+	chain := []*parseContext{}
+	for ctx := p; ctx != nil; ctx = ctx.parent {
+		chain = append(chain, ctx)
+	}
+	// Captures made by ancestors precede those made in their branches.
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, apply := range chain[i].apply {
+			if apply.strct.CanAddr() && apply.strct.UnsafeAddr() == strct.UnsafeAddr() && apply.strct.Type() == strct.Type() &&
+				reflect.DeepEqual(apply.field.Index, field.Index) {
+				out += joinValues(apply.fieldValue)
+			}
+		}
+	}
+	return out
+}
+
+// The value captured for a token with the given value.
+func (p *parseContext) tokenValue(value string) reflect.Value {
+	if p.interned != nil {
+		return p.interned.value(value)
+	}
+	return reflect.ValueOf(value)
+}
+
+// The tokens of a capture, excluding elided tokens skipped within it.
+func (p *parseContext) capturedTokens(tokens []lexer.Token) []lexer.Token {
+	out := make([]lexer.Token, 0, len(tokens))
+	for _, t := range tokens {
+		if !p.IsElided(t.Type) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
 // Defer adds a function to be applied once a branch has been picked.
 func (p *parseContext) Defer(tokens []lexer.Token, strct reflect.Value, field structLexerField, fieldValue []reflect.Value) {
 	p.apply = append(p.apply, &contextFieldSet{tokens, strct, field, fieldValue})
@@ -55,7 +253,13 @@ func (p *parseContext) Defer(tokens []lexer.Token, strct reflect.Value, field st
 // Apply deferred functions.
 func (p *parseContext) Apply() error {
 	for _, apply := range p.apply {
-		if err := setField(apply.tokens, apply.strct, apply.field, apply.fieldValue); err != nil {
+		if apply.field.Strict {
+			p.countStringCapture(apply)
+		}
+		if p.validate && (!p.validateStrings || apply.field.Type.Kind() != reflect.String) {
+			continue
+		}
+		if err := setField(p, apply.tokens, apply.strct, apply.field, apply.fieldValue); err != nil {
 			return err
 		}
 	}
@@ -63,10 +267,104 @@ func (p *parseContext) Apply() error {
 	return nil
 }
 
+// Captures into a struct may be applied in several batches, so the tokens captured into each
+// StrictStringCapture() field are counted until the struct has been parsed.
+func (p *parseContext) countStringCapture(apply *contextFieldSet) {
+	if len(apply.fieldValue) == 0 || !apply.strct.CanAddr() {
+		return
+	}
+	key := stringCaptureKey{apply.strct.Addr().Interface(), apply.field.Name}
+	capture, ok := p.stringCaptures[key]
+	if !ok {
+		capture = &stringCapture{}
+		if len(apply.tokens) > 0 {
+			capture.pos = apply.tokens[0].Pos
+		}
+		p.stringCaptures[key] = capture
+	}
+	capture.tokens += len(apply.fieldValue)
+}
+
+// Record the site capturing into an exclusive field of strct, failing if another site already has.
+// Several captures in the tag of one field accumulate as usual.
+func (p *parseContext) recordCaptureSite(strct reflect.Value, field structLexerField) error {
+	if !strct.CanAddr() {
+		return nil
+	}
+	key := stringCaptureKey{strct.Addr().Interface(), field.Name}
+	if site, ok := p.captureSites[key]; ok && !reflect.DeepEqual(site, field.Site) {
+		return fmt.Errorf("can only be captured once")
+	}
+	p.captureSites[key] = field.Site
+	return nil
+}
+
+// Check that each StrictStringCapture() field of the parsed struct captured at most one token.
+func (p *parseContext) checkStringCaptures(strct reflect.Value) error {
+	if len(p.stringCaptures) == 0 {
+		return nil
+	}
+	ptr := strct.Addr().Interface()
+	var (
+		failed *stringCapture
+		field  string
+	)
+	for key, capture := range p.stringCaptures {
+		if key.strct != ptr {
+			continue
+		}
+		delete(p.stringCaptures, key)
+		if capture.tokens > 1 && (failed == nil || capture.pos.Offset < failed.pos.Offset) {
+			failed, field = capture, key.field
+		}
+	}
+	if failed == nil {
+		return nil
+	}
+	return Errorf(failed.pos, "%s.%s: %d tokens captured into a string field, capture into a slice to allow multiple tokens",
+		strct.Type().Name(), field, failed.tokens)
+}
+
+// Pretend the single token expected by n is present for InsertExpected(), recording an error and
+// returning the values n would have matched, or nil if n does not expect a single token.
+func (p *parseContext) insert(n node, parent reflect.Value) (_ []reflect.Value, err error) {
+	switch n := n.(type) {
+	case *literal:
+		if n.s == "" {
+			return nil, nil
+		}
+		p.errors = append(p.errors, &UnexpectedTokenError{Unexpected: *p.Peek(), expectNode: n})
+		return []reflect.Value{reflect.ValueOf(n.s)}, nil
+
+	case *reference:
+		// The value of the token is unknown, so nothing is captured.
+		p.errors = append(p.errors, &UnexpectedTokenError{Unexpected: *p.Peek(), expectNode: n})
+		return []reflect.Value{}, nil
+
+	case *capture:
+		v, _ := p.insert(n.node, parent)
+		if v == nil {
+			return nil, nil
+		}
+		if len(v) > 0 && !n.skip && n.field.Type != tokenType && n.field.Type != tokensType {
+			if n.registry != nil {
+				if v, err = n.registry.lookup(p.Peek().Pos, v, n.field.Type.Kind() == reflect.Slice); err != nil {
+					return nil, err
+				}
+			}
+			p.Defer(nil, parent, n.field, v)
+		}
+		return []reflect.Value{parent}, nil
+	}
+	return nil, nil
+}
+
 // Branch accepts the branch as the correct branch.
 func (p *parseContext) Accept(branch *parseContext) {
 	p.apply = append(p.apply, branch.apply...)
+	p.errors = append(p.errors, branch.errors...)
 	p.PeekingLexer = branch.PeekingLexer
+	p.matchedEOF = branch.matchedEOF
 	if branch.deepestErrorDepth >= p.deepestErrorDepth {
 		p.deepestErrorDepth = branch.deepestErrorDepth
 		p.deepestError = branch.deepestError
@@ -78,9 +376,82 @@ func (p *parseContext) Branch() *parseContext {
 	branch := &parseContext{}
 	*branch = *p
 	branch.apply = nil
+	branch.errors = nil
+	branch.parent = p
 	return branch
 }
 
+// The error from exceeding a limit set by MaxAlternatives(), MaxDepth() or Deadline(), if any.
+//
+// Exceeding a limit is fatal, even if the parse subsequently backtracked to succeed.
+func (p *parseContext) limitError() error {
+	if p.alternatives != nil && p.alternatives.err != nil {
+		return p.alternatives.err
+	}
+	if p.deadline != nil && p.deadline.err != nil {
+		return p.deadline.err
+	}
+	if p.depthLimit != nil && p.depthLimit.err != nil {
+		return p.depthLimit.err
+	}
+	return nil
+}
+
+// Check whether the deadline set by Deadline() has expired, returning an error if so.
+//
+// The clock is only consulted periodically, to keep the check cheap.
+func (p *parseContext) checkDeadline() error {
+	deadline := p.deadline
+	if deadline == nil {
+		return nil
+	}
+	if deadline.err == nil {
+		deadline.checks++
+		if deadline.checks%64 == 0 && time.Now().After(deadline.at) {
+			deadline.err = Errorf(p.Peek().Pos, "parse timed out")
+		}
+	}
+	return deadline.err
+}
+
+// Count an attempted alternative at the current position, returning an error if the
+// limit set by MaxAlternatives() has been exceeded.
+func (p *parseContext) countAlternative() error {
+	limit := p.alternatives
+	if limit == nil {
+		return nil
+	}
+	if limit.err == nil {
+		cursor := p.Cursor()
+		limit.attempts[cursor]++
+		if limit.attempts[cursor] > limit.max {
+			limit.err = Errorf(p.Peek().Pos, "more than %d alternatives attempted at this position, the grammar may be backtracking excessively", limit.max)
+		}
+	}
+	return limit.err
+}
+
+// Enter a production, returning an error if the nesting limit set by MaxDepth() has been exceeded.
+//
+// Every call must be paired with a call to leaveProduction().
+func (p *parseContext) enterProduction() error {
+	limit := p.depthLimit
+	if limit == nil {
+		return nil
+	}
+	limit.depth++
+	if limit.err == nil && limit.depth > limit.max {
+		limit.err = Errorf(p.Peek().Pos, "maximum nesting depth of %d exceeded", limit.max)
+	}
+	return limit.err
+}
+
+func (p *parseContext) leaveProduction() {
+	if p.depthLimit != nil {
+		p.depthLimit.depth--
+	}
+}
+
 func (p *parseContext) MaybeUpdateError(err error) {
 	if p.PeekingLexer.Cursor() >= p.deepestErrorDepth {
 		p.deepestError = err
@@ -90,10 +461,20 @@ func (p *parseContext) MaybeUpdateError(err error) {
 
 // Stop returns true if parsing should terminate after the given "branch" failed to match.
 //
-// Additionally, track the deepest error in the branch - the deeper the error, the more useful it usually is.
+// Additionally, track the deepest error in the branch with trackError.
+func (p *parseContext) Stop(err error, branch *parseContext) bool {
+	p.trackError(err, branch)
+	if !p.hasInfiniteLookahead() && branch.PeekingLexer.Cursor() > p.PeekingLexer.Cursor()+p.lookahead {
+		p.Accept(branch)
+		return true
+	}
+	return false
+}
+
+// Track the deepest error in a failed branch - the deeper the error, the more useful it usually is.
 // It could already be the deepest error in the branch (only if deeper than current parent context deepest),
 // or it could be "err", the latest error on the branch (even if same depth; the lexer holds the position).
-func (p *parseContext) Stop(err error, branch *parseContext) bool {
+func (p *parseContext) trackError(err error, branch *parseContext) {
 	if branch.deepestErrorDepth > p.deepestErrorDepth {
 		p.deepestError = branch.deepestError
 		p.deepestErrorDepth = branch.deepestErrorDepth
@@ -101,11 +482,6 @@ func (p *parseContext) Stop(err error, branch *parseContext) bool {
 		p.deepestError = err
 		p.deepestErrorDepth = maxInt(branch.PeekingLexer.Cursor(), branch.deepestErrorDepth)
 	}
-	if !p.hasInfiniteLookahead() && branch.PeekingLexer.Cursor() > p.PeekingLexer.Cursor()+p.lookahead {
-		p.Accept(branch)
-		return true
-	}
-	return false
 }
 
 func (p *parseContext) hasInfiniteLookahead() bool { return p.lookahead < 0 }
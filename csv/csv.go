@@ -0,0 +1,166 @@
+// Package csv provides a parser for delimiter-separated values, such as CSV and TSV.
+//
+// The grammar is:
+//
+//	File = <eol>* (Record <eol>*)* .
+//	Record = Field (<delimiter> Field)* .
+//	Field = (<quoted> | <field>)? .
+//
+// Quoted fields may contain delimiters, newlines, and quotes escaped by doubling them, as in RFC 4180.
+// Fields are not trimmed of whitespace, and blank lines are ignored.
+package csv
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+type file struct {
+	Records []*record `EOL* (@@ EOL*)*`
+}
+
+type record struct {
+	Fields []*field `(?= Quoted | Field | Delimiter) @@ (Delimiter @@)*`
+}
+
+type field struct {
+	Value string `@(Quoted | Field)?`
+}
+
+// Table of parsed records.
+type Table struct {
+	// Header is the first record, if the Header option was used.
+	Header []string
+	Rows   [][]string
+}
+
+// An Option to configure the Parser.
+type Option func(c *config)
+
+type config struct {
+	delimiter rune
+	quote     rune
+	header    bool
+}
+
+// Delimiter sets the rune separating fields. It defaults to ','.
+func Delimiter(delimiter rune) Option {
+	return func(c *config) { c.delimiter = delimiter }
+}
+
+// TSV configures the Parser for tab-separated values.
+func TSV() Option {
+	return Delimiter('\t')
+}
+
+// Quote sets the rune used to quote fields. It defaults to '"'.
+func Quote(quote rune) Option {
+	return func(c *config) { c.quote = quote }
+}
+
+// Header treats the first record as a header, rather than a row.
+func Header() Option {
+	return func(c *config) { c.header = true }
+}
+
+// A Parser for delimiter-separated values.
+type Parser struct {
+	parser *participle.Parser[file]
+	header bool
+}
+
+// New constructs a Parser for delimiter-separated values.
+func New(options ...Option) (*Parser, error) {
+	c := &config{delimiter: ',', quote: '"'}
+	for _, option := range options {
+		option(c)
+	}
+	if c.delimiter == c.quote {
+		return nil, fmt.Errorf("delimiter and quote must differ, but both are %q", c.delimiter)
+	}
+	if c.delimiter == '\n' || c.delimiter == '\r' || c.quote == '\n' || c.quote == '\r' {
+		return nil, fmt.Errorf("delimiter and quote may not be newlines")
+	}
+	delimiter := regexp.QuoteMeta(string(c.delimiter))
+	quote := regexp.QuoteMeta(string(c.quote))
+	lex, err := lexer.NewSimple([]lexer.SimpleRule{
+		{Name: "Quoted", Pattern: fmt.Sprintf(`%s(?:[^%s]|%s%s)*%s`, quote, classEscape(c.quote), quote, quote, quote)},
+		{Name: "Delimiter", Pattern: delimiter},
+		{Name: "EOL", Pattern: `\r?\n`},
+		{Name: "Field", Pattern: fmt.Sprintf(`[^%s%s\r\n]+`, classEscape(c.delimiter), classEscape(c.quote))},
+	})
+	if err != nil {
+		return nil, err
+	}
+	escapedQuote := string(c.quote) + string(c.quote)
+	parser, err := participle.Build[file](
+		participle.Lexer(lex),
+		participle.Map(func(token lexer.Token) (lexer.Token, error) {
+			token.Value = token.Value[1 : len(token.Value)-1]
+			token.Value = strings.ReplaceAll(token.Value, escapedQuote, string(c.quote))
+			return token, nil
+		}, "Quoted"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Parser{parser: parser, header: c.header}, nil
+}
+
+// Escape r for use within a regular expression character class. QuoteMeta is not suitable, as it
+// does not escape '-'.
+func classEscape(r rune) string {
+	switch r {
+	case '\\', '[', ']', '^', '-':
+		return `\` + string(r)
+	}
+	return string(r)
+}
+
+// MustNew calls New and panics if an error occurs.
+func MustNew(options ...Option) *Parser {
+	parser, err := New(options...)
+	if err != nil {
+		panic(err)
+	}
+	return parser
+}
+
+// Parse delimiter-separated values from r.
+func (p *Parser) Parse(filename string, r io.Reader) (*Table, error) {
+	ast, err := p.parser.Parse(filename, r)
+	if err != nil {
+		return nil, err
+	}
+	return p.table(ast), nil
+}
+
+// ParseString parses delimiter-separated values from s.
+func (p *Parser) ParseString(filename string, s string) (*Table, error) {
+	ast, err := p.parser.ParseString(filename, s)
+	if err != nil {
+		return nil, err
+	}
+	return p.table(ast), nil
+}
+
+func (p *Parser) table(ast *file) *Table {
+	table := &Table{Rows: make([][]string, 0, len(ast.Records))}
+	for i, record := range ast.Records {
+		row := make([]string, 0, len(record.Fields))
+		for _, field := range record.Fields {
+			row = append(row, field.Value)
+		}
+		if i == 0 && p.header {
+			table.Header = row
+		} else {
+			table.Rows = append(table.Rows, row)
+		}
+	}
+	return table
+}
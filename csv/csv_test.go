@@ -0,0 +1,57 @@
+package csv_test
+
+import (
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2/csv"
+)
+
+func TestCSV(t *testing.T) {
+	parser := csv.MustNew(csv.Header())
+	table, err := parser.ParseString("", "name,description,,count\r\n"+
+		"foo,\"a, quoted \"\"value\"\"\",,1\n"+
+		"\n"+
+		"bar,\"multi\nline\",x,2\n")
+	require.NoError(t, err)
+	require.Equal(t, &csv.Table{
+		Header: []string{"name", "description", "", "count"},
+		Rows: [][]string{
+			{"foo", `a, quoted "value"`, "", "1"},
+			{"bar", "multi\nline", "x", "2"},
+		},
+	}, table)
+}
+
+func TestTSV(t *testing.T) {
+	parser := csv.MustNew(csv.TSV(), csv.Quote('\''))
+	table, err := parser.ParseString("", "a\t'b\tc'\t\n\td")
+	require.NoError(t, err)
+	require.Equal(t, &csv.Table{
+		Rows: [][]string{
+			{"a", "b\tc", ""},
+			{"", "d"},
+		},
+	}, table)
+}
+
+func TestCharacterClassMetacharacters(t *testing.T) {
+	parser := csv.MustNew(csv.Delimiter(';'), csv.Quote('-'))
+	table, err := parser.ParseString("", "a;-b;--c-;d\n")
+	require.NoError(t, err)
+	require.Equal(t, &csv.Table{Rows: [][]string{{"a", "b;-c", "d"}}}, table)
+
+	parser = csv.MustNew(csv.Delimiter('^'), csv.Quote(']'))
+	table, err = parser.ParseString("", "a^]b^c]^\\d\n")
+	require.NoError(t, err)
+	require.Equal(t, &csv.Table{Rows: [][]string{{"a", "b^c", `\d`}}}, table)
+}
+
+func TestCSVErrors(t *testing.T) {
+	_, err := csv.New(csv.Delimiter('"'))
+	require.EqualError(t, err, `delimiter and quote must differ, but both are '"'`)
+
+	_, err = csv.MustNew().ParseString("", `a,"b`)
+	require.Error(t, err)
+}
@@ -0,0 +1,269 @@
+package participle
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Grammar is the structured form of a grammar, as returned by Parser.Grammar().
+type Grammar struct {
+	// Productions in the same order as String().
+	Productions []*GrammarProduction
+}
+
+// GrammarNode is a node of a Grammar.
+//
+// It is one of *GrammarDisjunction, *GrammarSequence, *GrammarReference, *GrammarToken,
+// *GrammarLiteral, *GrammarNegation, *GrammarGroup, *GrammarLookaround, *GrammarPredicate,
+// *GrammarBackreference or *GrammarBalanced.
+type GrammarNode interface {
+	grammarNode()
+}
+
+// GrammarProduction is a named production of the grammar.
+type GrammarProduction struct {
+	Name string
+	// Doc is the production's documentation from a `doc` tag, one entry per line.
+	Doc  []string
+	Expr GrammarNode
+}
+
+// GrammarDisjunction matches one of its alternatives.
+type GrammarDisjunction struct {
+	Alternatives []GrammarNode
+}
+
+// GrammarSequence matches each of its nodes in order.
+type GrammarSequence struct {
+	Nodes []GrammarNode
+}
+
+// GrammarReference refers to a production by name.
+//
+// Types parsed by custom functions or by implementing Parseable have no production.
+type GrammarReference struct {
+	Name string
+}
+
+// GrammarToken matches any token of a type, or any value of a LiteralSet(), eg. <ident>.
+type GrammarToken struct {
+	Name string
+}
+
+// GrammarLiteral matches a token with a particular value, eg. "func".
+type GrammarLiteral struct {
+	Value string
+	// Type is the token type the literal is constrained to, eg. "func":Keyword, if any.
+	Type string
+}
+
+// GrammarNegation matches any token that does not match Node, eg. ~"}".
+type GrammarNegation struct {
+	Node GrammarNode
+}
+
+// GrammarGroup repeats Node, eg. "*" for zero or more.
+type GrammarGroup struct {
+	Node GrammarNode
+	// Repetition is one of "", "?", "*", "+", "!", "!:unique" or "!:min".
+	Repetition string
+	// Min is the minimum count for "!:min".
+	Min int
+	// Separator, if not nil, separates repetitions, eg. "," in @@*%",".
+	Separator GrammarNode
+	// Trailing is set if a trailing separator is permitted.
+	Trailing bool
+}
+
+// GrammarLookaround asserts that Node does, or does not, match ahead of or behind the current
+// position, eg. (?= "(") or (?<! "\").
+type GrammarLookaround struct {
+	Node     GrammarNode
+	Negative bool
+	Behind   bool
+}
+
+// GrammarPredicate is a predicate registered with Predicate(), eg. &name.
+type GrammarPredicate struct {
+	Name string
+}
+
+// GrammarBackreference matches the value previously captured into a field, eg. =Name.
+type GrammarBackreference struct {
+	Field string
+}
+
+// GrammarBalanced matches from an opening delimiter to its balanced closing delimiter.
+type GrammarBalanced struct {
+	Open  []string
+	Close []string
+}
+
+func (*GrammarDisjunction) grammarNode()   {}
+func (*GrammarSequence) grammarNode()      {}
+func (*GrammarReference) grammarNode()     {}
+func (*GrammarToken) grammarNode()         {}
+func (*GrammarLiteral) grammarNode()       {}
+func (*GrammarNegation) grammarNode()      {}
+func (*GrammarGroup) grammarNode()         {}
+func (*GrammarLookaround) grammarNode()    {}
+func (*GrammarPredicate) grammarNode()     {}
+func (*GrammarBackreference) grammarNode() {}
+func (*GrammarBalanced) grammarNode()      {}
+
+// Grammar returns the grammar in structured form, equivalent to the EBNF returned by String().
+//
+// This is intended for tooling that inspects productions programmatically, without having to
+// parse the EBNF.
+func (p *Parser[G]) Grammar() *Grammar {
+	d := &grammarDescriber{seen: map[node]bool{}}
+	if root := p.rootType.Elem(); root.Kind() == reflect.Slice && root.Name() != "" {
+		production := &GrammarProduction{Name: productionName(root)}
+		d.productions = append(d.productions, production)
+		production.Expr = d.describe(p.typeNodes[root])
+	}
+	d.describe(p.typeNodes[p.rootType])
+	if p.sortEBNF && len(d.productions) > 1 {
+		rest := d.productions[1:]
+		sort.SliceStable(rest, func(i, j int) bool { return rest[i].Name < rest[j].Name })
+	}
+	return &Grammar{Productions: d.productions}
+}
+
+type grammarDescriber struct {
+	seen        map[node]bool
+	productions []*GrammarProduction
+}
+
+// Describe n, adding any productions it defines. This mirrors buildEBNF.
+func (d *grammarDescriber) describe(n node) GrammarNode { // nolint: gocyclo
+	switch n := n.(type) {
+	case *disjunction:
+		out := &GrammarDisjunction{}
+		for _, next := range n.nodes {
+			out.Alternatives = append(out.Alternatives, d.describe(next))
+		}
+		return out
+
+	case *union:
+		name := productionName(n.typ)
+		if !d.seen[n] {
+			d.seen[n] = true
+			production := &GrammarProduction{Name: name}
+			d.productions = append(d.productions, production)
+			production.Expr = d.describe(&n.disjunction)
+		}
+		return &GrammarReference{Name: name}
+
+	case *custom:
+		return &GrammarReference{Name: productionName(n.typ)}
+
+	case *strct:
+		name := productionName(n.typ)
+		if !d.seen[n] {
+			d.seen[n] = true
+			production := &GrammarProduction{Name: name}
+			if n.doc != "" {
+				production.Doc = strings.Split(n.doc, "\n")
+			}
+			d.productions = append(d.productions, production)
+			production.Expr = d.describe(n.expr)
+		}
+		return &GrammarReference{Name: name}
+
+	case *sequence:
+		if n.next == nil {
+			return d.describe(n.node)
+		}
+		out := &GrammarSequence{}
+		for ; n != nil; n = n.next {
+			out.Nodes = append(out.Nodes, d.describe(n.node))
+		}
+		return out
+
+	case *parseable:
+		return &GrammarReference{Name: n.t.Name()}
+
+	case *capture:
+		return d.describe(n.node)
+
+	case *reference:
+		return &GrammarToken{Name: strings.ToLower(n.identifier)}
+
+	case *negation:
+		return &GrammarNegation{Node: d.describe(n.node)}
+
+	case *literal:
+		out := &GrammarLiteral{Value: n.s}
+		if n.t != lexer.EOF {
+			out.Type = n.tt
+		}
+		return out
+
+	case *predicate:
+		return &GrammarPredicate{Name: n.name}
+
+	case *productionRef:
+		return &GrammarReference{Name: strings.ToUpper(n.name[:1]) + n.name[1:]}
+
+	case *backreference:
+		return &GrammarBackreference{Field: n.field.Name}
+
+	case *literalSet:
+		return &GrammarToken{Name: strings.ToLower(n.name)}
+
+	case *balanced:
+		out := &GrammarBalanced{}
+		for i := range n.open {
+			out.Open = append(out.Open, n.open[i].s)
+			out.Close = append(out.Close, n.close[i].s)
+		}
+		return out
+
+	case *group:
+		return d.describeGroup(n)
+
+	case *lookaheadGroup:
+		return &GrammarLookaround{Node: d.describe(n.expr), Negative: n.negative}
+
+	case *lookbehindGroup:
+		return &GrammarLookaround{Node: d.describe(n.expr), Negative: n.negative, Behind: true}
+
+	default:
+		panic(fmt.Sprintf("unsupported node type %T", n))
+	}
+}
+
+func (d *grammarDescriber) describeGroup(n *group) GrammarNode {
+	expr := n.expr
+	if child, ok := expr.(*capture); ok {
+		expr = child.node
+	}
+	if child, ok := expr.(*group); ok && child.mode == groupMatchOnce {
+		expr = child.expr
+	}
+	out := &GrammarGroup{Node: d.describe(expr), Min: n.min, Trailing: n.trailing}
+	switch n.mode {
+	case groupMatchNonEmpty:
+		out.Repetition = "!"
+	case groupMatchUnique:
+		out.Repetition = "!:unique"
+	case groupMatchAtLeast:
+		out.Repetition = "!:min"
+	case groupMatchZeroOrOne:
+		out.Repetition = "?"
+	case groupMatchZeroOrMore:
+		out.Repetition = "*"
+	case groupMatchOneOrMore:
+		out.Repetition = "+"
+	case groupMatchOnce:
+	}
+	if n.sep != nil {
+		out.Separator = d.describe(n.sep)
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package participle_test
+
+import (
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
+)
+
+func TestGrammar(t *testing.T) {
+	type call struct {
+		_    struct{} `doc:"A function call."`
+		Name string   `@Ident (?= "(")`
+		Args []string `"(" @Ident*%","? ")"`
+	}
+	type grammar struct {
+		Calls []*call `@@+`
+		Rest  string  `~"end"* "end":Ident`
+	}
+	parser := mustTestParser[grammar](t)
+	require.Equal(t, &participle.Grammar{Productions: []*participle.GrammarProduction{
+		{Name: "Grammar", Expr: &participle.GrammarSequence{Nodes: []participle.GrammarNode{
+			&participle.GrammarGroup{Node: &participle.GrammarReference{Name: "Call"}, Repetition: "+"},
+			&participle.GrammarGroup{Node: &participle.GrammarNegation{Node: &participle.GrammarLiteral{Value: "end"}}, Repetition: "*"},
+			&participle.GrammarLiteral{Value: "end", Type: "Ident"},
+		}}},
+		{Name: "Call", Doc: []string{"A function call."}, Expr: &participle.GrammarSequence{Nodes: []participle.GrammarNode{
+			&participle.GrammarToken{Name: "ident"},
+			&participle.GrammarLookaround{Node: &participle.GrammarLiteral{Value: "("}},
+			&participle.GrammarLiteral{Value: "("},
+			&participle.GrammarGroup{
+				Node:       &participle.GrammarToken{Name: "ident"},
+				Repetition: "*",
+				Separator:  &participle.GrammarLiteral{Value: ","},
+				Trailing:   true,
+			},
+			&participle.GrammarLiteral{Value: ")"},
+		}}},
+	}}, parser.Grammar())
+}
+
+func TestGrammarSorted(t *testing.T) {
+	parser := mustTestParser[EBNF](t, participle.SortedEBNF())
+	names := []string{}
+	for _, production := range parser.Grammar().Productions {
+		names = append(names, production.Name)
+	}
+	require.Equal(t, []string{
+		"EBNF", "EBNFOption", "Expression", "Group", "Literal", "LookaheadGroup", "Negation",
+		"Production", "Range", "Repetition", "Sequence", "Term",
+	}, names)
+}
@@ -6,12 +6,15 @@
 //
 //   - `@<expr>` Capture expression into the field.
 //   - `@@` Recursively capture using the fields own type.
-//   - `<identifier>` Match named lexer token.
+//   - `<identifier>` Match named lexer token, or a value in the set registered with LiteralSet().
 //   - `( ... )` Group.
 //   - `"..."` Match the literal (note that the lexer must emit tokens matching this literal exactly).
 //   - `"...":<identifier>` Match the literal, specifying the exact lexer token type to match.
 //   - `<expr> <expr> ...` Match expressions.
 //   - `<expr> | <expr>` Match one of the alternatives.
+//   - `Balanced("<open>", "<close>", ...)` Match a region enclosed by balanced pairs of delimiters.
+//   - `&<name>` Call the predicate registered with Predicate(), failing to match if it returns false.
+//   - `=<field>` Match a token equal to the text previously captured into the string <field>.
 //
 // The following modifiers can be used after any expression:
 //
@@ -19,6 +22,9 @@
 //   - `+` Expression must match one or more times.
 //   - `?` Expression can match zero or once.
 //   - `!` Require a non-empty match (this is useful with a sequence of optional matches eg. `("a"? "b"? "c"?)!`).
+//   - `!:unique` Match each alternative of a group or repetition at most once, in any order.
+//   - `!:min(n)` Expression must match at least n times, with an optional custom error eg. `!:min(1, "empty block")`.
+//   - `*%<sep>` / `+%<sep>` Match zero/one or more times separated by <sep>, with a trailing `?` allowing a trailing separator.
 //
 // Here's an example of an EBNF grammar.
 //
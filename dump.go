@@ -0,0 +1,64 @@
+package participle
+
+import (
+	"fmt"
+	"go/format"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DumpStructs returns Go source declaring the structs of the grammar, with their tags.
+//
+// Structs are declared in the order of their productions in String(), and types from the package
+// of the root struct are unqualified. This is useful for snapshotting grammars, and as a starting
+// point when migrating generated grammars to hand-written ones. Union interfaces and custom types
+// are not declared.
+func (p *Parser[G]) DumpStructs() string {
+	types := []reflect.Type{}
+	seen := map[node]bool{}
+	_ = visit(p.typeNodes[p.rootType], func(n node, next func() error) error {
+		if seen[n] {
+			return nil
+		}
+		seen[n] = true
+		if s, ok := n.(*strct); ok {
+			types = append(types, s.typ)
+		}
+		return next()
+	})
+	if len(types) == 0 {
+		return ""
+	}
+	pkg := regexp.MustCompile(`\b` + regexp.QuoteMeta(strings.SplitN(types[0].String(), ".", 2)[0]) + `\.`)
+	w := &strings.Builder{}
+	for _, t := range types {
+		fmt.Fprintf(w, "type %s struct {\n", t.Name())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			typ := pkg.ReplaceAllString(field.Type.String(), "")
+			if field.Anonymous {
+				fmt.Fprintf(w, "\t%s", typ)
+			} else {
+				fmt.Fprintf(w, "\t%s %s", field.Name, typ)
+			}
+			if field.Tag != "" {
+				tag := string(field.Tag)
+				if strings.Contains(tag, "`") {
+					tag = strconv.Quote(tag)
+				} else {
+					tag = "`" + tag + "`"
+				}
+				fmt.Fprintf(w, " %s", tag)
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprint(w, "}\n\n")
+	}
+	out := strings.TrimSuffix(w.String(), "\n")
+	if formatted, err := format.Source([]byte(out)); err == nil {
+		return string(formatted)
+	}
+	return out
+}
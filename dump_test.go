@@ -0,0 +1,43 @@
+package participle_test
+
+import (
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+type dumpValue struct {
+	Pos    lexer.Position
+	Number *int        `  @Int`
+	List   []dumpValue `| "[" @@*%"," "]"`
+}
+
+type dumpEntry struct {
+	Key   string     `parser:"@Ident '='" json:"key"`
+	Value *dumpValue `parser:"@@"`
+}
+
+type dumpConfig struct {
+	Entries []*dumpEntry `@@*`
+}
+
+func TestDumpStructs(t *testing.T) {
+	parser := mustTestParser[dumpConfig](t)
+	expected := "type dumpConfig struct {\n" +
+		"\tEntries []*dumpEntry `@@*`\n" +
+		"}\n" +
+		"\n" +
+		"type dumpEntry struct {\n" +
+		"\tKey   string     `parser:\"@Ident '='\" json:\"key\"`\n" +
+		"\tValue *dumpValue `parser:\"@@\"`\n" +
+		"}\n" +
+		"\n" +
+		"type dumpValue struct {\n" +
+		"\tPos    lexer.Position\n" +
+		"\tNumber *int        `  @Int`\n" +
+		"\tList   []dumpValue `| \"[\" @@*%\",\" \"]\"`\n" +
+		"}\n"
+	require.Equal(t, expected, parser.DumpStructs())
+}
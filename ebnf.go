@@ -2,40 +2,71 @@ package participle
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 )
 
 // String returns the EBNF for the grammar.
 //
 // Productions are always upper cased. Lexer tokens are always lower case.
+//
+// Productions whose struct has a blank field with a doc tag, eg. "_ struct{} `doc:\"...\"`", are
+// preceded by the documentation as "//" comments.
+//
+// The root production is always first. The remaining productions are in the order they are first
+// referenced by the grammar, which changes if eg. struct fields are reordered. Use the SortedEBNF()
+// option to instead sort them by name, for output that is stable across such changes, and the
+// AnnotatedEBNF() option to prefix captures with the name of their field.
 func (p *Parser[G]) String() string {
-	return ebnf(p.typeNodes[p.rootType])
+	if root := p.rootType.Elem(); root.Kind() == reflect.Slice && root.Name() != "" {
+		return fmt.Sprintf("%s = %s .\n%s", productionName(root),
+			ebnfProductions(p.typeNodes[root], false, p.annotateEBNF),
+			ebnfProductions(p.typeNodes[p.rootType], p.sortEBNF, p.annotateEBNF))
+	}
+	return ebnfProductions(p.typeNodes[p.rootType], p.sortEBNF, p.annotateEBNF)
 }
 
 type ebnfp struct {
 	name string
+	doc  string
 	out  string
 }
 
 func ebnf(n node) string {
+	return ebnfProductions(n, false, false)
+}
+
+// Render the EBNF for n, optionally sorting all productions but the first by name, and optionally
+// prefixing captures with the name of their field.
+func ebnfProductions(n node, sorted, annotated bool) string {
 	outp := []*ebnfp{}
 	switch n.(type) {
 	case *strct:
-		buildEBNF(true, n, map[node]bool{}, nil, &outp)
+		buildEBNF(true, n, map[node]bool{}, nil, &outp, annotated)
+		if sorted && len(outp) > 1 {
+			rest := outp[1:]
+			sort.SliceStable(rest, func(i, j int) bool { return rest[i].name < rest[j].name })
+		}
 		out := []string{}
 		for _, p := range outp {
+			if p.doc != "" {
+				for _, line := range strings.Split(p.doc, "\n") {
+					out = append(out, strings.TrimSpace("// "+line))
+				}
+			}
 			out = append(out, fmt.Sprintf("%s = %s .", p.name, p.out))
 		}
 		return strings.Join(out, "\n")
 
 	default:
 		out := &ebnfp{}
-		buildEBNF(true, n, map[node]bool{}, out, &outp)
+		buildEBNF(true, n, map[node]bool{}, out, &outp, annotated)
 		return out.out
 	}
 }
 
-func buildEBNF(root bool, n node, seen map[node]bool, p *ebnfp, outp *[]*ebnfp) {
+func buildEBNF(root bool, n node, seen map[node]bool, p *ebnfp, outp *[]*ebnfp, annotate bool) {
 	switch n := n.(type) {
 	case *disjunction:
 		if !root {
@@ -45,14 +76,14 @@ func buildEBNF(root bool, n node, seen map[node]bool, p *ebnfp, outp *[]*ebnfp)
 			if i > 0 {
 				p.out += " | "
 			}
-			buildEBNF(false, next, seen, p, outp)
+			buildEBNF(false, next, seen, p, outp, annotate)
 		}
 		if !root {
 			p.out += ")"
 		}
 
 	case *union:
-		name := strings.ToUpper(n.typ.Name()[:1]) + n.typ.Name()[1:]
+		name := productionName(n.typ)
 		if p != nil {
 			p.out += name
 		}
@@ -66,15 +97,15 @@ func buildEBNF(root bool, n node, seen map[node]bool, p *ebnfp, outp *[]*ebnfp)
 			if i > 0 {
 				p.out += " | "
 			}
-			buildEBNF(false, next, seen, p, outp)
+			buildEBNF(false, next, seen, p, outp, annotate)
 		}
 
 	case *custom:
-		name := strings.ToUpper(n.typ.Name()[:1]) + n.typ.Name()[1:]
+		name := productionName(n.typ)
 		p.out += name
 
 	case *strct:
-		name := strings.ToUpper(n.typ.Name()[:1]) + n.typ.Name()[1:]
+		name := productionName(n.typ)
 		if p != nil {
 			p.out += name
 		}
@@ -82,9 +113,9 @@ func buildEBNF(root bool, n node, seen map[node]bool, p *ebnfp, outp *[]*ebnfp)
 			return
 		}
 		seen[n] = true
-		p = &ebnfp{name: name}
+		p = &ebnfp{name: name, doc: n.doc}
 		*outp = append(*outp, p)
-		buildEBNF(true, n.expr, seen, p, outp)
+		buildEBNF(true, n.expr, seen, p, outp, annotate)
 
 	case *sequence:
 		group := n.next != nil && !root
@@ -92,7 +123,7 @@ func buildEBNF(root bool, n node, seen map[node]bool, p *ebnfp, outp *[]*ebnfp)
 			p.out += "("
 		}
 		for n != nil {
-			buildEBNF(false, n.node, seen, p, outp)
+			buildEBNF(false, n.node, seen, p, outp, annotate)
 			n = n.next
 			if n != nil {
 				p.out += " "
@@ -106,33 +137,68 @@ func buildEBNF(root bool, n node, seen map[node]bool, p *ebnfp, outp *[]*ebnfp)
 		p.out += n.t.Name()
 
 	case *capture:
-		buildEBNF(false, n.node, seen, p, outp)
+		if annotate {
+			p.out += n.field.Name + ":"
+		}
+		buildEBNF(false, n.node, seen, p, outp, annotate)
 
 	case *reference:
 		p.out += "<" + strings.ToLower(n.identifier) + ">"
 
 	case *negation:
 		p.out += "~"
-		buildEBNF(false, n.node, seen, p, outp)
+		buildEBNF(false, n.node, seen, p, outp, annotate)
 
 	case *literal:
 		p.out += fmt.Sprintf("%q", n.s)
 
+	case *predicate:
+		p.out += "&" + n.name
+
+	case *productionRef:
+		p.out += strings.ToUpper(n.name[:1]) + n.name[1:]
+
+	case *backreference:
+		p.out += "=" + n.field.Name
+
+	case *literalSet:
+		p.out += "<" + strings.ToLower(n.name) + ">"
+
+	case *balanced:
+		if annotate && n.field != nil {
+			p.out += n.field.Name + ":"
+		}
+		p.out += "Balanced("
+		for i := range n.open {
+			if i > 0 {
+				p.out += ", "
+			}
+			p.out += fmt.Sprintf("%q, %q", n.open[i].s, n.close[i].s)
+		}
+		p.out += ")"
+
 	case *group:
 		if child, ok := n.expr.(*group); ok && child.mode == groupMatchOnce {
-			buildEBNF(false, child.expr, seen, p, outp)
+			buildEBNF(false, child.expr, seen, p, outp, annotate)
 		} else if child, ok := n.expr.(*capture); ok {
+			if annotate {
+				p.out += child.field.Name + ":"
+			}
 			if grandchild, ok := child.node.(*group); ok && grandchild.mode == groupMatchOnce {
-				buildEBNF(false, grandchild.expr, seen, p, outp)
+				buildEBNF(false, grandchild.expr, seen, p, outp, annotate)
 			} else {
-				buildEBNF(false, n.expr, seen, p, outp)
+				buildEBNF(false, child.node, seen, p, outp, annotate)
 			}
 		} else {
-			buildEBNF(false, n.expr, seen, p, outp)
+			buildEBNF(false, n.expr, seen, p, outp, annotate)
 		}
 		switch n.mode {
 		case groupMatchNonEmpty:
 			p.out += "!"
+		case groupMatchUnique:
+			p.out += "!:unique"
+		case groupMatchAtLeast:
+			p.out += fmt.Sprintf("!:min(%d)", n.min)
 		case groupMatchZeroOrOne:
 			p.out += "?"
 		case groupMatchZeroOrMore:
@@ -141,6 +207,13 @@ func buildEBNF(root bool, n node, seen map[node]bool, p *ebnfp, outp *[]*ebnfp)
 			p.out += "+"
 		case groupMatchOnce:
 		}
+		if n.sep != nil {
+			p.out += "%"
+			buildEBNF(false, n.sep, seen, p, outp, annotate)
+			if n.trailing {
+				p.out += "?"
+			}
+		}
 
 	case *lookaheadGroup:
 		if !n.negative {
@@ -148,10 +221,24 @@ func buildEBNF(root bool, n node, seen map[node]bool, p *ebnfp, outp *[]*ebnfp)
 		} else {
 			p.out += "(?! "
 		}
-		buildEBNF(true, n.expr, seen, p, outp)
+		buildEBNF(true, n.expr, seen, p, outp, annotate)
+		p.out += ")"
+
+	case *lookbehindGroup:
+		if !n.negative {
+			p.out += "(?<= "
+		} else {
+			p.out += "(?<! "
+		}
+		buildEBNF(true, n.expr, seen, p, outp, annotate)
 		p.out += ")"
 
 	default:
 		panic(fmt.Sprintf("unsupported node type %T", n))
 	}
 }
+
+// Productions are named after their type, upper cased.
+func productionName(t reflect.Type) string {
+	return strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+}
@@ -7,17 +7,28 @@
 //	Expression = Sequence ("|" Sequence)* .
 //	SubExpression = "(" ("?!" | "?=")? Expression ")" .
 //	Sequence = Term+ .
-//	Term = "~"? (<ident> | <string> | ("<" <ident> ">") | SubExpression) ("*" | "+" | "?" | "!")? .
+//	Term = (<ident> ":")? "~"? (<ident> | <string> | ("<" <ident> ">") | SubExpression) ("*" | "+" | "?" | "!")? .
 package ebnf
 
 import (
 	"fmt"
 	"io"
+	"strings"
+	"text/scanner"
 
 	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
 )
 
-var parser = participle.MustBuild[EBNF]()
+var parser = participle.MustBuild[EBNF](
+	// Distinguishes an annotated field name from a production name.
+	participle.UseLookahead(2),
+	// Comments are lexed so that production documentation can be collected, but are not part of the grammar.
+	participle.Lexer(lexer.NewTextScannerLexer(func(s *scanner.Scanner) { s.Mode &^= scanner.SkipComments })),
+	participle.Elide("Comment"),
+)
+
+var commentType = parser.Lexer().Symbols()["Comment"]
 
 // A Node in the EBNF grammar.
 type Node interface {
@@ -28,7 +39,9 @@ var _ Node = &Term{}
 
 // Term in the EBNF grammar.
 type Term struct {
-	Negation bool `@("~")?`
+	// Field is the field captured into, with participle.AnnotatedEBNF().
+	Field    string `(@Ident ":")?`
+	Negation bool   `@("~")?`
 
 	Name    string         `(   @Ident`
 	Literal string         `  | @String`
@@ -41,6 +54,13 @@ type Term struct {
 func (t *Term) sealed() {}
 
 func (t *Term) String() string {
+	if t.Field != "" {
+		return t.Field + ":" + t.term()
+	}
+	return t.term()
+}
+
+func (t *Term) term() string {
 	switch {
 	case t.Name != "":
 		return t.Name + t.Repetition
@@ -140,6 +160,10 @@ var _ Node = &Production{}
 
 // Production of the grammar.
 type Production struct {
+	Pos lexer.Position
+
+	// Doc is the documentation preceding the production, one entry per "//" comment line.
+	Doc        []string
 	Production string      `@Ident "="`
 	Expression *Expression `@@ "."`
 }
@@ -157,6 +181,9 @@ func (e *EBNF) sealed() {}
 
 func (e *EBNF) String() (out string) {
 	for i, production := range e.Productions {
+		for _, line := range production.Doc {
+			out += strings.TrimSpace("// "+line) + "\n"
+		}
 		out += fmt.Sprintf("%s = %s .", production.Production, production.Expression)
 		if i < len(e.Productions)-1 {
 			out += "\n"
@@ -167,10 +194,42 @@ func (e *EBNF) String() (out string) {
 
 // ParseString string into EBNF.
 func ParseString(ebnf string) (*EBNF, error) {
-	return parser.ParseString("", ebnf)
+	return Parse(strings.NewReader(ebnf))
 }
 
 // Parse io.Reader into EBNF.
 func Parse(r io.Reader) (*EBNF, error) {
-	return parser.Parse("", r)
+	tokens, err := parser.Lex("", r)
+	if err != nil {
+		return nil, err
+	}
+	ebnf, err := parser.ParseTokens("", tokens)
+	if err != nil {
+		return ebnf, err
+	}
+	attachDocs(ebnf, tokens)
+	return ebnf, nil
+}
+
+// attachDocs sets the Doc of each production to the "//" comments immediately preceding it.
+func attachDocs(ebnf *EBNF, tokens []lexer.Token) {
+	productions := map[int]*Production{}
+	for _, production := range ebnf.Productions {
+		productions[production.Pos.Offset] = production
+	}
+	var doc []string
+	for _, token := range tokens {
+		if token.Type == commentType {
+			if strings.HasPrefix(token.Value, "//") {
+				doc = append(doc, strings.TrimSpace(strings.TrimPrefix(token.Value, "//")))
+			} else {
+				doc = nil
+			}
+			continue
+		}
+		if production, ok := productions[token.Pos.Offset]; ok {
+			production.Doc = doc
+		}
+		doc = nil
+	}
 }
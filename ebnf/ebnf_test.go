@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
 )
 
 func TestEBNF(t *testing.T) {
@@ -13,3 +15,39 @@ func TestEBNF(t *testing.T) {
 	require.NoError(t, err, input)
 	require.Equal(t, input, ast.String())
 }
+
+func TestEBNFDoc(t *testing.T) {
+	input := "// Root of the grammar.\n//\n// It is empty.\nRoot = Empty* .\nEmpty = \"empty\" ."
+	ast, err := ParseString(input)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Root of the grammar.", "", "It is empty."}, ast.Productions[0].Doc)
+	require.Equal(t, input, ast.String())
+}
+
+func TestEBNFComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"BetweenAlternatives", "A = \"a\" // x\n| \"b\" .", `A = "a" | "b" .`},
+		{"Trailing", "A = \"a\" .\n// x", `A = "a" .`},
+		{"Block", "/* x */\nA = /* y */ \"a\" .", `A = "a" .`},
+		{"BlockBreaksDoc", "// x\n/* y */\n// z\nA = \"a\" .", "// z\nA = \"a\" ."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ast, err := ParseString(test.input)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, ast.String())
+		})
+	}
+}
+
+func TestEBNFAnnotated(t *testing.T) {
+	input := participle.MustBuild[EBNF](participle.AnnotatedEBNF()).String()
+	ast, err := ParseString(input)
+	require.NoError(t, err, input)
+	require.Equal(t, input, ast.String())
+	require.Equal(t, "Production", ast.Productions[1].Expression.Alternatives[0].Terms[0].Field)
+}
@@ -27,6 +27,44 @@ Negation = "!" Expression .
 	require.Equal(t, strings.TrimSpace(expected), parser.String())
 }
 
+func TestEBNFSorted(t *testing.T) {
+	parser := mustTestParser[EBNF](t, participle.SortedEBNF())
+	expected := `
+EBNF = Production* .
+EBNFOption = "[" Expression "]" .
+Expression = Sequence ("|" Sequence)* .
+Group = "(" Expression ")" .
+Literal = <string> .
+LookaheadGroup = "(" "?" ("=" | "!") Expression ")" .
+Negation = "!" Expression .
+Production = <ident> "=" Expression+ "." .
+Range = <string> "…" <string> .
+Repetition = "{" Expression "}" .
+Sequence = Term+ .
+Term = <ident> | Literal | Range | Group | LookaheadGroup | EBNFOption | Repetition | Negation .
+`
+	require.Equal(t, strings.TrimSpace(expected), parser.String())
+}
+
+func TestEBNFAnnotated(t *testing.T) {
+	parser := mustTestParser[EBNF](t, participle.AnnotatedEBNF())
+	expected := `
+EBNF = Productions:Production* .
+Production = Name:<ident> "=" Expression:Expression+ "." .
+Expression = Alternatives:Sequence ("|" Alternatives:Sequence)* .
+Sequence = Terms:Term+ .
+Term = Name:<ident> | Literal:Literal | Range:Range | Group:Group | LookaheadGroup:LookaheadGroup | Option:EBNFOption | Repetition:Repetition | Negation:Negation .
+Literal = Start:<string> .
+Range = Start:<string> "…" End:<string> .
+Group = "(" Expression:Expression ")" .
+LookaheadGroup = "(" "?" ("=" | "!") Expression:Expression ")" .
+EBNFOption = "[" Expression:Expression "]" .
+Repetition = "{" Expression:Expression "}" .
+Negation = "!" Expression:Expression .
+`
+	require.Equal(t, strings.TrimSpace(expected), parser.String())
+}
+
 func TestEBNF_Other(t *testing.T) {
 	type Grammar struct {
 		PositiveLookahead string `  (?= 'good') @Ident`
@@ -75,3 +113,28 @@ EBNFUnionC = <float> .
 `),
 		parser.String())
 }
+
+func TestEBNF_Doc(t *testing.T) {
+	type Value struct {
+		_ struct{} `doc:"A single value."`
+
+		Ident string `@Ident`
+	}
+	type Grammar struct {
+		_ struct{} `doc:"A list of values.\n\nValues are separated by commas."`
+
+		Values []*Value `@@ ("," @@)*`
+	}
+
+	parser := mustTestParser[Grammar](t)
+	require.Equal(t,
+		strings.TrimSpace(`
+// A list of values.
+//
+// Values are separated by commas.
+Grammar = Value ("," Value)* .
+// A single value.
+Value = <ident> .
+`),
+		parser.String())
+}
@@ -1,7 +1,12 @@
 package participle
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/alecthomas/participle/v2/lexer"
 )
@@ -43,22 +48,96 @@ func FormatError(err Error) string {
 type UnexpectedTokenError struct {
 	Unexpected lexer.Token
 	Expect     string
-	expectNode node // Usable instead of Expect, delays creating the string representation until necessary
+	// Suggestion is the expected literal closest to the unexpected token, if SuggestAlternatives() is enabled.
+	Suggestion string
+	expectNode node   // Usable instead of Expect, delays creating the string representation until necessary
+	mismatch   string // Explains a typed literal that matched the token's value but not its type.
+	formatter  ErrorFormatterFunc
 }
 
-func (u *UnexpectedTokenError) Error() string { return FormatError(u) }
+func (u *UnexpectedTokenError) Error() string {
+	if u.formatter != nil {
+		return u.formatter(u.Position(), u.Unexpected, u.expected())
+	}
+	return FormatError(u)
+}
 
 func (u *UnexpectedTokenError) Message() string { // nolint: golint
 	var expected string
-	if u.expectNode != nil {
-		expected = fmt.Sprintf(" (expected %s)", u.expectNode)
-	} else if u.Expect != "" {
-		expected = fmt.Sprintf(" (expected %s)", u.Expect)
+	if expect := u.expected(); expect != "" {
+		expected = fmt.Sprintf(" (expected %s)", expect)
+	}
+	if u.Suggestion != "" {
+		expected += fmt.Sprintf(", did you mean %q?", u.Suggestion)
+	}
+	if u.mismatch != "" {
+		expected += ": " + u.mismatch
 	}
 	return fmt.Sprintf("unexpected token %q%s", u.Unexpected, expected)
 }
 func (u *UnexpectedTokenError) Position() lexer.Position { return u.Unexpected.Pos } // nolint: golint
 
+// The expected grammar, if known.
+func (u *UnexpectedTokenError) expected() string {
+	if u.expectNode != nil {
+		return u.expectNode.String()
+	}
+	return u.Expect
+}
+
+// ErrorFormatterFunc renders an unexpected token error, given its position, the unexpected token,
+// and the expected grammar, which is empty if unknown.
+type ErrorFormatterFunc func(pos lexer.Position, unexpected lexer.Token, expected string) string
+
+// Format every unexpected token error with the given formatter.
+func formatUnexpectedTokens(err error, formatter ErrorFormatterFunc) {
+	if errs, ok := err.(Errors); ok { // nolint: errorlint
+		for _, err := range errs {
+			formatUnexpectedTokens(err, formatter)
+		}
+		return
+	}
+	var uerr *UnexpectedTokenError
+	if errors.As(err, &uerr) {
+		uerr.formatter = formatter
+	}
+}
+
+// The furthest token whose value matched a typed literal such as "123":String, but whose type did not.
+// Shared by all branches of a parse.
+type literalTypeMismatch struct {
+	token   *lexer.Token
+	literal *literal
+}
+
+func (m *literalTypeMismatch) add(token lexer.Token, literal *literal) {
+	if token.EOF() || (m.token != nil && token.Pos.Offset <= m.token.Pos.Offset) {
+		return
+	}
+	m.token, m.literal = &token, literal
+}
+
+// Explain a type mismatch at the unexpected token of each error, as the token otherwise appears to
+// be the expected literal.
+func explainTypeMismatch(err error, mismatch *literalTypeMismatch, symbols map[lexer.TokenType]string) {
+	if errs, ok := err.(Errors); ok { // nolint: errorlint
+		for _, err := range errs {
+			explainTypeMismatch(err, mismatch, symbols)
+		}
+		return
+	}
+	var uerr *UnexpectedTokenError
+	if mismatch.token == nil || !errors.As(err, &uerr) || uerr.Unexpected.Pos.Offset != mismatch.token.Pos.Offset {
+		return
+	}
+	actual, ok := symbols[mismatch.token.Type]
+	if !ok {
+		actual = fmt.Sprintf("%d", mismatch.token.Type)
+	}
+	uerr.mismatch = fmt.Sprintf("the value matches %q:%s but the token type is %s, not %s",
+		mismatch.literal.s, mismatch.literal.tt, actual, mismatch.literal.tt)
+}
+
 // ParseError is returned when a parse error occurs.
 //
 // It is useful for differentiating between parse errors and other errors such
@@ -100,3 +179,112 @@ func Wrapf(pos lexer.Position, err error, format string, args ...interface{}) Er
 	}
 	return &wrappingParseError{err: err, ParseError: ParseError{Msg: msg, Pos: pos}}
 }
+
+// Errors is returned by a parse using ContinueOnError() that failed, with one error for each
+// failed element of the root repetition, in order.
+//
+// Every entry implements Error.
+type Errors []error
+
+func (e Errors) Error() string {
+	lines := make([]string, 0, len(e))
+	for _, err := range e {
+		lines = append(lines, err.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Unwrap returns the individual errors.
+func (e Errors) Unwrap() []error { return e }
+
+// Positions returns the position of each error.
+func (e Errors) Positions() []lexer.Position {
+	out := make([]lexer.Position, 0, len(e))
+	for _, err := range e {
+		var perr Error
+		if errors.As(err, &perr) {
+			out = append(out, perr.Position())
+		} else {
+			out = append(out, lexer.Position{})
+		}
+	}
+	return out
+}
+
+// Sort the errors by position, in place.
+func (e Errors) Sort() {
+	positions := e.Positions()
+	sort.Stable(errorsByPosition{e, positions})
+}
+
+type errorsByPosition struct {
+	errs      Errors
+	positions []lexer.Position
+}
+
+func (e errorsByPosition) Len() int           { return len(e.errs) }
+func (e errorsByPosition) Less(i, j int) bool { return e.positions[i].Offset < e.positions[j].Offset }
+func (e errorsByPosition) Swap(i, j int) {
+	e.errs[i], e.errs[j] = e.errs[j], e.errs[i]
+	e.positions[i], e.positions[j] = e.positions[j], e.positions[i]
+}
+
+// JSONError is the machine-readable form of an error, as produced by ErrorsAsJSON.
+type JSONError struct {
+	Filename  string `json:"filename,omitempty"`
+	Offset    int    `json:"offset"`
+	Line      int    `json:"line"`
+	Column    int    `json:"column"`
+	EndOffset int    `json:"endOffset"`
+	EndLine   int    `json:"endLine"`
+	EndColumn int    `json:"endColumn"`
+	Message   string `json:"message"`
+}
+
+// ErrorsAsJSON serialises err to a JSON array of JSONError for consumption by other tools.
+//
+// Errors wrapping multiple errors, via an "Unwrap() []error" method, are flattened into one
+// entry per error. Errors that neither are nor wrap an Error will have no positional information.
+func ErrorsAsJSON(err error) ([]byte, error) {
+	out := []JSONError{}
+	var collect func(err error)
+	collect = func(err error) {
+		if multi, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, err := range multi.Unwrap() {
+				collect(err)
+			}
+			return
+		}
+		var perr Error
+		if !errors.As(err, &perr) {
+			out = append(out, JSONError{Message: err.Error()})
+			return
+		}
+		pos := perr.Position()
+		end := pos
+		var uerr *UnexpectedTokenError
+		if errors.As(perr, &uerr) {
+			end.Advance(uerr.Unexpected.Value)
+		}
+		out = append(out, JSONError{
+			Filename:  pos.Filename,
+			Offset:    pos.Offset,
+			Line:      pos.Line,
+			Column:    pos.Column,
+			EndOffset: end.Offset,
+			EndLine:   end.Line,
+			EndColumn: end.Column,
+			Message:   perr.Message(),
+		})
+	}
+	if err != nil {
+		collect(err)
+	}
+	w := &bytes.Buffer{}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(out); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(w.Bytes(), []byte("\n")), nil
+}
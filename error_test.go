@@ -2,6 +2,8 @@ package participle_test
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	require "github.com/alecthomas/assert/v2"
@@ -78,3 +80,211 @@ func TestErrorWrap(t *testing.T) {
 	require.Equal(t, expected, errors.Unwrap(err))
 	require.Equal(t, "1:1: bad: thing: badbad", err.Error())
 }
+
+func TestErrorFormatter(t *testing.T) {
+	type grammar struct {
+		Name  string `"name" @Ident`
+		Value int    `"=" @Int`
+	}
+	source := "name a = b"
+	p := mustTestParser[grammar](t, participle.ErrorFormatter(func(pos lexer.Position, unexpected lexer.Token, expected string) string {
+		return fmt.Sprintf("error: expected %s\n --> %d:%d\n  |\n  | %s\n  | %s^",
+			expected, pos.Line, pos.Column, source, strings.Repeat(" ", pos.Column-1))
+	}))
+	_, err := p.ParseString("", source)
+	require.EqualError(t, err, "error: expected <int>\n --> 1:10\n  |\n  | name a = b\n  |          ^")
+	var uerr *participle.UnexpectedTokenError
+	require.True(t, errors.As(err, &uerr))
+	require.Equal(t, `unexpected token "b" (expected <int>)`, uerr.Message())
+
+	// Parse errors other than unexpected tokens are unaffected.
+	_, err = p.ParseString("", `name a = 99999999999999999999`)
+	require.Error(t, err)
+	require.False(t, strings.HasPrefix(err.Error(), "error:"))
+}
+
+type multiError []error
+
+func (m multiError) Error() string   { return "multiple errors" }
+func (m multiError) Unwrap() []error { return m }
+
+func TestErrorsAsJSON(t *testing.T) {
+	type grammar struct {
+		Name string `"name" @Ident`
+	}
+	p := mustTestParser[grammar](t)
+	_, err := p.ParseString("test.txt", `name "str"`)
+	require.Error(t, err)
+	data, jerr := participle.ErrorsAsJSON(err)
+	require.NoError(t, jerr)
+	require.Equal(t,
+		`[{"filename":"test.txt","offset":5,"line":1,"column":6,"endOffset":10,"endLine":1,"endColumn":11,"message":"unexpected token \"\\\"str\\\"\" (expected <ident>)"}]`,
+		string(data))
+
+	err = multiError{
+		participle.Errorf(lexer.Position{Offset: 1, Line: 1, Column: 2}, "first"),
+		errors.New("second"),
+		fmt.Errorf("third: %w", participle.Errorf(lexer.Position{Offset: 3, Line: 1, Column: 4}, "wrapped")),
+	}
+	data, jerr = participle.ErrorsAsJSON(err)
+	require.NoError(t, jerr)
+	require.Equal(t,
+		`[{"offset":1,"line":1,"column":2,"endOffset":1,"endLine":1,"endColumn":2,"message":"first"},`+
+			`{"offset":0,"line":0,"column":0,"endOffset":0,"endLine":0,"endColumn":0,"message":"second"},`+
+			`{"offset":3,"line":1,"column":4,"endOffset":3,"endLine":1,"endColumn":4,"message":"wrapped"}]`,
+		string(data))
+}
+
+func TestContinueOnError(t *testing.T) {
+	type statement struct {
+		Name  string `@Ident "="`
+		Value int    `@Int ";"`
+	}
+	type grammar struct {
+		Statements []*statement `@@*`
+	}
+	p := mustTestParser[grammar](t)
+	ast, err := p.ParseString("", `a = 1; b = ; c = 3; d e = 5; 6`, participle.ContinueOnError())
+	require.EqualError(t, err, "1:12: unexpected token \";\" (expected <int> \";\")\n"+
+		"1:23: unexpected token \"e\" (expected \"=\" <int> \";\")\n"+
+		"1:30: unexpected token \"6\" (expected Statement)")
+	require.Equal(t, &grammar{Statements: []*statement{{"a", 1}, {"c", 3}, {"e", 5}}}, ast)
+	errs, ok := err.(participle.Errors)
+	require.True(t, ok)
+	require.Equal(t, 3, len(errs))
+
+	ast, err = p.ParseString("", `a = 1; c = 3;`, participle.ContinueOnError())
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Statements: []*statement{{"a", 1}, {"c", 3}}}, ast)
+
+	// Without the option, parsing stops at the first error.
+	_, err = p.ParseString("", `a = 1; b = ; c = 3;`)
+	require.EqualError(t, err, `1:12: unexpected token ";" (expected <int> ";")`)
+
+	// Nested uses of the root production do not continue after errors.
+	type tree struct {
+		Statements []*statement `( @@`
+		Trees      []*tree      `| "{" @@ "}" )*`
+	}
+	tp := mustTestParser[tree](t)
+	nested, err := tp.ParseString("", `a = 1; { b = 2; } c = 3;`, participle.ContinueOnError())
+	require.NoError(t, err)
+	require.Equal(t, &tree{
+		Statements: []*statement{{"a", 1}, {"c", 3}},
+		Trees:      []*tree{{Statements: []*statement{{"b", 2}}}},
+	}, nested)
+	_, err = tp.ParseString("", `a = 1; { b = ; } c = 3;`, participle.ContinueOnError())
+	require.EqualError(t, err, `1:14: unexpected token ";" (expected <int> ";")`)
+
+	// The root repetition can also be a slice grammar.
+	_, err = mustTestParser[[]*statement](t).ParseString("", `a = ; b = 2; c`, participle.ContinueOnError())
+	require.EqualError(t, err, "1:5: unexpected token \";\" (expected <int> \";\")\n"+
+		"1:15: unexpected token \"<EOF>\" (expected \"=\" <int> \";\")")
+}
+
+func TestInsertExpected(t *testing.T) {
+	type statement struct {
+		Name  string `@Ident "="`
+		Value int    `@Int ";"`
+	}
+	type block struct {
+		Open       string       `@"{"`
+		Statements []*statement `@@*`
+		Close      string       `@"}"`
+	}
+	p := mustTestParser[block](t)
+	ast, err := p.ParseString("", `{ a = 1 b = ; c = 3;`, participle.InsertExpected())
+	require.EqualError(t, err, "1:9: unexpected token \"b\" (expected \";\")\n"+
+		"1:13: unexpected token \";\" (expected <int>)\n"+
+		"1:21: unexpected token \"<EOF>\" (expected \"}\")")
+	require.Equal(t, &block{Open: "{", Statements: []*statement{{"a", 1}, {"b", 0}, {"c", 3}}, Close: "}"}, ast)
+	errs, ok := err.(participle.Errors)
+	require.True(t, ok)
+	require.Equal(t, 3, len(errs))
+
+	ast, err = p.ParseString("", `{ a = 1; }`, participle.InsertExpected())
+	require.NoError(t, err)
+	require.Equal(t, &block{Open: "{", Statements: []*statement{{"a", 1}}, Close: "}"}, ast)
+
+	// Only a missing token following a matched token is inserted.
+	_, err = p.ParseString("", `a = 1; }`, participle.InsertExpected())
+	require.EqualError(t, err, `1:1: unexpected token "a"`)
+
+	// Tokens are not inserted into an alternative while a later alternative matches.
+	type declaration struct {
+		Variable string `  @Ident "=" Int ";"`
+		Function string `| @Ident "(" ")" ";"`
+	}
+	type program struct {
+		Declarations []*declaration `@@*`
+	}
+	ast2, err := mustTestParser[program](t).ParseString("", `a = 1; f();`, participle.InsertExpected())
+	require.NoError(t, err)
+	require.Equal(t, &program{Declarations: []*declaration{{Variable: "a"}, {Function: "f"}}}, ast2)
+}
+
+func TestErrorsPositions(t *testing.T) {
+	type statement struct {
+		Name  string `@Ident "="`
+		Value int    `@Int ";"`
+	}
+	type grammar struct {
+		Statements []*statement `@@*`
+	}
+	p := mustTestParser[grammar](t)
+	_, err := p.ParseString("", "a = ;\nb = 2; c = ;", participle.ContinueOnError())
+	errs, ok := err.(participle.Errors)
+	require.True(t, ok)
+	for _, err := range errs {
+		_, ok := err.(participle.Error)
+		require.True(t, ok, "%T", err)
+	}
+	require.Equal(t, []lexer.Position{
+		{Offset: 4, Line: 1, Column: 5},
+		{Offset: 17, Line: 2, Column: 12},
+	}, errs.Positions())
+
+	errs = participle.Errors{errs[1], errors.New("no position"), errs[0]}
+	errs.Sort()
+	require.Equal(t, []lexer.Position{
+		{},
+		{Offset: 4, Line: 1, Column: 5},
+		{Offset: 17, Line: 2, Column: 12},
+	}, errs.Positions())
+}
+
+func TestParseStream(t *testing.T) {
+	type record struct {
+		Name  string `@Ident "="`
+		Value int    `@Int ";"`
+	}
+	p := mustTestParser[record](t)
+	var records []*record
+	collect := func(r *record) error {
+		records = append(records, r)
+		return nil
+	}
+	err := p.ParseStream("", strings.NewReader(`a = 1; b = ; c = 3; d e = 5;`), collect, participle.ContinueOnError())
+	require.EqualError(t, err, "1:12: unexpected token \";\" (expected <int> \";\")\n"+
+		"1:23: unexpected token \"e\" (expected \"=\" <int> \";\")")
+	require.Equal(t, []*record{{"a", 1}, {"c", 3}, {"e", 5}}, records)
+
+	// Without the option, parsing stops at the first error.
+	records = nil
+	err = p.ParseStream("", strings.NewReader(`a = 1; b = ; c = 3;`), collect)
+	require.EqualError(t, err, `1:12: unexpected token ";" (expected <int> ";")`)
+	require.Equal(t, []*record{{"a", 1}}, records)
+
+	// Errors from the callback stop parsing.
+	records = nil
+	err = p.ParseStream("", strings.NewReader(`a = 1; b = 2;`), func(r *record) error {
+		return errors.New("stop")
+	}, participle.ContinueOnError())
+	require.EqualError(t, err, "1:1: stop")
+
+	// Lexing errors stop parsing.
+	records = nil
+	err = p.ParseStream("", strings.NewReader("a = 1; b = 2; `"), collect, participle.ContinueOnError())
+	require.EqualError(t, err, "1:16: literal not terminated")
+	require.Equal(t, []*record{{"a", 1}, {"b", 2}}, records)
+}
@@ -0,0 +1,82 @@
+package participle
+
+// Left-factor common literal prefixes out of disjunctions.
+//
+// That is, the disjunction:
+//
+//	"<" "1" ">" | "<" "2" ">" | "x"
+//
+// Is rewritten to:
+//
+//	"<" ("1" ">" | "2" ">") | "x"
+//
+// Only contiguous alternatives are factored, preserving the order in which alternatives are attempted.
+func leftFactor(n node) {
+	seen := map[node]bool{}
+	var factor func(n node, next func() error) error
+	factor = func(n node, next func() error) error {
+		if seen[n] {
+			return nil
+		}
+		seen[n] = true
+		switch n := n.(type) {
+		case *strct:
+			// The alternative index must be preserved, so only factor within each alternative.
+			if d, ok := n.expr.(*disjunction); ok && n.alternativeFieldIndex != nil {
+				seen[d] = true
+				for _, child := range d.nodes {
+					_ = visit(child, factor)
+				}
+				return nil
+			}
+		case *disjunction:
+			n.nodes = factorAlternatives(n.nodes)
+		}
+		return next()
+	}
+	_ = visit(n, factor)
+}
+
+func factorAlternatives(nodes []node) []node {
+	out := make([]node, 0, len(nodes))
+	for i := 0; i < len(nodes); {
+		prefix := factorablePrefix(nodes[i])
+		j := i + 1
+		for prefix != nil && j < len(nodes) && sameLiteral(prefix, factorablePrefix(nodes[j])) {
+			j++
+		}
+		if j-i < 2 {
+			out = append(out, nodes[i])
+			i++
+			continue
+		}
+		rest := &disjunction{}
+		for _, n := range nodes[i:j] {
+			tail := *n.(*sequence).next
+			tail.head = true
+			if tail.next == nil {
+				rest.nodes = append(rest.nodes, tail.node)
+			} else {
+				rest.nodes = append(rest.nodes, &tail)
+			}
+		}
+		rest.nodes = factorAlternatives(rest.nodes)
+		out = append(out, &sequence{head: true, node: prefix, next: &sequence{node: rest}})
+		i = j
+	}
+	return out
+}
+
+// Returns the uncaptured leading literal of a sequence of at least two nodes, or nil.
+func factorablePrefix(n node) *literal {
+	seq, ok := n.(*sequence)
+	if !ok || seq.next == nil {
+		return nil
+	}
+	lit, _ := seq.node.(*literal)
+	return lit
+}
+
+func sameLiteral(a, b *literal) bool {
+	return b != nil && a.s == b.s && a.t == b.t
+}
@@ -0,0 +1,93 @@
+package participle_test
+
+import (
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+	"github.com/alecthomas/participle/v2"
+)
+
+type leftFactorDisjunction struct {
+	Long1 bool `parser:"  @('<' '1' 'l' 'o' 'n' 'g' '>')"`
+	Long2 bool `parser:"| @('<' '2' 'l' 'o' 'n' 'g' '>')"`
+	Real  bool `parser:"| @('<' 'x' '>')"`
+	Other bool `parser:"| '<' '>' | @'x'"`
+}
+
+type leftFactorDisjunctions struct {
+	List []leftFactorDisjunction `parser:"@@*"`
+}
+
+func TestLeftFactor(t *testing.T) {
+	// Captured alternatives are not factored.
+	parser, err := participle.Build[leftFactorDisjunctions](participle.LeftFactor())
+	require.NoError(t, err)
+	require.Equal(t, `LeftFactorDisjunctions = LeftFactorDisjunction* .
+LeftFactorDisjunction = ("<" "1" "l" "o" "n" "g" ">") | ("<" "2" "l" "o" "n" "g" ">") | ("<" "x" ">") | ("<" ">") | "x" .`, parser.String())
+
+	type grammar struct {
+		A bool `  "<" "a" ">" @"!"?`
+		B bool `| "<" "b" @">"`
+		C bool `| "<" "c" (?= ">") ">"`
+		D bool `| @"<"`
+	}
+	factored, err := participle.Build[grammar](participle.LeftFactor())
+	require.NoError(t, err)
+	require.Equal(t, `Grammar = ("<" (("a" ">" "!"?) | ("b" ">") | ("c" (?= ">") ">"))) | "<" .`, factored.String())
+
+	unfactored := participle.MustBuild[grammar]()
+	for _, input := range []string{`<a>`, `<a>!`, `<b>`, `<c>`, `<`} {
+		expected, err := unfactored.ParseString("", input)
+		require.NoError(t, err)
+		actual, err := factored.ParseString("", input)
+		require.NoError(t, err)
+		require.Equal(t, expected, actual, input)
+	}
+}
+
+func TestLeftFactorReducesLookahead(t *testing.T) {
+	type grammar struct {
+		A bool `  "a" "b" @"c"`
+		B bool `| "a" "b" @"d"`
+	}
+	_, err := participle.MustBuild[grammar]().ParseString("", `a b d`)
+	require.Error(t, err)
+	actual, err := participle.MustBuild[grammar](participle.LeftFactor()).ParseString("", `a b d`)
+	require.NoError(t, err)
+	require.Equal(t, &grammar{B: true}, actual)
+}
+
+func TestLeftFactorPreservesAlternative(t *testing.T) {
+	type grammar struct {
+		Alternative int `alternative:"true"`
+
+		A bool `  "a" @"b"`
+		B bool `| "a" @"c"`
+	}
+	actual, err := participle.MustBuild[grammar](participle.LeftFactor(), participle.UseLookahead(2)).ParseString("", `a c`)
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Alternative: 1, B: true}, actual)
+}
+
+func BenchmarkLeftFactor(b *testing.B) {
+	type Disjunction struct {
+		Long1 bool `parser:"  '<' '1' ' ' 'l' 'o' 'n' 'g' ' ' 'r' 'u' 'l' 'e' '>'"`
+		Long2 bool `parser:"| '<' '2' ' ' 'l' 'o' 'n' 'g' ' ' 'r' 'u' 'l' 'e' '>'"`
+		Long3 bool `parser:"| '<' '3' ' ' 'l' 'o' 'n' 'g' ' ' 'r' 'u' 'l' 'e' '>'"`
+		Real  bool `parser:"| '<' 'x' '>'"`
+	}
+
+	type Disjunctions struct {
+		List []Disjunction `parser:"@@*"`
+	}
+
+	parser := participle.MustBuild[Disjunctions](participle.LeftFactor())
+	input := "<x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x> <x>"
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parser.ParseString("", input); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package participle
+
+import (
+	"sort"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// First returns the FIRST set of the root production: the token types and literals that input
+// matching the grammar can start with.
+//
+// Untyped references such as <ident> contribute their token type, while literals such as "func"
+// contribute their value. Both are sorted. Productions parsed by custom functions, by types
+// implementing Parseable, or by negations can start with tokens that are not included.
+//
+// This is useful for editor completion at the start of input, and for tooling that checks where a
+// grammar may begin.
+func (p *Parser[G]) First() ([]lexer.TokenType, []string) {
+	f := &firstSet{
+		nullable: nullableChecker{memo: map[node]bool{}},
+		visiting: map[node]bool{},
+		types:    map[lexer.TokenType]bool{},
+		literals: map[string]bool{},
+	}
+	f.add(p.typeNodes[p.rootType])
+	types := make([]lexer.TokenType, 0, len(f.types))
+	for tt := range f.types {
+		types = append(types, tt)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	literals := make([]string, 0, len(f.literals))
+	for literal := range f.literals {
+		literals = append(literals, literal)
+	}
+	sort.Strings(literals)
+	return types, literals
+}
+
+type firstSet struct {
+	nullable nullableChecker
+	visiting map[node]bool
+	types    map[lexer.TokenType]bool
+	literals map[string]bool
+}
+
+// Add the tokens n can start with.
+func (f *firstSet) add(n node) {
+	if f.visiting[n] {
+		return
+	}
+	f.visiting[n] = true
+	defer delete(f.visiting, n)
+	switch n := n.(type) {
+	case *strct:
+		f.add(n.expr)
+	case *disjunction:
+		for _, child := range n.nodes {
+			f.add(child)
+		}
+	case *union:
+		for _, member := range n.disjunction.nodes {
+			f.add(member)
+		}
+	case *sequence:
+		for ; n != nil; n = n.next {
+			f.add(n.node)
+			if !f.nullable.check(n.node) && !isAssertion(n.node) {
+				return
+			}
+		}
+	case *capture:
+		f.add(n.node)
+	case *group:
+		f.add(n.expr)
+	case *reference:
+		f.types[n.typ] = true
+	case *literal:
+		if n.s == "" {
+			f.types[n.t] = true
+		} else {
+			f.literals[n.s] = true
+		}
+	case *literalSet:
+		for value := range n.values {
+			f.literals[value] = true
+		}
+	case *balanced:
+		for _, open := range n.open {
+			f.add(open)
+		}
+	}
+}
+
+// Assertions never consume input, although the nullable check treats them as if they do.
+func isAssertion(n node) bool {
+	switch n.(type) {
+	case *lookaheadGroup, *lookbehindGroup, *predicate:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package participle_test
+
+import (
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+func TestFirst(t *testing.T) {
+	type modifier struct {
+		Name string `@("public" | "private")`
+	}
+	type declaration struct {
+		Modifiers []*modifier `@@*`
+		Func      string      `(  "func" @Ident`
+		Var       string      ` | (?= Ident) @Ident ":=" )`
+	}
+	type grammar struct {
+		Package string         `("package" @String)?`
+		Decls   []*declaration `@@*`
+	}
+	parser := mustTestParser[grammar](t)
+	symbols := parser.Lexer().Symbols()
+
+	types, literals := parser.First()
+	require.Equal(t, []lexer.TokenType{symbols["Ident"]}, types)
+	require.Equal(t, []string{"func", "package", "private", "public"}, literals)
+
+	type set struct {
+		Keyword string `@Keyword @Int`
+	}
+	types, literals = mustTestParser[set](t, participle.LiteralSet("Keyword", []string{"b", "a"})).First()
+	require.Equal(t, []lexer.TokenType{}, types)
+	require.Equal(t, []string{"a", "b"}, literals)
+}
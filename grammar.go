@@ -3,6 +3,9 @@ package participle
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"text/scanner"
 
 	"github.com/alecthomas/participle/v2/lexer"
@@ -10,8 +13,21 @@ import (
 
 type generatorContext struct {
 	lexer.Definition
-	typeNodes    map[reflect.Type]node
-	symbolsToIDs map[lexer.TokenType]string
+	typeNodes       map[reflect.Type]node
+	symbolsToIDs    map[lexer.TokenType]string
+	splitLiterals   bool
+	adjacent        map[string]bool // Literals that may match a sequence of adjacent tokens.
+	allowUnexported bool
+	strictStrings   bool
+	duplicateKeys   bool
+	predicates      map[string]func(ctx *ParseContext) bool
+	transforms      map[string]func(string) (string, error)
+	literalSets     map[string]map[string]bool
+	valueRegistries map[reflect.Type]*valueRegistry
+	rawValues       bool // Whether values of tokens before mapping are used, eg. by `raw:"<field>"` tags.
+	backreferences  bool // Whether the grammar contains any `=<field>` backreference.
+	lookahead       int  // Depth of lookahead groups being parsed.
+	productionRefs  []*productionRef
 }
 
 func newGeneratorContext(lex lexer.Definition) *generatorContext {
@@ -43,16 +59,38 @@ func (g *generatorContext) addUnionDefs(defs []unionDef) error {
 			}
 			unionNode.disjunction.nodes = append(unionNode.disjunction.nodes, memberNode)
 		}
+		if def.keys != nil {
+			g.addUnionKeys(unionNode)
+		}
 	}
 	return nil
 }
 
+// Resolve the first tokens selecting each member of a union defined with UnionByToken().
+func (g *generatorContext) addUnionKeys(u *union) {
+	symbols := g.Symbols()
+	u.byValue = map[string]int{}
+	u.byType = map[lexer.TokenType]int{}
+	expected := make([]string, 0, len(u.keys))
+	for key, i := range u.keys {
+		if tt, ok := symbols[key]; ok {
+			u.byType[tt] = i
+			expected = append(expected, "<"+strings.ToLower(key)+">")
+		} else {
+			u.byValue[key] = i
+			expected = append(expected, strconv.Quote(key))
+		}
+	}
+	sort.Strings(expected)
+	u.expected = strings.Join(expected, " | ")
+}
+
 func (g *generatorContext) addCustomDefs(defs []customDef) error {
 	for _, def := range defs {
 		if _, exists := g.typeNodes[def.typ]; exists {
 			return fmt.Errorf("duplicate definition for interface or union type %s", def.typ)
 		}
-		g.typeNodes[def.typ] = &custom{typ: def.typ, parseFn: def.parseFn}
+		g.typeNodes[def.typ] = &custom{typ: def.typ, parseFn: def.parseFn, withContext: def.withContext}
 	}
 	return nil
 }
@@ -73,7 +111,7 @@ func (g *generatorContext) parseType(t reflect.Type) (_ node, returnedError erro
 		return &parseable{t}, nil
 	}
 	switch t.Kind() { // nolint: exhaustive
-	case reflect.Slice, reflect.Ptr:
+	case reflect.Slice, reflect.Ptr, reflect.Map:
 		t = indirectType(t.Elem())
 		if t.Kind() != reflect.Struct {
 			return nil, fmt.Errorf("expected a struct but got %T", t)
@@ -81,12 +119,15 @@ func (g *generatorContext) parseType(t reflect.Type) (_ node, returnedError erro
 		fallthrough
 
 	case reflect.Struct:
-		slexer, err := lexStruct(t)
+		slexer, err := lexStruct(t, g.allowUnexported)
 		if err != nil {
 			return nil, err
 		}
 		out := newStrct(t)
 		g.typeNodes[t] = out // Ensure we avoid infinite recursion.
+		if out.endPosFieldIndex != nil {
+			g.rawValues = true // EndPos follows the token as it appeared in the input.
+		}
 		if slexer.NumField() == 0 {
 			return nil, fmt.Errorf("can not parse into empty struct %s", t)
 		}
@@ -103,6 +144,10 @@ func (g *generatorContext) parseType(t reflect.Type) (_ node, returnedError erro
 		}
 		out.expr = e
 		return out, nil
+
+	case reflect.Interface:
+		// Registered unions are already in typeNodes.
+		return nil, fmt.Errorf("interface type %s requires participle.Union[%s](...)", t, t)
 	}
 	return nil, fmt.Errorf("%s should be a struct or should implement the Parseable interface", t)
 }
@@ -149,12 +194,22 @@ loop:
 		if term == nil {
 			break loop
 		}
-		if cursor.node == nil {
-			cursor.head = true
-			cursor.node = term
-		} else {
-			cursor.next = &sequence{node: term}
-			cursor = cursor.next
+		// Splice in sequences, such as those produced by split literals.
+		terms := []node{term}
+		if seq, ok := term.(*sequence); ok {
+			terms = terms[:0]
+			for ; seq != nil; seq = seq.next {
+				terms = append(terms, seq.node)
+			}
+		}
+		for _, term := range terms {
+			if cursor.node == nil {
+				cursor.head = true
+				cursor.node = term
+			} else {
+				cursor.next = &sequence{node: term}
+				cursor = cursor.next
+			}
 		}
 	}
 	if head.node == nil {
@@ -178,6 +233,10 @@ func (g *generatorContext) parseTermNoModifiers(slexer *structLexer, allowUnknow
 		return g.parseLiteral(slexer)
 	case '!', '~':
 		return g.parseNegation(slexer)
+	case '&':
+		return g.parsePredicate(slexer)
+	case '=':
+		return g.parseBackreference(slexer)
 	case '[':
 		return g.parseOptional(slexer)
 	case '{':
@@ -206,7 +265,7 @@ func (g *generatorContext) parseTerm(slexer *structLexer, allowUnknown bool) (no
 	return g.parseModifier(slexer, out)
 }
 
-// Parse modifiers: ?, *, + and/or !
+// Parse modifiers: ?, *, +, !, !:unique and/or !:min(n)
 func (g *generatorContext) parseModifier(slexer *structLexer, expr node) (node, error) {
 	out := &group{expr: expr}
 	t, err := slexer.Peek()
@@ -215,11 +274,41 @@ func (g *generatorContext) parseModifier(slexer *structLexer, expr node) (node,
 	}
 	switch t.Type {
 	case '!':
-		out.mode = groupMatchNonEmpty
+		_, _ = slexer.Next()
+		if t, err = slexer.Peek(); err != nil {
+			return nil, err
+		}
+		// Named modifiers are delimited by ":", so a term following "!" is never mistaken for one.
+		if t.Type != ':' {
+			out.mode = groupMatchNonEmpty
+			return out, nil
+		}
+		_, _ = slexer.Next()
+		if t, err = slexer.Peek(); err != nil {
+			return nil, err
+		}
+		if t.Type != scanner.Ident || (t.Value != "unique" && t.Value != "min") {
+			return nil, fmt.Errorf("expected unique or min after !: but got %q", t)
+		}
+		// The contents of a wrapping group or repetition are matched directly.
+		if inner, ok := expr.(*group); ok && (inner.mode == groupMatchOnce || inner.mode == groupMatchZeroOrMore ||
+			(t.Value == "min" && inner.mode == groupMatchOneOrMore)) {
+			out.expr = inner.expr
+		}
+		if t.Value == "min" {
+			_, _ = slexer.Next()
+			out.mode = groupMatchAtLeast
+			return out, g.parseMinArgs(slexer, out)
+		}
+		out.mode = groupMatchUnique
 	case '+':
 		out.mode = groupMatchOneOrMore
+		_, _ = slexer.Next()
+		return out, g.parseSeparator(slexer, out)
 	case '*':
 		out.mode = groupMatchZeroOrMore
+		_, _ = slexer.Next()
+		return out, g.parseSeparator(slexer, out)
 	case '?':
 		out.mode = groupMatchZeroOrOne
 	default:
@@ -229,6 +318,143 @@ func (g *generatorContext) parseModifier(slexer *structLexer, expr node) (node,
 	return out, nil
 }
 
+// Parse the optional separator of a repetition: *%<sep>, +%<sep>, or with a trailing ? to allow a trailing separator.
+func (g *generatorContext) parseSeparator(slexer *structLexer, out *group) error {
+	if t, err := slexer.Peek(); err != nil || t.Type != '%' {
+		return err
+	}
+	_, _ = slexer.Next()
+	sep, err := g.parseTermNoModifiers(slexer, false)
+	if err != nil {
+		return err
+	}
+	if sep == nil {
+		return fmt.Errorf("expected separator after %%")
+	}
+	out.sep = sep
+	if t, _ := slexer.Peek(); t.Type == '?' {
+		_, _ = slexer.Next()
+		out.trailing = true
+	}
+	return nil
+}
+
+// &<name> calls a predicate registered with Predicate().
+func (g *generatorContext) parsePredicate(slexer *structLexer) (node, error) {
+	_, _ = slexer.Next()
+	token, err := slexer.Next()
+	if err != nil {
+		return nil, err
+	}
+	if token.Type != scanner.Ident {
+		return nil, fmt.Errorf("expected predicate name but got %q", token)
+	}
+	fn, ok := g.predicates[token.Value]
+	if !ok {
+		return nil, fmt.Errorf("unknown predicate %q", token.Value)
+	}
+	return &predicate{name: token.Value, fn: fn}, nil
+}
+
+// @@:<production> in a lookahead group matches a production other than the field's type.
+func (g *generatorContext) parseProductionRef(slexer *structLexer) (node, error) {
+	_, _ = slexer.Next()
+	token, err := slexer.Next()
+	if err != nil {
+		return nil, err
+	}
+	if token.Type != scanner.Ident {
+		return nil, fmt.Errorf("expected production name but got %q", token)
+	}
+	if g.lookahead == 0 {
+		return nil, fmt.Errorf("@@:%s can only be used in a lookahead group", token.Value)
+	}
+	ref := &productionRef{name: token.Value}
+	g.productionRefs = append(g.productionRefs, ref)
+	return ref, nil
+}
+
+// Resolve @@:<production> references once all productions are known, by type or production name.
+func (g *generatorContext) resolveProductionRefs() error {
+	for _, ref := range g.productionRefs {
+		for t, n := range g.typeNodes {
+			// Anonymous types can not be referred to by name.
+			if t.Name() == "" || (t.Name() != ref.name && productionName(t) != ref.name) {
+				continue
+			}
+			if ref.node != nil && ref.node != n {
+				return fmt.Errorf("@@:%s is ambiguous", ref.name)
+			}
+			ref.node = n
+		}
+		if ref.node == nil {
+			return fmt.Errorf("@@:%s does not refer to a production of the grammar", ref.name)
+		}
+	}
+	return nil
+}
+
+func (g *generatorContext) parseBackreference(slexer *structLexer) (node, error) {
+	_, _ = slexer.Next()
+	token, err := slexer.Next()
+	if err != nil {
+		return nil, err
+	}
+	if token.Type != scanner.Ident {
+		return nil, fmt.Errorf("expected field name but got %q", token)
+	}
+	field, ok := slexer.s.FieldByName(token.Value)
+	if !ok || field.Type.Kind() != reflect.String {
+		return nil, fmt.Errorf("backreference %q must be to a string field of %s", token.Value, slexer.s)
+	}
+	g.backreferences = true
+	return &backreference{field: structLexerField{StructField: field, Index: field.Index}}, nil
+}
+
+// Parse the arguments of !min(<n>) or !min(<n>, "<message>").
+func (g *generatorContext) parseMinArgs(slexer *structLexer, out *group) error {
+	next, err := slexer.Next()
+	if err != nil {
+		return err
+	}
+	if next.Type != '(' {
+		return fmt.Errorf("expected ( but got %q", next)
+	}
+	next, err = slexer.Next()
+	if err != nil {
+		return err
+	}
+	if next.Type != scanner.Int {
+		return fmt.Errorf("expected minimum count but got %q", next)
+	}
+	out.min, err = strconv.Atoi(next.Value)
+	if err != nil || out.min < 1 {
+		return fmt.Errorf("minimum count must be a positive integer but got %q", next)
+	}
+	next, err = slexer.Next()
+	if err != nil {
+		return err
+	}
+	if next.Type == ',' {
+		next, err = slexer.Next()
+		if err != nil {
+			return err
+		}
+		if next.Type != scanner.String && next.Type != scanner.RawString && next.Type != scanner.Char {
+			return fmt.Errorf("expected error message but got %q", next)
+		}
+		out.message = next.Value
+		next, err = slexer.Next()
+		if err != nil {
+			return err
+		}
+	}
+	if next.Type != ')' {
+		return fmt.Errorf("expected ) but got %q", next)
+	}
+	return nil
+}
+
 // @<expression> captures <expression> into the current field.
 func (g *generatorContext) parseCapture(slexer *structLexer) (node, error) {
 	_, _ = slexer.Next()
@@ -236,24 +462,212 @@ func (g *generatorContext) parseCapture(slexer *structLexer) (node, error) {
 	if err != nil {
 		return nil, err
 	}
-	field := slexer.Field()
+	field, err := captureField(slexer, slexer.Field())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkUniqueTag(field); err != nil {
+		return nil, err
+	}
+	if err := checkRuneTag(field); err != nil {
+		return nil, err
+	}
+	raw, err := g.rawField(slexer, field)
+	if err != nil {
+		return nil, err
+	}
+	transforms, err := g.fieldTransforms(field)
+	if err != nil {
+		return nil, err
+	}
+	if field.Type.Kind() == reflect.Map {
+		if token.Type != '@' {
+			return nil, fmt.Errorf("map fields can only be captured with @@, not %s", field.Type)
+		}
+		if err := checkMapField(field); err != nil {
+			return nil, err
+		}
+		field.DuplicateKeys = g.duplicateKeys
+	}
 	if token.Type == '@' {
 		_, _ = slexer.Next()
+		if token, _ := slexer.Peek(); token.Type == ':' {
+			return g.parseProductionRef(slexer)
+		}
+		if transforms != nil {
+			return nil, fmt.Errorf("transform tag can not be used with @@")
+		}
 		n, err := g.parseType(field.Type)
 		if err != nil {
 			return nil, err
 		}
-		return &capture{field, n}, nil
+		return &capture{field: field, node: n, skip: skipField(field), raw: raw}, nil
 	}
 	ft := indirectType(field.Type)
-	if ft.Kind() == reflect.Struct && ft != tokenType && ft != tokensType && !implements(ft, captureType) && !implements(ft, textUnmarshalerType) {
-		return nil, fmt.Errorf("%s: structs can only be parsed with @@ or by implementing the Capture or encoding.TextUnmarshaler interfaces", ft)
+	if ft.Kind() == reflect.Struct && ft != tokenType && ft != tokensType && !implements(ft, captureType) &&
+		!implements(ft, tokenCaptureType) && !implements(ft, textUnmarshalerType) {
+		return nil, fmt.Errorf("%s: structs can only be parsed with @@ or by implementing the Capture, TokenCapture or encoding.TextUnmarshaler interfaces", ft)
 	}
 	n, err := g.parseTermNoModifiers(slexer, false)
 	if err != nil {
 		return nil, err
 	}
-	return &capture{field, n}, nil
+	if b, ok := n.(*balanced); ok {
+		if transforms != nil {
+			return nil, fmt.Errorf("transform tag can not be used with Balanced()")
+		}
+		// Only the tokens between the delimiters are captured.
+		b.field = &field
+		return b, nil
+	}
+	registry := g.valueRegistries[ft]
+	if registry == nil && ft.Kind() == reflect.Func {
+		return nil, fmt.Errorf("no FuncRegistry for %s", ft)
+	}
+	if registry != nil && registry.flags && field.Type.Kind() != reflect.Slice {
+		field.Flags = true
+	}
+	if g.strictStrings && ft.Kind() == reflect.String && field.Type.Kind() != reflect.Slice &&
+		!implements(ft, captureType) && !implements(ft, tokenCaptureType) && !implements(ft, textUnmarshalerType) {
+		field.Strict = true
+	}
+	return &capture{field: field, node: n, registry: registry, transforms: transforms, skip: skipField(field), raw: raw}, nil
+}
+
+// The transforms named by the `transform:"<name>,..."` tag of field, in the order they are applied.
+func (g *generatorContext) fieldTransforms(field structLexerField) ([]func(string) (string, error), error) {
+	tag := field.Tag.Get("transform")
+	if tag == "" {
+		return nil, nil
+	}
+	transforms := []func(string) (string, error){}
+	for _, name := range strings.Split(tag, ",") {
+		transform, ok := g.transforms[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q", strings.TrimSpace(name))
+		}
+		transforms = append(transforms, transform)
+	}
+	return transforms, nil
+}
+
+// Resolve the field a capture populates, which is the sibling named by the `into:"<field>"` tag if
+// present. This allows multiple positions in the grammar to capture into the same field.
+func captureField(slexer *structLexer, field structLexerField) (structLexerField, error) {
+	site := field.Index
+	if name := field.Tag.Get("into"); name != "" {
+		target, ok := slexer.s.FieldByName(name)
+		if !ok || name == "_" || target.Tag.Get("into") != "" {
+			return field, fmt.Errorf("into field %q must be a capturing field of %s", name, slexer.s)
+		}
+		field = structLexerField{StructField: target, Index: target.Index}
+	}
+	if field.Type.Kind() != reflect.Slice {
+		for i := 0; i < slexer.s.NumField(); i++ {
+			if slexer.s.Field(i).Tag.Get("into") == field.Name {
+				field.Exclusive = true
+				field.Site = site
+			}
+		}
+	}
+	return field, nil
+}
+
+// Resolve the sibling string field named by the `raw:"<field>"` tag, if any, which receives the
+// captured text as it was before mapping (eg. by Unquote()).
+func (g *generatorContext) rawField(slexer *structLexer, field structLexerField) (*structLexerField, error) {
+	name := field.Tag.Get("raw")
+	if name == "" {
+		return nil, nil
+	}
+	sibling, ok := slexer.s.FieldByName(name)
+	if !ok || sibling.Type.Kind() != reflect.String {
+		return nil, fmt.Errorf("raw field %q must be a string field of %s", name, slexer.s)
+	}
+	g.rawValues = true
+	return &structLexerField{StructField: sibling, Index: sibling.Index}, nil
+}
+
+// Validate the `rune:"true"` tag, which requires a rune or slice of runes.
+func checkRuneTag(field structLexerField) error {
+	if field.Tag.Get("rune") != "true" {
+		return nil
+	}
+	t := field.Type
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Int32 {
+		return fmt.Errorf("rune tag requires a rune or []rune field, not %s", field.Type)
+	}
+	return nil
+}
+
+// Validate the `unique:"<key>"` tag, which must name a comparable field of the slice's element struct.
+func checkUniqueTag(field structLexerField) error {
+	key := field.Tag.Get("unique")
+	if key == "" {
+		return nil
+	}
+	if field.Type.Kind() != reflect.Slice || indirectType(field.Type.Elem()).Kind() != reflect.Struct {
+		return fmt.Errorf("unique tag requires a slice of structs, not %s", field.Type)
+	}
+	elem := indirectType(field.Type.Elem())
+	kf, ok := elem.FieldByName(key)
+	if !ok {
+		return fmt.Errorf("unique key %q is not a comparable field of %s", key, elem)
+	}
+	// Pointer keys are compared by the values they point to.
+	kt := kf.Type
+	for kt.Kind() == reflect.Ptr {
+		kt = kt.Elem()
+	}
+	if !kt.Comparable() {
+		return fmt.Errorf("unique key %q is not a comparable field of %s", key, elem)
+	}
+	return nil
+}
+
+// Validate a map field, whose values must be structs providing their key with a MapKey() method or
+// a field tagged `mapkey:"true"`.
+func checkMapField(field structLexerField) error {
+	elem := indirectType(field.Type.Elem())
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("map values must be structs, not %s", field.Type.Elem())
+	}
+	keyType, ok := mapKeyType(elem)
+	if !ok {
+		return fmt.Errorf("%s must have a MapKey() method or a field tagged mapkey:\"true\" to be a map value", elem)
+	}
+	if !keyType.ConvertibleTo(field.Type.Key()) {
+		return fmt.Errorf("map key %s of %s is not convertible to %s", keyType, elem, field.Type.Key())
+	}
+	return nil
+}
+
+// The type of the key of a map value struct, if it has one.
+func mapKeyType(t reflect.Type) (reflect.Type, bool) {
+	if method, ok := reflect.PtrTo(t).MethodByName("MapKey"); ok && method.Type.NumIn() == 1 && method.Type.NumOut() == 1 {
+		return method.Type.Out(0), true
+	}
+	if index := mapKeyField(t); index != nil {
+		return t.FieldByIndex(index).Type, true
+	}
+	return nil, false
+}
+
+func mapKeyField(t reflect.Type) []int {
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.Tag.Get("mapkey") == "true" && field.PkgPath == "" {
+			return field.Index
+		}
+	}
+	return nil
+}
+
+// Reports whether captures into the field should be discarded, via the `skip:"true"` tag.
+func skipField(field structLexerField) bool {
+	return field.Tag.Get("skip") == "true"
 }
 
 // A reference in the form <identifier> refers to a named token from the lexer.
@@ -267,11 +681,65 @@ func (g *generatorContext) parseReference(slexer *structLexer) (node, error) { /
 	}
 	typ, ok := g.Symbols()[token.Value]
 	if !ok {
+		if values, ok := g.literalSets[token.Value]; ok {
+			return &literalSet{name: token.Value, values: values}, nil
+		}
+		if token.Value == "Balanced" {
+			return g.parseBalanced(slexer)
+		}
 		return nil, fmt.Errorf("unknown token type %q", token)
 	}
 	return &reference{typ: typ, identifier: token.Value}, nil
 }
 
+// Balanced("<open>", "<close>", ...) matches a region delimited by any of the pairs of literals.
+func (g *generatorContext) parseBalanced(slexer *structLexer) (node, error) {
+	next, err := slexer.Next()
+	if err != nil {
+		return nil, err
+	}
+	if next.Type != '(' {
+		return nil, fmt.Errorf("expected ( after Balanced but got %q", next)
+	}
+	delimiters := []*literal{}
+	for {
+		next, err = slexer.Peek()
+		if err != nil {
+			return nil, err
+		}
+		if next.Type != scanner.String && next.Type != scanner.RawString && next.Type != scanner.Char {
+			return nil, fmt.Errorf("expected delimiter literal but got %q", next)
+		}
+		n, err := g.parseLiteral(slexer)
+		if err != nil {
+			return nil, err
+		}
+		lit, ok := n.(*literal)
+		if !ok {
+			return nil, fmt.Errorf("delimiter %s must be a single literal", n)
+		}
+		delimiters = append(delimiters, lit)
+		if next, err = slexer.Next(); err != nil {
+			return nil, err
+		}
+		if next.Type == ')' {
+			break
+		}
+		if next.Type != ',' {
+			return nil, fmt.Errorf("expected , or ) but got %q", next)
+		}
+	}
+	if len(delimiters)%2 != 0 {
+		return nil, fmt.Errorf("Balanced() requires pairs of open and close delimiters")
+	}
+	out := &balanced{}
+	for i := 0; i < len(delimiters); i += 2 {
+		out.open = append(out.open, delimiters[i])
+		out.close = append(out.close, delimiters[i+1])
+	}
+	return out, nil
+}
+
 // [ <expression> ] optionally matches <expression>.
 func (g *generatorContext) parseOptional(slexer *structLexer) (node, error) {
 	_, _ = slexer.Next() // [
@@ -316,7 +784,7 @@ func (g *generatorContext) parseGroup(slexer *structLexer) (node, error) {
 		return nil, err
 	}
 	if peek.Type == '?' {
-		return g.subparseLookaheadGroup(slexer) // If there was an error peeking, code below will handle it
+		return g.subparseLookaroundGroup(slexer) // If there was an error peeking, code below will handle it
 	}
 	expr, err := g.subparseGroup(slexer)
 	if err != nil {
@@ -326,13 +794,21 @@ func (g *generatorContext) parseGroup(slexer *structLexer) (node, error) {
 }
 
 // (?[!=] <expression> ) requires a grouped sub-expression either matches or doesn't match, without consuming it
-func (g *generatorContext) subparseLookaheadGroup(slexer *structLexer) (node, error) {
+//
+// (?<[!=] <expression> ) requires the previous token either matches or doesn't match a single-token sub-expression
+func (g *generatorContext) subparseLookaroundGroup(slexer *structLexer) (node, error) {
 	_, _ = slexer.Next() // ? - the opening ( was already consumed in parseGroup
 	var negative bool
 	next, err := slexer.Next()
 	if err != nil {
 		return nil, err
 	}
+	lookbehind := next.Type == '<'
+	if lookbehind {
+		if next, err = slexer.Next(); err != nil {
+			return nil, err
+		}
+	}
 	switch next.Type {
 	case '=':
 		negative = false
@@ -341,13 +817,43 @@ func (g *generatorContext) subparseLookaheadGroup(slexer *structLexer) (node, er
 	default:
 		return nil, fmt.Errorf("expected = or ! but got %q", next)
 	}
+	if !lookbehind {
+		g.lookahead++
+		defer func() { g.lookahead-- }()
+	}
 	expr, err := g.subparseGroup(slexer)
 	if err != nil {
 		return nil, err
 	}
+	if lookbehind {
+		if err := checkSingleToken(expr); err != nil {
+			return nil, err
+		}
+		return &lookbehindGroup{expr: expr, negative: negative}, nil
+	}
 	return &lookaheadGroup{expr: expr, negative: negative}, nil
 }
 
+// Checks that a lookbehind expression only matches a single token.
+func checkSingleToken(n node) error {
+	switch n := n.(type) {
+	case *literal, *reference:
+		return nil
+	case *disjunction:
+		for _, child := range n.nodes {
+			if err := checkSingleToken(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *group:
+		if n.mode == groupMatchOnce {
+			return checkSingleToken(n.expr)
+		}
+	}
+	return fmt.Errorf("lookbehind can only contain literals and token references, not %s", n)
+}
+
 // helper parsing <expression> ) to finish parsing groups or lookahead groups
 func (g *generatorContext) subparseGroup(slexer *structLexer) (node, error) {
 	disj, err := g.parseDisjunction(slexer)
@@ -406,7 +912,20 @@ func (g *generatorContext) parseLiteral(lex *structLexer) (node, error) { // nol
 			return nil, fmt.Errorf("unknown token type %q in literal type constraint", token)
 		}
 	}
-	return &literal{s: s, t: t, tt: g.symbolsToIDs[t]}, nil
+	if words := strings.Fields(s); g.splitLiterals && t == lexer.EOF && len(words) > 1 {
+		head := &sequence{head: true, node: &literal{s: words[0], t: t, tt: g.symbolsToIDs[t]}}
+		cursor := head
+		for _, word := range words[1:] {
+			cursor.next = &sequence{node: &literal{s: word, t: t, tt: g.symbolsToIDs[t]}}
+			cursor = cursor.next
+		}
+		return head, nil
+	}
+	adjacent := t == lexer.EOF && g.adjacent[s]
+	if adjacent {
+		g.rawValues = true // Adjacency is determined by the tokens as they appeared in the input.
+	}
+	return &literal{s: s, t: t, tt: g.symbolsToIDs[t], adjacent: adjacent}, nil
 }
 
 func indirectType(t reflect.Type) reflect.Type {
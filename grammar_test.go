@@ -61,3 +61,11 @@ func TestBuild_Colon_MissingTokenType(t *testing.T) {
 	_, err := participle.Build[grammar]()
 	require.EqualError(t, err, `Key: expected identifier for literal type constraint but got "@"`)
 }
+
+func TestBuild_Errors_LookbehindGroup(t *testing.T) {
+	type grammar struct {
+		Whatever string `(?<= 'a' 'b') @Ident`
+	}
+	_, err := participle.Build[grammar]()
+	require.EqualError(t, err, `Whatever: lookbehind can only contain literals and token references, not "a" "b"`)
+}
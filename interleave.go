@@ -0,0 +1,73 @@
+package participle
+
+import (
+	"reflect"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// A production registered with Interleave().
+type interleaveDef struct {
+	typ  reflect.Type // T
+	node node
+}
+
+// Remove interleaved productions from the token stream, appending them to the sinks passed with
+// InterleaveInto(). Matches without a sink are discarded.
+//
+// Elided tokens preceding a match are retained.
+func (p *parserOptions) removeInterleaved(lex *lexer.PeekingLexer, elide []lexer.TokenType, sinks map[reflect.Type]reflect.Value) (*lexer.PeekingLexer, error) {
+	tokens := []lexer.Token{}
+	start := lex.RawCursor()
+	for {
+		_, next := lex.PeekAny(func(lexer.Token) bool { return false })
+		if lex.Peek().EOF() {
+			tokens = append(tokens, lex.Range(start, next+1)...)
+			break
+		}
+		matched, err := p.parseInterleaved(lex, sinks)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			tokens = append(tokens, lex.Range(start, next)...)
+			start = lex.RawCursor()
+			continue
+		}
+		lex.Next()
+	}
+	return lexer.Upgrade(&tokenSliceLexer{tokens: tokens}, elide...)
+}
+
+// Attempt each interleaved production at the current token.
+func (p *parserOptions) parseInterleaved(lex *lexer.PeekingLexer, sinks map[reflect.Type]reflect.Value) (bool, error) {
+	for _, def := range p.interleave {
+		ctx := newParseContext(lex, p.useLookahead, p.caseInsensitiveTokens, p.literalMatchTokens)
+		start := ctx.Cursor()
+		pv, err := def.node.Parse(&ctx, reflect.Value{})
+		if err != nil {
+			return false, err
+		}
+		if len(pv) == 0 || ctx.Cursor() == start {
+			continue
+		}
+		*lex = ctx.PeekingLexer
+		if sink, ok := sinks[def.typ]; ok {
+			sink.Set(reflect.Append(sink, maybeRef(def.typ, pv[0])))
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+type tokenSliceLexer struct {
+	tokens []lexer.Token
+}
+
+func (t *tokenSliceLexer) Next() (lexer.Token, error) {
+	token := t.tokens[0]
+	if !token.EOF() {
+		t.tokens = t.tokens[1:]
+	}
+	return token, nil
+}
@@ -0,0 +1,65 @@
+package participle_test
+
+import (
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+func TestInterleave(t *testing.T) {
+	type pragma struct {
+		Pos  lexer.Position
+		Name string `"#" "pragma" @Ident`
+	}
+	type assignment struct {
+		Name  string `@Ident "="`
+		Value int    `@Int ";"`
+	}
+	type grammar struct {
+		Assignments []*assignment `@@*`
+	}
+	parser := mustTestParser[grammar](t, participle.Interleave[*pragma]())
+
+	var pragmas []*pragma
+	actual, err := parser.ParseString("", `a = #pragma x 1; # pragma y b = 2; #pragma z`, participle.InterleaveInto(&pragmas))
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Assignments: []*assignment{
+		{Name: "a", Value: 1},
+		{Name: "b", Value: 2},
+	}}, actual)
+	require.Equal(t, []*pragma{
+		{Pos: lexer.Position{Offset: 4, Line: 1, Column: 5}, Name: "x"},
+		{Pos: lexer.Position{Offset: 17, Line: 1, Column: 18}, Name: "y"},
+		{Pos: lexer.Position{Offset: 35, Line: 1, Column: 36}, Name: "z"},
+	}, pragmas)
+
+	// Each parse has its own sink, and matches are discarded without one.
+	var other []*pragma
+	_, err = parser.ParseString("", `#pragma w a = 1;`, participle.InterleaveInto(&other))
+	require.NoError(t, err)
+	require.Equal(t, []*pragma{{Pos: lexer.Position{Line: 1, Column: 1}, Name: "w"}}, other)
+	require.Equal(t, 3, len(pragmas))
+	_, err = parser.ParseString("", `#pragma v a = 1;`)
+	require.NoError(t, err)
+
+	// A partially matched production is an error.
+	_, err = parser.ParseString("", `a = 1; #pragma 2`)
+	require.EqualError(t, err, `1:16: unexpected token "2" (expected <ident>)`)
+	_, err = parser.ParseString("", `a = 1; # b = 2;`)
+	require.EqualError(t, err, `1:10: unexpected token "b" (expected "pragma" <ident>)`)
+
+	// Tokens that don't start the production are left in place.
+	_, err = parser.ParseString("", `a = 1; = 2;`)
+	require.EqualError(t, err, `1:8: unexpected token "="`)
+}
+
+func TestInterleaveInvalidProduction(t *testing.T) {
+	type grammar struct {
+		Name string `@Ident`
+	}
+	_, err := participle.Build[grammar](participle.Interleave[string]())
+	require.EqualError(t, err, "Interleave(): production must be a struct or pointer to a struct, not string")
+}
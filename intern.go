@@ -0,0 +1,61 @@
+package participle
+
+import (
+	"reflect"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Pool of token values for InternTokens(), shared by the lexer and the parse.
+type internPool struct {
+	strings map[string]string
+	values  map[string]reflect.Value
+}
+
+func newInternPool() *internPool {
+	return &internPool{strings: map[string]string{}, values: map[string]reflect.Value{}}
+}
+
+func (i *internPool) intern(s string) string {
+	if interned, ok := i.strings[s]; ok {
+		return interned
+	}
+	i.strings[s] = s
+	return s
+}
+
+func (i *internPool) reset() {
+	i.strings = map[string]string{}
+	i.values = map[string]reflect.Value{}
+}
+
+// The captured value of s, allocated once per distinct value.
+func (i *internPool) value(s string) reflect.Value {
+	if v, ok := i.values[s]; ok {
+		return v
+	}
+	v := reflect.ValueOf(i.intern(s))
+	i.values[s] = v
+	return v
+}
+
+type internLexer struct {
+	lexer.Lexer
+	pool *internPool
+}
+
+func (l *internLexer) Next() (lexer.Token, error) {
+	token, err := l.Lexer.Next()
+	token.Value = l.pool.intern(token.Value)
+	return token, err
+}
+
+// Intern the values of tokens from lex with InternTokens(), sharing the pool with the parse.
+func (p *Parser[G]) intern(lex lexer.Lexer, options []ParseOption) (lexer.Lexer, []ParseOption, *internPool) {
+	if !p.internTokens {
+		return lex, options, nil
+	}
+	pool := newInternPool()
+	options = appendOptions(options, func(p *parseContext) { p.interned = pool })
+	return &internLexer{Lexer: lex, pool: pool}, options, pool
+}
@@ -0,0 +1,75 @@
+//go:build go1.20
+
+package participle_test
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
+)
+
+type internInsert struct {
+	Table   string   `"insert" "into" @Ident`
+	Columns []string `"(" @Ident ("," @Ident)* ")"`
+	Values  []string `"values" "(" (@Ident | @Int) ("," (@Ident | @Int))* ")" ";"`
+}
+
+type internInserts struct {
+	Inserts []*internInsert `@@*`
+}
+
+func TestInternTokens(t *testing.T) {
+	parser := mustTestParser[internInserts](t, participle.InternTokens())
+	source := strings.Repeat("insert into users (id, name) values (1, alice);\n", 2)
+	actual, err := parser.ParseString("", source)
+	require.NoError(t, err)
+	require.Equal(t, &internInserts{Inserts: []*internInsert{
+		{Table: "users", Columns: []string{"id", "name"}, Values: []string{"1", "alice"}},
+		{Table: "users", Columns: []string{"id", "name"}, Values: []string{"1", "alice"}},
+	}}, actual)
+	require.Equal(t, stringData(actual.Inserts[0].Table), stringData(actual.Inserts[1].Table))
+	require.Equal(t, stringData(actual.Inserts[0].Values[1]), stringData(actual.Inserts[1].Values[1]))
+}
+
+func TestInternTokensStream(t *testing.T) {
+	parser := mustTestParser[internInsert](t, participle.InternTokens())
+	source := strings.Repeat("insert into users (id, name) values (1, alice);\n", 3)
+	inserts := []*internInsert{}
+	err := parser.ParseStream("", strings.NewReader(source), func(insert *internInsert) error {
+		inserts = append(inserts, insert)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, len(inserts))
+	require.Equal(t, []string{"1", "alice"}, inserts[2].Values)
+}
+
+// The address of the bytes of s.
+func stringData(s string) *byte {
+	return unsafe.StringData(s)
+}
+
+func BenchmarkInternTokens(b *testing.B) {
+	source := strings.Repeat("insert into users (id, name, email) values (1, alice, example);\n", 1000)
+	for _, bench := range []struct {
+		name    string
+		options []participle.Option
+	}{
+		{"Default", nil},
+		{"InternTokens", []participle.Option{participle.InternTokens()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			parser := participle.MustBuild[internInserts](bench.options...)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := parser.ParseString("", source); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
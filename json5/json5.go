@@ -0,0 +1,216 @@
+// Package json5 provides a parser for JSON5, a superset of JSON that is also suitable for JSONC
+// (JSON with comments).
+//
+// The grammar is:
+//
+//	Value = Object | Array | <string> | <number> | "true" | "false" | "null" .
+//	Object = "{" Member*%","? "}" .
+//	Member = (<string> | <ident>) ":" Value .
+//	Array = "[" Value*%","? "]" .
+//
+// In addition to JSON, "//" and "/* */" comments, trailing commas, single quoted strings, unquoted
+// object keys, and hexadecimal, signed, Infinity and NaN numbers are supported.
+//
+// Values are decoded as with encoding/json into an any: objects as map[string]any, arrays as []any,
+// numbers as float64, strings as string, booleans as bool, and null as nil.
+package json5
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+type value struct {
+	Pos lexer.Position
+
+	Object *object  `  @@`
+	Array  *array   `| @@`
+	String *string  `| @String`
+	Number *float64 `| @Number`
+	True   bool     `| @"true"`
+	False  bool     `| @"false"`
+	Null   bool     `| @"null"`
+}
+
+type object struct {
+	Members []*member `"{" @@*%","? "}"`
+}
+
+type member struct {
+	Key   string `@(String | Ident) ":"`
+	Value *value `@@`
+}
+
+type array struct {
+	Values []*value `"[" @@*%","? "]"`
+}
+
+var (
+	json5Lexer = lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "comment", Pattern: `//[^\n]*|/\*(?s:.*?)\*/`},
+		{Name: "whitespace", Pattern: `[\s\x{feff}\x{2028}\x{2029}]+`},
+		{Name: "String", Pattern: `"(?:\\(?s:.)|[^"\\\n])*"|'(?:\\(?s:.)|[^'\\\n])*'`},
+		{Name: "Number", Pattern: `[-+]?(?:0[xX][0-9a-fA-F]+|(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?|Infinity|NaN)`},
+		{Name: "Ident", Pattern: `[\pL_$][\pL\pN_$]*`},
+		{Name: "Punct", Pattern: `[{}\[\]:,]`},
+	})
+	parser = participle.MustBuild[value](
+		participle.Lexer(json5Lexer),
+		participle.Elide("comment", "whitespace"),
+		participle.Map(unquoteToken, "String"),
+		participle.Map(normaliseNumber, "Number"),
+	)
+)
+
+// Parse JSON5 from r.
+func Parse(filename string, r io.Reader) (any, error) {
+	ast, err := parser.Parse(filename, r)
+	if err != nil {
+		return nil, err
+	}
+	return ast.decode(), nil
+}
+
+// ParseString parses JSON5 from s.
+func ParseString(filename string, s string) (any, error) {
+	ast, err := parser.ParseString(filename, s)
+	if err != nil {
+		return nil, err
+	}
+	return ast.decode(), nil
+}
+
+// ParseBytes parses JSON5 from b.
+func ParseBytes(filename string, b []byte) (any, error) {
+	ast, err := parser.ParseBytes(filename, b)
+	if err != nil {
+		return nil, err
+	}
+	return ast.decode(), nil
+}
+
+func (v *value) decode() any {
+	switch {
+	case v.Object != nil:
+		out := make(map[string]any, len(v.Object.Members))
+		for _, member := range v.Object.Members {
+			out[member.Key] = member.Value.decode()
+		}
+		return out
+	case v.Array != nil:
+		out := make([]any, 0, len(v.Array.Values))
+		for _, value := range v.Array.Values {
+			out = append(out, value.decode())
+		}
+		return out
+	case v.String != nil:
+		return *v.String
+	case v.Number != nil:
+		return *v.Number
+	case v.True:
+		return true
+	case v.False:
+		return false
+	default:
+		return nil
+	}
+}
+
+// Rewrite numbers not understood by strconv.ParseFloat.
+func normaliseNumber(token lexer.Token) (lexer.Token, error) {
+	sign, digits := "", token.Value
+	if digits[0] == '-' || digits[0] == '+' {
+		sign, digits = digits[:1], digits[1:]
+	}
+	switch {
+	case digits == "Infinity":
+		token.Value = sign + "Inf"
+	case strings.HasPrefix(digits, "0x"), strings.HasPrefix(digits, "0X"):
+		n, err := strconv.ParseUint(digits[2:], 16, 64)
+		if err != nil {
+			return token, participle.Errorf(token.Pos, "invalid hexadecimal number %q", token.Value)
+		}
+		token.Value = sign + strconv.FormatFloat(float64(n), 'g', -1, 64)
+	case digits == "NaN":
+		token.Value = strconv.FormatFloat(math.NaN(), 'g', -1, 64)
+	}
+	return token, nil
+}
+
+// Unquote a single or double quoted JSON5 string.
+func unquoteToken(token lexer.Token) (lexer.Token, error) {
+	s := token.Value[1 : len(token.Value)-1]
+	out := strings.Builder{}
+	for len(s) > 0 {
+		if s[0] != '\\' {
+			rn, size := utf8.DecodeRuneInString(s)
+			out.WriteRune(rn)
+			s = s[size:]
+			continue
+		}
+		rn, size := utf8.DecodeRuneInString(s[1:])
+		s = s[1+size:]
+		switch rn {
+		case 'b':
+			out.WriteByte('\b')
+		case 'f':
+			out.WriteByte('\f')
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 't':
+			out.WriteByte('\t')
+		case 'v':
+			out.WriteByte('\v')
+		case '0':
+			out.WriteByte(0)
+		case '\n', '\u2028', '\u2029': // Line continuation.
+		case '\r':
+			s = strings.TrimPrefix(s, "\n")
+		case 'x', 'u':
+			digits := 2
+			if rn == 'u' {
+				digits = 4
+			}
+			code, err := parseHex(s, digits)
+			if err != nil {
+				return token, participle.Errorf(token.Pos, "invalid escape in %s: %s", token.Value, err)
+			}
+			s = s[digits:]
+			// Combine UTF-16 surrogate pairs.
+			if utf16.IsSurrogate(code) && strings.HasPrefix(s, `\u`) {
+				if low, err := parseHex(s[2:], 4); err == nil {
+					if combined := utf16.DecodeRune(code, low); combined != utf8.RuneError {
+						code = combined
+						s = s[6:]
+					}
+				}
+			}
+			out.WriteRune(code)
+		default:
+			out.WriteRune(rn)
+		}
+	}
+	token.Value = out.String()
+	return token, nil
+}
+
+func parseHex(s string, digits int) (rune, error) {
+	if len(s) < digits {
+		return 0, fmt.Errorf("expected %d hexadecimal digits", digits)
+	}
+	n, err := strconv.ParseUint(s[:digits], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("expected %d hexadecimal digits", digits)
+	}
+	return rune(n), nil
+}
@@ -0,0 +1,68 @@
+package json5_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2/json5"
+)
+
+func TestJSON5(t *testing.T) {
+	actual, err := json5.ParseString("", `
+// Line comment.
+{
+  unquoted: 'and you can quote me on that',
+  singleQuotes: 'I can use "double quotes" here',
+  lineBreaks: "Look, Mom! \
+No \\n's!",
+  hexadecimal: 0xdecaf,
+  leadingDecimalPoint: .8675309, andTrailing: 8675309.,
+  positiveSign: +1,
+  "quoted": [1, -2.5e1, null, true, false,],
+  /* Block
+     comment. */
+  escapes: "\x41é😀\t",
+  empty: {},
+  trailingComma: 'in objects', andIn: ['arrays',],
+}
+`)
+	require.NoError(t, err)
+	require.Equal[any](t, map[string]any{
+		"unquoted":            "and you can quote me on that",
+		"singleQuotes":        `I can use "double quotes" here`,
+		"lineBreaks":          `Look, Mom! No \n's!`,
+		"hexadecimal":         float64(0xdecaf),
+		"leadingDecimalPoint": .8675309,
+		"andTrailing":         8675309.0,
+		"positiveSign":        1.0,
+		"quoted":              []any{1.0, -25.0, nil, true, false},
+		"escapes":             "Aé😀\t",
+		"empty":               map[string]any{},
+		"trailingComma":       "in objects",
+		"andIn":               []any{"arrays"},
+	}, actual)
+}
+
+func TestJSON5Numbers(t *testing.T) {
+	actual, err := json5.Parse("", strings.NewReader(`[Infinity, -Infinity, NaN, -0x10]`))
+	require.NoError(t, err)
+	values := actual.([]any)
+	require.Equal[any](t, math.Inf(1), values[0])
+	require.Equal[any](t, math.Inf(-1), values[1])
+	require.True(t, math.IsNaN(values[2].(float64)))
+	require.Equal[any](t, -16.0, values[3])
+}
+
+func TestJSON5Errors(t *testing.T) {
+	_, err := json5.ParseBytes("", []byte(`{a: 1,, b: 2}`))
+	require.EqualError(t, err, `1:7: unexpected token "," (expected "}")`)
+
+	_, err = json5.ParseString("", `"\u00g0"`)
+	require.EqualError(t, err, `1:1: invalid escape in "\u00g0": expected 4 hexadecimal digits`)
+
+	_, err = json5.ParseString("", `[1, 2`)
+	require.Error(t, err)
+}
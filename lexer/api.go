@@ -41,6 +41,13 @@ type BytesDefinition interface {
 	LexBytes(filename string, input []byte) (Lexer, error)
 }
 
+// TriviaDefinition is an optional interface lexer Definition's can implement
+// to declare the symbols of tokens that are not significant to the grammar, such
+// as whitespace and comments. Parsers elide these tokens by default.
+type TriviaDefinition interface {
+	Trivia() []string
+}
+
 // A Lexer returns tokens from a source.
 type Lexer interface {
 	// Next consumes and returns the next token.
@@ -78,6 +85,36 @@ func Must(def Definition, err error) Definition {
 	return def
 }
 
+// FromTokens returns a Lexer returning previously lexed "tokens", the inverse of ConsumeAll.
+//
+// If "tokens" does not end with EOF, an EOF token is returned after the last token, positioned at
+// its end, or at the start of "filename" if there are no tokens.
+func FromTokens(filename string, tokens []Token) Lexer {
+	return &tokenLexer{filename: filename, tokens: tokens}
+}
+
+type tokenLexer struct {
+	filename string
+	tokens   []Token
+	eof      Position
+}
+
+func (t *tokenLexer) Next() (Token, error) {
+	if len(t.tokens) == 0 {
+		if t.eof.Line == 0 {
+			t.eof = Position{Filename: t.filename, Line: 1, Column: 1}
+		}
+		return EOFToken(t.eof), nil
+	}
+	token := t.tokens[0]
+	if !token.EOF() {
+		t.tokens = t.tokens[1:]
+		t.eof = token.Pos
+		t.eof.Advance(token.Value)
+	}
+	return token, nil
+}
+
 // ConsumeAll reads all tokens from a Lexer.
 func ConsumeAll(lexer Lexer) ([]Token, error) {
 	tokens := make([]Token, 0, 1024)
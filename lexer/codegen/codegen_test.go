@@ -0,0 +1,35 @@
+package codegen_test
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/alecthomas/participle/v2/lexer/codegen"
+)
+
+func TestGenerateSimpleLexer(t *testing.T) {
+	w := &strings.Builder{}
+	err := codegen.GenerateSimpleLexer(w, "main", "Test", []lexer.SimpleRule{
+		{Name: "Ident", Pattern: `\w+`},
+		{Name: "Whitespace", Pattern: `\s+`},
+		{Name: "Punct", Pattern: `[,.<>(){}=:]`},
+	})
+	require.NoError(t, err)
+	source := w.String()
+	_, err = parser.ParseFile(token.NewFileSet(), "test_lexer.go", source, 0)
+	require.NoError(t, err)
+	require.Contains(t, source, "package main")
+	require.Contains(t, source, "var TestLexer lexer.Definition")
+	require.Contains(t, source, `"Whitespace": -3`)
+}
+
+func TestGenerateSimpleLexerInvalidRule(t *testing.T) {
+	err := codegen.GenerateSimpleLexer(&strings.Builder{}, "main", "Test", []lexer.SimpleRule{
+		{Name: "Ident", Pattern: `\w+(`},
+	})
+	require.Error(t, err)
+}
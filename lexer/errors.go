@@ -17,6 +17,10 @@ type errorInterface interface {
 type Error struct {
 	Msg string
 	Pos Position
+	// Remaining is the input that was not lexed, starting at Pos, if known.
+	//
+	// This allows tooling to show more context than the error message, or to resume lexing.
+	Remaining string
 }
 
 var _ errorInterface = &Error{}
@@ -0,0 +1,44 @@
+package lexer
+
+import (
+	"fmt"
+	"io"
+)
+
+// NewExternal returns a Definition for tokens produced by a tokeniser external to Participle, whose
+// integer token types are named by "types" so that grammars can refer to them, eg. "Ident" or
+// <ident>.
+//
+// "lex" adapts the external tokeniser to a Lexer, setting each Token's Type to the tokeniser's
+// integer type. It may be nil if tokens will only be supplied to Parser.ParseFromLexer(), in which
+// case Lex() returns an error. The EOF type is named "EOF" unless it is already named in "types".
+func NewExternal(types map[int]string, lex func(filename string, r io.Reader) (Lexer, error)) (Definition, error) {
+	symbols := map[string]TokenType{}
+	for t, name := range types {
+		if prev, ok := symbols[name]; ok {
+			return nil, fmt.Errorf("token types %d and %d are both named %q", prev, t, name)
+		}
+		symbols[name] = TokenType(t)
+	}
+	if name, ok := types[int(EOF)]; ok && name != "EOF" {
+		return nil, fmt.Errorf("token type %d is reserved for EOF, not %q", EOF, name)
+	}
+	if _, ok := symbols["EOF"]; !ok {
+		symbols["EOF"] = EOF
+	}
+	return &externalDefinition{symbols: symbols, lex: lex}, nil
+}
+
+type externalDefinition struct {
+	symbols map[string]TokenType
+	lex     func(filename string, r io.Reader) (Lexer, error)
+}
+
+func (e *externalDefinition) Symbols() map[string]TokenType { return e.symbols }
+
+func (e *externalDefinition) Lex(filename string, r io.Reader) (Lexer, error) {
+	if e.lex == nil {
+		return nil, fmt.Errorf("external lexer has no tokeniser, tokens must be provided to ParseFromLexer()")
+	}
+	return e.lex(filename, r)
+}
@@ -0,0 +1,70 @@
+package lexer_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"unicode"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Token types of an external tokeniser.
+const (
+	externalWord = iota + 1
+	externalNumber
+)
+
+func tokeniseExternal(filename string, r io.Reader) (lexer.Lexer, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	tokens := []lexer.Token{}
+	for _, field := range strings.Fields(string(data)) {
+		t := lexer.Token{Type: externalWord, Value: field}
+		if unicode.IsDigit(rune(field[0])) {
+			t.Type = externalNumber
+		}
+		tokens = append(tokens, t)
+	}
+	return &staticLexer{tokens: tokens}, nil
+}
+
+func TestExternal(t *testing.T) {
+	type grammar struct {
+		Key   string `@Word`
+		Value int    `@Number`
+	}
+	def, err := lexer.NewExternal(map[int]string{externalWord: "Word", externalNumber: "Number"}, tokeniseExternal)
+	require.NoError(t, err)
+	parser := participle.MustBuild[grammar](participle.Lexer(def))
+	actual, err := parser.ParseString("", "size 10")
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Key: "size", Value: 10}, actual)
+
+	_, err = parser.ParseString("", "size width")
+	require.EqualError(t, err, `unexpected token "width" (expected <number>)`)
+
+	// Without a tokeniser, tokens can be provided directly.
+	def, err = lexer.NewExternal(map[int]string{externalWord: "Word", externalNumber: "Number"}, nil)
+	require.NoError(t, err)
+	parser = participle.MustBuild[grammar](participle.Lexer(def))
+	lex, err := lexer.Upgrade(&staticLexer{tokens: []lexer.Token{
+		{Type: externalWord, Value: "size"},
+		{Type: externalNumber, Value: "20"},
+	}})
+	require.NoError(t, err)
+	actual, err = parser.ParseFromLexer(lex)
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Key: "size", Value: 20}, actual)
+
+	_, err = parser.ParseString("", "size 10")
+	require.EqualError(t, err, "external lexer has no tokeniser, tokens must be provided to ParseFromLexer()")
+
+	_, err = lexer.NewExternal(map[int]string{externalWord: "Word", externalNumber: "Word"}, nil)
+	require.Error(t, err)
+}
@@ -5,6 +5,16 @@ type PeekingLexer struct {
 	Checkpoint
 	tokens []Token
 	elide  map[TokenType]bool
+	stream *tokenStream // Only set by UpgradeStream.
+}
+
+// Tokens read on demand by a PeekingLexer created with UpgradeStream.
+//
+// This is shared by all copies of the PeekingLexer, whose own tokens are a prefix of these.
+type tokenStream struct {
+	lex    Lexer
+	tokens []Token
+	err    error
 }
 
 // RawCursor index in the token stream.
@@ -43,9 +53,71 @@ func Upgrade(lex Lexer, elide ...TokenType) (*PeekingLexer, error) {
 	return r, nil
 }
 
+// UpgradeStream upgrades a Lexer to a PeekingLexer that reads tokens on demand, rather than
+// reading all of them up front as Upgrade does.
+//
+// Combined with Discard, this bounds the number of buffered tokens when parsing a large input
+// piece by piece. A lexing error ends the token stream as if it were EOF, and is available from
+// Err.
+func UpgradeStream(lex Lexer, elide ...TokenType) *PeekingLexer {
+	r := &PeekingLexer{
+		elide:  make(map[TokenType]bool, len(elide)),
+		stream: &tokenStream{lex: lex},
+	}
+	for _, rn := range elide {
+		r.elide[rn] = true
+	}
+	r.advanceToNonElided()
+	return r
+}
+
+// Err returns the error, if any, that ended the token stream of a PeekingLexer created with
+// UpgradeStream.
+func (p *PeekingLexer) Err() error {
+	if p.stream == nil {
+		return nil
+	}
+	return p.stream.err
+}
+
+// Discard buffered tokens before the cursor, which can no longer be returned by Range or Previous.
+//
+// Cursors, including those of existing checkpoints, are invalidated, and the cursor is reset to
+// zero. This is a no-op unless the PeekingLexer was created with UpgradeStream.
+func (p *PeekingLexer) Discard() {
+	if p.stream == nil {
+		return
+	}
+	p.stream.tokens = append([]Token(nil), p.stream.tokens[p.rawCursor:]...)
+	p.tokens = p.stream.tokens
+	p.nextCursor -= p.rawCursor
+	p.rawCursor = 0
+	p.cursor = 0
+}
+
+// Make the token at raw position "cursor" available, reading it from the stream if necessary.
+func (p *PeekingLexer) fill(cursor RawCursor) {
+	stream := p.stream
+	for int(cursor) >= len(stream.tokens) {
+		t, err := stream.lex.Next()
+		if err != nil {
+			stream.err = err
+			t = Token{Type: EOF}
+			if n := len(stream.tokens); n > 0 {
+				t.Pos = stream.tokens[n-1].Pos
+			}
+		}
+		stream.tokens = append(stream.tokens, t)
+	}
+	p.tokens = stream.tokens
+}
+
 // Range returns the slice of tokens between the two cursor points.
+//
+// The capacity of the slice is limited to its length, so appending to it will not
+// overwrite subsequent tokens.
 func (p *PeekingLexer) Range(rawStart, rawEnd RawCursor) []Token {
-	return p.tokens[rawStart:rawEnd]
+	return p.tokens[rawStart:rawEnd:rawEnd]
 }
 
 // Cursor position in tokens, excluding elided tokens.
@@ -71,6 +143,22 @@ func (p *PeekingLexer) Next() *Token {
 	return t
 }
 
+// IsElided returns true if tokens of type t are elided.
+func (p *PeekingLexer) IsElided(t TokenType) bool {
+	return p.elide[t]
+}
+
+// Previous returns the most recently consumed non-elided token, or nil if no
+// token has been consumed yet.
+func (p *PeekingLexer) Previous() *Token {
+	for i := int(p.rawCursor) - 1; i >= 0; i-- {
+		if t := &p.tokens[i]; !p.elide[t.Type] {
+			return t
+		}
+	}
+	return nil
+}
+
 // Peek ahead at the next non-elided token.
 func (p *PeekingLexer) Peek() *Token {
 	return &p.tokens[p.nextCursor]
@@ -86,6 +174,9 @@ func (p *PeekingLexer) RawPeek() *Token {
 // advanceToNonElided advances nextCursor to the closest non-elided token
 func (p *PeekingLexer) advanceToNonElided() {
 	for ; ; p.nextCursor++ {
+		if int(p.nextCursor) >= len(p.tokens) {
+			p.fill(p.nextCursor)
+		}
 		t := &p.tokens[p.nextCursor]
 		if t.EOF() || !p.elide[t.Type] {
 			return
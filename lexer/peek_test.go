@@ -56,6 +56,24 @@ func TestPeekingLexer_Peek_Next_Checkpoint(t *testing.T) {
 	require.Equal(t, expected[0], *plex.Peek(), "should have reverted to pre-Next state")
 }
 
+func TestPeekingLexer_Previous(t *testing.T) {
+	slexdef := lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\w+`},
+		{"Whitespace", `\s+`},
+	})
+	slex, err := slexdef.LexString("", `hello world`)
+	require.NoError(t, err)
+	plex, err := lexer.Upgrade(slex, slexdef.Symbols()["Whitespace"])
+	require.NoError(t, err)
+	require.Zero(t, plex.Previous())
+	hello := plex.Next()
+	require.Equal(t, hello, plex.Previous())
+	world := plex.Next()
+	require.Equal(t, world, plex.Previous())
+	plex.Next()
+	require.Equal(t, world, plex.Previous(), "EOF should not be consumed")
+}
+
 func BenchmarkPeekingLexer_Peek(b *testing.B) {
 	tokens := []lexer.Token{{Type: 1, Value: "x"}, {Type: 3, Value: " "}, {Type: 2, Value: "y"}}
 	l, err := lexer.Upgrade(&staticLexer{tokens: tokens}, 3)
@@ -71,3 +89,55 @@ func BenchmarkPeekingLexer_Peek(b *testing.B) {
 	}
 	require.Equal(b, lexer.Token{Type: 2, Value: "y"}, *t)
 }
+
+type countingLexer struct {
+	staticLexer
+	read int
+}
+
+func (c *countingLexer) Next() (lexer.Token, error) {
+	c.read++
+	return c.staticLexer.Next()
+}
+
+func TestUpgradeStream(t *testing.T) {
+	t0 := lexer.Token{Type: 1, Value: "a"}
+	ts := lexer.Token{Type: 3, Value: " "}
+	t1 := lexer.Token{Type: 2, Value: "b"}
+	t2 := lexer.Token{Type: 1, Value: "c"}
+	lex := &countingLexer{staticLexer: staticLexer{tokens: []lexer.Token{t0, ts, t1, t2}}}
+	l := lexer.UpgradeStream(lex, 3)
+	require.Equal(t, 1, lex.read, "only the first token should have been read")
+	require.Equal(t, t0, *l.Next())
+	require.Equal(t, 3, lex.read)
+
+	// Copies share the token stream.
+	branch := *l
+	require.Equal(t, t1, *branch.Next())
+	require.Equal(t, t2, *branch.Peek())
+	require.Equal(t, t1, *l.Next())
+	require.Equal(t, t2, *l.Peek())
+	require.Equal(t, 4, lex.read)
+
+	l.Discard()
+	require.Equal(t, 0, l.Cursor())
+	require.Equal(t, []lexer.Token{t2}, l.Range(0, 1))
+	require.Equal(t, t2, *l.Next())
+	require.True(t, l.Peek().EOF())
+	require.NoError(t, l.Err())
+}
+
+func TestFromTokens(t *testing.T) {
+	tokens := []lexer.Token{
+		{Type: 1, Value: "moo", Pos: lexer.Position{Filename: "a", Offset: 0, Line: 1, Column: 1}},
+		{Type: 2, Value: "\n", Pos: lexer.Position{Filename: "a", Offset: 3, Line: 1, Column: 4}},
+	}
+	actual, err := lexer.ConsumeAll(lexer.FromTokens("b", tokens))
+	require.NoError(t, err)
+	eof := lexer.EOFToken(lexer.Position{Filename: "a", Offset: 4, Line: 2, Column: 1})
+	require.Equal(t, append(tokens, eof), actual)
+
+	actual, err = lexer.ConsumeAll(lexer.FromTokens("b", nil))
+	require.NoError(t, err)
+	require.Equal(t, []lexer.Token{lexer.EOFToken(lexer.Position{Filename: "b", Line: 1, Column: 1})}, actual)
+}
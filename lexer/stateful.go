@@ -11,10 +11,12 @@ import (
 	"strings"
 	"sync"
 	"unicode"
+	"unsafe"
 )
 
 var (
-	backrefReplace = regexp.MustCompile(`(\\+)(\d)`)
+	backrefReplace  = regexp.MustCompile(`(\\+)(\d)`)
+	fragmentReplace = regexp.MustCompile(`(\\*)([pP]?)\{([A-Za-z_]\w*)\}`)
 )
 
 // A Rule matching input and possibly changing state.
@@ -65,12 +67,20 @@ func (r *Rule) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		action = actual
+	case "indent":
+		actual := ActionIndent{}
+		if err := json.Unmarshal(jrule.Action, &actual); err != nil {
+			return err
+		}
+		action = actual
 	case "include":
 		actual := include{}
 		if err := json.Unmarshal(jrule.Action, &actual); err != nil {
 			return err
 		}
 		action = actual
+	case "fragment":
+		action = fragment{}
 	case "":
 	default:
 		return fmt.Errorf("unknown action %q", jaction.Kind)
@@ -100,8 +110,12 @@ func (r *Rule) MarshalJSON() ([]byte, error) {
 			jaction["kind"] = "pop"
 		case ActionPush:
 			jaction["kind"] = "push"
+		case ActionIndent:
+			jaction["kind"] = "indent"
 		case include:
 			jaction["kind"] = "include"
+		case fragment:
+			jaction["kind"] = "fragment"
 		default:
 			return nil, fmt.Errorf("unsupported action %T", r.Action)
 		}
@@ -144,6 +158,11 @@ type validatingRule interface {
 	validate(rules Rules) error
 }
 
+// An Action that emits tokens of its own, which must be given token types.
+type emittingAction interface {
+	emits() []string
+}
+
 // ActionPop pops to the previous state when the Rule matches.
 type ActionPop struct{}
 
@@ -196,6 +215,76 @@ func Push(state string) Action {
 	return ActionPush{state}
 }
 
+// ActionIndent emits "Indent" and "Dedent" tokens when the indentation of a line changes.
+type ActionIndent struct {
+	Indent string `json:"indent"`
+	Dedent string `json:"dedent"`
+}
+
+func (a ActionIndent) applyAction(lexer *StatefulLexer, groups []string) error {
+	if groups[0] == "" {
+		return errors.New("did not consume any input")
+	}
+	newline := strings.LastIndex(groups[0], "\n")
+	if newline == -1 {
+		return errors.New("did not match a newline")
+	}
+	// Blank lines, and the end of the input, do not change the indentation.
+	rest := lexer.data[len(groups[0]):]
+	if rest == "" || rest[0] == '\n' || strings.HasPrefix(rest, "\r\n") {
+		return nil
+	}
+	indent := groups[0][newline+1:]
+	if strings.Contains(indent, " ") && strings.Contains(indent, "\t") {
+		return errors.New("indentation mixes tabs and spaces")
+	}
+	pos := lexer.pos
+	pos.Advance(groups[0])
+	state := &lexer.indentation
+	state.dedent = lexer.def.symbols[a.Dedent]
+	current := ""
+	if n := len(state.levels); n > 0 {
+		current = state.levels[n-1]
+	}
+	switch {
+	case indent == current:
+	case strings.HasPrefix(indent, current):
+		state.levels = append(state.levels, indent)
+		lexer.pending = append(lexer.pending, Token{Type: lexer.def.symbols[a.Indent], Value: indent, Pos: pos})
+	default:
+		for current != indent {
+			if !strings.HasPrefix(current, indent) {
+				return errors.New("indentation does not match any outer indentation level")
+			}
+			state.levels = state.levels[:len(state.levels)-1]
+			lexer.pending = append(lexer.pending, Token{Type: state.dedent, Pos: pos})
+			current = ""
+			if n := len(state.levels); n > 0 {
+				current = state.levels[n-1]
+			}
+		}
+	}
+	return nil
+}
+
+func (a ActionIndent) emits() []string {
+	return []string{a.Indent, a.Dedent}
+}
+
+// Indent tracks the indentation of lines, for languages such as Python where it is significant.
+//
+// The Rule must match a newline followed by the indentation of the next line, eg. `\n[ \t]*`.
+// When the line is indented further than the previous line a token of type "indentToken" is
+// emitted following the Rule's own token, and when it is indented less, a token of type
+// "dedentToken" is emitted for each level of indentation closed. Remaining levels are closed at
+// the end of the input. Blank lines do not affect the indentation.
+//
+// Lexing fails if the indentation of a line mixes tabs and spaces, or if a dedent does not return
+// to an enclosing level of indentation.
+func Indent(indentToken, dedentToken string) Action {
+	return ActionIndent{indentToken, dedentToken}
+}
+
 type include struct {
 	State string `json:"state"`
 }
@@ -220,6 +309,79 @@ func Include(state string) Rule {
 	return Rule{Action: include{state}}
 }
 
+type fragment struct{}
+
+func (fragment) applyAction(lexer *StatefulLexer, groups []string) error {
+	panic("should not be called")
+}
+
+// Fragment defines a named sub-pattern that can be interpolated into the patterns
+// of other rules, in any state, as "{Name}".
+//
+// Fragments are expanded before patterns are compiled, and never match input or
+// produce tokens themselves. eg.
+//
+//	lexer.Fragment("Digit", `[0-9]`),
+//	{"Float", `{Digit}+\.{Digit}*`, nil},
+func Fragment(name, pattern string) Rule {
+	return Rule{Name: name, Pattern: pattern, Action: fragment{}}
+}
+
+// Remove Fragment rules, expanding references to them in the patterns of the remaining rules.
+func expandFragments(rules Rules) (Rules, error) {
+	fragments := map[string]string{}
+	for _, set := range rules {
+		for _, rule := range set {
+			if _, ok := rule.Action.(fragment); !ok {
+				continue
+			}
+			if pattern, ok := fragments[rule.Name]; ok && pattern != rule.Pattern {
+				return nil, fmt.Errorf("duplicate fragment %q with different patterns %q != %q", rule.Name, rule.Pattern, pattern)
+			}
+			fragments[rule.Name] = rule.Pattern
+		}
+	}
+	if len(fragments) == 0 {
+		return rules, nil
+	}
+	var expand func(pattern string, expanding []string) (string, error)
+	expand = func(pattern string, expanding []string) (out string, err error) {
+		out = fragmentReplace.ReplaceAllStringFunc(pattern, func(ref string) string {
+			match := fragmentReplace.FindStringSubmatch(ref)
+			fragment, ok := fragments[match[3]]
+			// Escaped braces, Unicode classes such as \p{Greek} and unknown names are left as-is.
+			if !ok || len(match[1])%2 == 1 || err != nil {
+				return ref
+			}
+			for _, name := range expanding {
+				if name == match[3] {
+					err = fmt.Errorf("fragment %q is recursive", name)
+					return ref
+				}
+			}
+			fragment, err = expand(fragment, append(expanding, match[3]))
+			return match[1] + match[2] + "(?:" + fragment + ")"
+		})
+		return out, err
+	}
+	out := make(Rules, len(rules))
+	for state, set := range rules {
+		out[state] = make([]Rule, 0, len(set))
+		for _, rule := range set {
+			if _, ok := rule.Action.(fragment); ok {
+				continue
+			}
+			pattern, err := expand(rule.Pattern, nil)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+			}
+			rule.Pattern = pattern
+			out[state] = append(out[state], rule)
+		}
+	}
+	return out, nil
+}
+
 // StatefulDefinition is the lexer.Definition.
 type StatefulDefinition struct {
 	rules   compiledRules
@@ -227,11 +389,65 @@ type StatefulDefinition struct {
 	// Map of key->*regexp.Regexp
 	backrefCache sync.Map
 	matchLongest bool
+	trackOrigin  bool
+	priorities   map[string]int
+	maxLengths   map[string]int
+	zeroCopy     bool
+}
+
+// Option for configuring the stateful lexer.
+type Option func(d *StatefulDefinition)
+
+// TrackOrigin records, for each token lexed in a state entered by Push(), the token that pushed the
+// state. See StatefulLexer.Origin().
+//
+// This allows eg. errors in an interpolated expression to be related back to the enclosing string.
+func TrackOrigin() Option {
+	return func(d *StatefulDefinition) {
+		d.trackOrigin = true
+	}
+}
+
+// ZeroCopy makes LexBytes lex its input without copying it.
+//
+// The values of the lexed tokens then share memory with the input, which must therefore not be
+// modified while they, or any values captured from them, are in use.
+func ZeroCopy() Option {
+	return func(d *StatefulDefinition) {
+		d.zeroCopy = true
+	}
+}
+
+// Priorities sets the priority of the named rules, in every state and including rules from an
+// Include(). Within a state, rules with a higher priority are tried first, while rules with the same
+// priority, including unlisted rules with priority 0, are tried in the order they are declared.
+//
+// This allows eg. keywords to take precedence over identifiers regardless of the order in which
+// they are declared or included:
+//
+//	lexer.MustStateful(rules, lexer.Priorities(map[string]int{"Keyword": 1}))
+func Priorities(priorities map[string]int) Option {
+	return func(d *StatefulDefinition) {
+		d.priorities = priorities
+	}
+}
+
+// MaxLengths fails lexing if any of the named rules, in any state, matches more than the given
+// number of bytes of input.
+//
+// This guards against pathological input, such as an unterminated string matching all the way to
+// EOF, when lexing untrusted input with greedy patterns:
+//
+//	lexer.MustStateful(rules, lexer.MaxLengths(map[string]int{"String": 4096}))
+func MaxLengths(lengths map[string]int) Option {
+	return func(d *StatefulDefinition) {
+		d.maxLengths = lengths
+	}
 }
 
 // MustStateful creates a new stateful lexer and panics if it is incorrect.
-func MustStateful(rules Rules) *StatefulDefinition {
-	def, err := New(rules)
+func MustStateful(rules Rules, options ...Option) *StatefulDefinition {
+	def, err := New(rules, options...)
 	if err != nil {
 		panic(err)
 	}
@@ -239,7 +455,15 @@ func MustStateful(rules Rules) *StatefulDefinition {
 }
 
 // New constructs a new stateful lexer from rules.
-func New(rules Rules) (*StatefulDefinition, error) {
+func New(rules Rules, options ...Option) (*StatefulDefinition, error) {
+	d := &StatefulDefinition{}
+	for _, option := range options {
+		option(d)
+	}
+	rules, err := expandFragments(rules)
+	if err != nil {
+		return nil, err
+	}
 	compiled := compiledRules{}
 	for key, set := range rules {
 		for i, rule := range set {
@@ -278,6 +502,25 @@ restart:
 			}
 		}
 	}
+	names := map[string]bool{}
+	for _, rules := range compiled {
+		for _, rule := range rules {
+			names[rule.Name] = true
+		}
+		sort.SliceStable(rules, func(i, j int) bool {
+			return d.priorities[rules[i].Name] > d.priorities[rules[j].Name]
+		})
+	}
+	for name := range d.priorities {
+		if !names[name] {
+			return nil, fmt.Errorf("priority for unknown rule %q", name)
+		}
+	}
+	for name := range d.maxLengths {
+		if !names[name] {
+			return nil, fmt.Errorf("maximum length for unknown rule %q", name)
+		}
+	}
 	keys := make([]string, 0, len(compiled))
 	for key := range compiled {
 		keys = append(keys, key)
@@ -299,10 +542,22 @@ restart:
 			rn--
 		}
 	}
-	d := &StatefulDefinition{
-		rules:   compiled,
-		symbols: symbols,
+	for _, key := range keys {
+		for _, rule := range compiled[key] {
+			action, ok := rule.Action.(emittingAction)
+			if !ok {
+				continue
+			}
+			for _, name := range action.emits() {
+				if _, ok := symbols[name]; !ok {
+					symbols[name] = rn
+					rn--
+				}
+			}
+		}
 	}
+	d.rules = compiled
+	d.symbols = symbols
 	return d, nil
 }
 
@@ -321,6 +576,12 @@ func (d *StatefulDefinition) Rules() Rules {
 	return out
 }
 
+// MaxLength returns the maximum length of a match of the named rule set by MaxLengths(), or 0 if
+// there is none.
+func (d *StatefulDefinition) MaxLength(rule string) int {
+	return d.maxLengths[rule]
+}
+
 // LexString is a fast-path implementation for lexing strings.
 func (d *StatefulDefinition) LexString(filename string, s string) (Lexer, error) {
 	return &StatefulLexer{
@@ -335,6 +596,41 @@ func (d *StatefulDefinition) LexString(filename string, s string) (Lexer, error)
 	}, nil
 }
 
+// LexBytes lexes a copy of b, or b itself if the ZeroCopy() option is set.
+func (d *StatefulDefinition) LexBytes(filename string, b []byte) (Lexer, error) {
+	if !d.zeroCopy {
+		return d.LexString(filename, string(b))
+	}
+	return d.LexString(filename, *(*string)(unsafe.Pointer(&b)))
+}
+
+// LexFrom lexes data starting at byte "offset" in the lexer state "state", eg. to re-lex only the
+// region of a document that has changed since it was last lexed.
+//
+// Positions of the returned tokens are relative to the start of data. A Pop() returns to the Root
+// state. Rules in "state" with backreferences to the groups of the rule that pushed it can not be
+// matched, as those groups are not known.
+func (d *StatefulDefinition) LexFrom(filename string, data string, offset int, state string) (Lexer, error) {
+	if _, ok := d.rules[state]; !ok {
+		return nil, fmt.Errorf("unknown lexer state %q", state)
+	}
+	if offset < 0 || offset > len(data) {
+		return nil, fmt.Errorf("offset %d is out of range for input of length %d", offset, len(data))
+	}
+	stack := []lexerState{{name: "Root"}}
+	if state != "Root" {
+		stack = append(stack, lexerState{name: state})
+	}
+	pos := Position{Filename: filename, Line: 1, Column: 1}
+	pos.Advance(data[:offset])
+	return &StatefulLexer{
+		def:   d,
+		data:  data[offset:],
+		stack: stack,
+		pos:   pos,
+	}, nil
+}
+
 func (d *StatefulDefinition) Lex(filename string, r io.Reader) (Lexer, error) { // nolint: golint
 	w := &strings.Builder{}
 	_, err := io.Copy(w, r)
@@ -352,17 +648,71 @@ func (d *StatefulDefinition) Symbols() map[string]TokenType { // nolint: golint
 type lexerState struct {
 	name   string
 	groups []string
+	origin int // One more than the index of the token that pushed this state, or 0, with TrackOrigin().
+}
+
+// A run of consecutive tokens sharing the same origin, recorded with TrackOrigin().
+type originRun struct {
+	start  int // Index of the first token of the run.
+	origin int // One more than the index of the origin of the run, or 0 if it has none.
 }
 
 // StatefulLexer implementation.
 type StatefulLexer struct {
-	stack []lexerState
-	def   *StatefulDefinition
-	data  string
-	pos   Position
+	stack       []lexerState
+	def         *StatefulDefinition
+	data        string
+	pos         Position
+	pending     []Token // Tokens emitted by actions, returned before lexing continues.
+	indentation indentation
+	index       int         // Index of the next token returned by Next().
+	origins     []originRun // Ordered by start, with TrackOrigin().
+}
+
+// Origin returns the index of the token that entered the lexer state the token at "index" was
+// lexed in, eg. the opening quote of a string containing an interpolated expression. Indexes count
+// the tokens returned by Next(), starting from zero.
+//
+// Origins are only recorded by lexers constructed with the TrackOrigin() option, and tokens lexed
+// in the root state have none.
+func (l *StatefulLexer) Origin(index int) (int, bool) {
+	if index >= l.index {
+		return 0, false
+	}
+	i := sort.Search(len(l.origins), func(i int) bool { return l.origins[i].start > index })
+	if i == 0 || l.origins[i-1].origin == 0 {
+		return 0, false
+	}
+	return l.origins[i-1].origin - 1, true
+}
+
+// Indentation tracked by Indent().
+type indentation struct {
+	levels []string
+	dedent TokenType
 }
 
 func (l *StatefulLexer) Next() (Token, error) { // nolint: golint
+	token, origin, err := l.next()
+	if err != nil {
+		return token, err
+	}
+	if l.def.trackOrigin {
+		if n := len(l.origins); (n == 0 && origin != 0) || (n > 0 && l.origins[n-1].origin != origin) {
+			l.origins = append(l.origins, originRun{start: l.index, origin: origin})
+		}
+	}
+	l.index++
+	return token, nil
+}
+
+// Lex the next token, returning it along with its origin as recorded in lexerState.
+func (l *StatefulLexer) next() (Token, int, error) {
+	if len(l.pending) > 0 {
+		token := l.pending[0]
+		l.pending = l.pending[1:]
+		return token, 0, nil
+	}
 	parent := l.stack[len(l.stack)-1]
 	rules := l.def.rules[parent.name]
 next:
@@ -382,7 +732,7 @@ next:
 			}
 			re, err := l.getPattern(candidate)
 			if err != nil {
-				return Token{}, errorf(l.pos, "rule %q: %s", candidate.Name, err)
+				return Token{}, 0, l.errorf("rule %q: %s", candidate.Name, err)
 			}
 			m = re.FindStringSubmatchIndex(l.data)
 			if m != nil && (match == nil || m[1] > match[1]) {
@@ -398,19 +748,23 @@ next:
 			if len(sample) > 16 {
 				sample = append(sample[:16], []rune("...")...)
 			}
-			return Token{}, errorf(l.pos, "invalid input text %q", string(sample))
+			return Token{}, 0, l.errorf("invalid input text %q", string(sample))
 		}
 
+		if n, ok := l.def.maxLengths[rule.Name]; ok && match[1]-match[0] > n {
+			return Token{}, 0, l.errorf("rule %q: match exceeds maximum length of %d bytes", rule.Name, n)
+		}
+		depth := len(l.stack)
 		if rule.Action != nil {
 			groups := make([]string, 0, len(match)/2)
 			for i := 0; i < len(match); i += 2 {
 				groups = append(groups, l.data[match[i]:match[i+1]])
 			}
 			if err := rule.Action.applyAction(l, groups); err != nil {
-				return Token{}, errorf(l.pos, "rule %q: %s", rule.Name, err)
+				return Token{}, 0, l.errorf("rule %q: %s", rule.Name, err)
 			}
 		} else if match[0] == match[1] {
-			return Token{}, errorf(l.pos, "rule %q did not match any input", rule.Name)
+			return Token{}, 0, l.errorf("rule %q did not match any input", rule.Name)
 		}
 
 		span := l.data[match[0]:match[1]]
@@ -420,18 +774,42 @@ next:
 		// Update position.
 		pos := l.pos
 		l.pos.Advance(span)
+		token := Token{
+			Type:  l.def.symbols[rule.Name],
+			Value: span,
+			Pos:   pos,
+		}
+		if l.def.trackOrigin && len(l.stack) > depth {
+			// Ignored tokens are not returned, so states they push keep the origin of their parent.
+			if rule.ignore {
+				l.stack[len(l.stack)-1].origin = parent.origin
+			} else {
+				l.stack[len(l.stack)-1].origin = l.index + 1
+			}
+		}
 		if rule.ignore {
+			if len(l.pending) > 0 {
+				return l.next()
+			}
 			parent = l.stack[len(l.stack)-1]
 			rules = l.def.rules[parent.name]
 			continue
 		}
-		return Token{
-			Type:  l.def.symbols[rule.Name],
-			Value: span,
-			Pos:   pos,
-		}, nil
+		return token, parent.origin, nil
+	}
+	// Close any levels of indentation left open by Indent().
+	if n := len(l.indentation.levels); n > 0 {
+		l.indentation.levels = l.indentation.levels[:n-1]
+		return Token{Type: l.indentation.dedent, Pos: l.pos}, 0, nil
 	}
-	return EOFToken(l.pos), nil
+	return EOFToken(l.pos), 0, nil
+}
+
+// Create an Error at the current position, carrying the remaining input.
+func (l *StatefulLexer) errorf(format string, args ...interface{}) *Error {
+	err := errorf(l.pos, format, args...)
+	err.Remaining = l.data
+	return err
 }
 
 func (l *StatefulLexer) getPattern(candidate compiledRule) (*regexp.Regexp, error) {
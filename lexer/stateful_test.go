@@ -2,6 +2,8 @@ package lexer_test
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"testing"
@@ -46,6 +48,7 @@ func TestStatefulLexer(t *testing.T) {
 		name     string
 		rules    lexer.Rules
 		input    string
+		options  []lexer.Option
 		tokens   []string
 		err      string
 		buildErr string
@@ -174,11 +177,63 @@ func TestStatefulLexer(t *testing.T) {
 			input: "hello",
 			err:   "1:1: rule \"NoMatch\": did not consume any input",
 		},
+		{name: "MaxLen",
+			rules: lexer.Rules{
+				"Root": {
+					{"String", `"[^"]*"?`, nil},
+					{"whitespace", `\s+`, nil},
+				},
+			},
+			options: []lexer.Option{lexer.MaxLengths(map[string]int{"String": 8})},
+			input:   `"short" "unterminated and long`,
+			err:     "1:9: rule \"String\": match exceeds maximum length of 8 bytes",
+		},
+		{name: "MaxLenUnknownRule",
+			rules:    lexer.Rules{"Root": {{"String", `"[^"]*"`, nil}}},
+			options:  []lexer.Option{lexer.MaxLengths(map[string]int{"Strings": 8})},
+			buildErr: `maximum length for unknown rule "Strings"`,
+		},
+		{name: "Fragments",
+			rules: lexer.Rules{
+				"Root": {
+					lexer.Fragment("Digit", `[0-9]`),
+					lexer.Fragment("Exponent", `[eE][-+]?{Digit}+`),
+					{"Float", `{Digit}+\.{Digit}*{Exponent}?`, nil},
+					{"Int", `{Digit}+`, nil},
+					{"Braces", `\{Digit\}`, nil},
+					{"whitespace", `\s+`, nil},
+				},
+			},
+			input:  `1.5e10 42 {Digit}`,
+			tokens: []string{"1.5e10", "42", "{Digit}"},
+		},
+		{name: "FragmentNamedAfterUnicodeClass",
+			rules: lexer.Rules{
+				"Root": {
+					lexer.Fragment("Greek", `g`),
+					{"Letters", `\p{Greek}+`, nil},
+					{"Word", `\P{Greek}p{Greek}`, nil},
+					{"whitespace", `\s+`, nil},
+				},
+			},
+			input:  `αβγ xpg`,
+			tokens: []string{"αβγ", "xpg"},
+		},
+		{name: "RecursiveFragment",
+			buildErr: `rule "Loop": fragment "A" is recursive`,
+			rules: lexer.Rules{
+				"Root": {
+					lexer.Fragment("A", `a{B}`),
+					lexer.Fragment("B", `b{A}`),
+					{"Loop", `{A}`, nil},
+				},
+			},
+		},
 	}
 	// nolint: scopelint
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			def, err := lexer.New(test.rules)
+			def, err := lexer.New(test.rules, test.options...)
 			if test.buildErr != "" {
 				require.EqualError(t, err, test.buildErr)
 				return
@@ -336,6 +391,236 @@ func TestStateful(t *testing.T) {
 	require.Equal(t, expected, actual)
 }
 
+func TestTrackOrigin(t *testing.T) {
+	def, err := lexer.New(lexer.Rules{
+		"Root": {
+			{"whitespace", `\s+`, nil},
+			{"Ident", `\w+`, nil},
+			{`String`, `"`, lexer.Push("String")},
+		},
+		"String": {
+			{"StringEnd", `"`, lexer.Pop()},
+			{"Expr", `\${`, lexer.Push("Expr")},
+			{"Char", `[^$"]+`, nil},
+		},
+		"Expr": {
+			lexer.Include("Root"),
+			{"ExprEnd", `}`, lexer.Pop()},
+		},
+	}, lexer.TrackOrigin())
+	require.NoError(t, err)
+	lex, err := def.LexString("", `a "b${c}"`)
+	require.NoError(t, err)
+	tokens, err := lexer.ConsumeAll(lex)
+	require.NoError(t, err)
+	origins := []string{}
+	for i, token := range tokens {
+		origin := ""
+		for o, ok := lex.(*lexer.StatefulLexer).Origin(i); ok; o, ok = lex.(*lexer.StatefulLexer).Origin(o) {
+			origin += fmt.Sprintf(" <- %s@%d", tokens[o].Value, tokens[o].Pos.Offset)
+		}
+		origins = append(origins, token.String()+origin)
+	}
+	require.Equal(t, []string{
+		"a",
+		`"`,
+		`b <- "@2`,
+		`${ <- "@2`,
+		`c <- ${@4 <- "@2`,
+		`} <- ${@4 <- "@2`,
+		`" <- "@2`,
+		"<EOF>",
+	}, origins)
+
+	// Origins are only tracked when requested.
+	def, err = lexer.New(lexer.Rules{
+		"Root": {
+			{`String`, `"`, lexer.Push("String")},
+		},
+		"String": {
+			{"StringEnd", `"`, lexer.Pop()},
+			{"Char", `[^"]+`, nil},
+		},
+	})
+	require.NoError(t, err)
+	lex, err = def.LexString("", `"b"`)
+	require.NoError(t, err)
+	tokens, err = lexer.ConsumeAll(lex)
+	require.NoError(t, err)
+	for i := range tokens {
+		_, ok := lex.(*lexer.StatefulLexer).Origin(i)
+		require.False(t, ok)
+	}
+}
+
+func TestLexFrom(t *testing.T) {
+	def, err := lexer.New(lexer.Rules{
+		"Root": {
+			{"whitespace", `\s+`, nil},
+			{"Ident", `\w+`, nil},
+			{"String", `"`, lexer.Push("String")},
+		},
+		"String": {
+			{"StringEnd", `"`, lexer.Pop()},
+			{"Char", `[^"]+`, nil},
+		},
+	})
+	require.NoError(t, err)
+	symbols := lexer.SymbolsByRune(def)
+	lexFrom := func(data string, offset int, state string) []string {
+		t.Helper()
+		lex, err := def.LexFrom("test", data, offset, state)
+		require.NoError(t, err)
+		tokens, err := lexer.ConsumeAll(lex)
+		require.NoError(t, err)
+		out := []string{}
+		for _, token := range tokens {
+			out = append(out, fmt.Sprintf("%s@%d:%s:%q", token.Pos, token.Pos.Offset, symbols[token.Type], token.Value))
+		}
+		return out
+	}
+	require.Equal(t, []string{
+		`test:2:3@4:Ident:"c"`,
+		`test:2:5@6:String:"\""`,
+		`test:2:6@7:Char:"d"`,
+		`test:2:7@8:StringEnd:"\""`,
+		`test:2:8@9:EOF:""`,
+	}, lexFrom("a\nabc \"d\"", 4, "Root"))
+
+	// Starting inside the string.
+	require.Equal(t, []string{
+		`test:2:2@3:Char:"bc "`,
+		`test:2:5@6:StringEnd:"\""`,
+		`test:2:6@7:Ident:"d"`,
+		`test:2:7@8:EOF:""`,
+	}, lexFrom("a\nabc \"d", 3, "String"))
+
+	_, err = def.LexFrom("", "", 0, "Missing")
+	require.EqualError(t, err, `unknown lexer state "Missing"`)
+	_, err = def.LexFrom("", "abc", 4, "Root")
+	require.EqualError(t, err, `offset 4 is out of range for input of length 3`)
+}
+
+func TestPriorities(t *testing.T) {
+	rules := lexer.Rules{
+		"Root": {
+			{"whitespace", `\s+`, nil},
+			lexer.Include("Idents"),
+			lexer.Include("Keywords"),
+		},
+		"Idents":   {{"Ident", `\w+`, nil}},
+		"Keywords": {{"Keyword", `if|else`, nil}},
+	}
+	lex := func(def *lexer.StatefulDefinition) []string {
+		l, err := def.LexString("", `if a else`)
+		require.NoError(t, err)
+		tokens, err := lexer.ConsumeAll(l)
+		require.NoError(t, err)
+		names := lexer.SymbolsByRune(def)
+		out := []string{}
+		for _, token := range tokens {
+			out = append(out, names[token.Type]+":"+token.Value)
+		}
+		return out
+	}
+	def, err := lexer.New(rules)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Ident:if", "Ident:a", "Ident:else", "EOF:"}, lex(def))
+
+	def, err = lexer.New(rules, lexer.Priorities(map[string]int{"Keyword": 1}))
+	require.NoError(t, err)
+	require.Equal(t, []string{"Keyword:if", "Ident:a", "Keyword:else", "EOF:"}, lex(def))
+	// The rules are reported in the order they are tried, eg. for code generation.
+	require.Equal(t, []lexer.Rule{{"Keyword", `if|else`, nil}, {"whitespace", `\s+`, nil}, {"Ident", `\w+`, nil}}, def.Rules()["Root"])
+
+	_, err = lexer.New(rules, lexer.Priorities(map[string]int{"Keywords": 1}))
+	require.EqualError(t, err, `priority for unknown rule "Keywords"`)
+}
+
+func TestIndent(t *testing.T) {
+	rules := lexer.Rules{
+		"Root": {
+			{"Newline", `(\n[ \t]*)+`, lexer.Indent("Indent", "Dedent")},
+			{"whitespace", `[ \t]+`, nil},
+			{"Ident", `\w+`, nil},
+			{"Punct", `:`, nil},
+		},
+	}
+	def, err := lexer.New(rules)
+	require.NoError(t, err)
+	symbols := lexer.SymbolsByRune(def)
+	lex := func(source string) ([]string, error) {
+		t.Helper()
+		lex, err := def.LexString("", source)
+		require.NoError(t, err)
+		tokens, err := lexer.ConsumeAll(lex)
+		out := []string{}
+		for _, token := range tokens {
+			out = append(out, fmt.Sprintf("%s@%s", symbols[token.Type], token.Pos))
+		}
+		return out, err
+	}
+	tokens, err := lex("if a:\n  b\n\n  if c:\n    d\ne\n  f")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"Ident@1:1", "Ident@1:4", "Punct@1:5", "Newline@1:6", "Indent@2:3",
+		"Ident@2:3", "Newline@2:4",
+		"Ident@4:3", "Ident@4:6", "Punct@4:7", "Newline@4:8", "Indent@5:5",
+		"Ident@5:5", "Newline@5:6", "Dedent@6:1", "Dedent@6:1",
+		"Ident@6:1", "Newline@6:2", "Indent@7:3",
+		"Ident@7:3", "Dedent@7:4",
+		"EOF@7:4",
+	}, tokens)
+
+	_, err = lex("a\n  b\n \tc")
+	require.EqualError(t, err, `2:4: rule "Newline": indentation mixes tabs and spaces`)
+
+	_, err = lex("a\n    b\n  c")
+	require.EqualError(t, err, `2:6: rule "Newline": indentation does not match any outer indentation level`)
+
+	// Rules using Indent() can be serialised.
+	data, err := json.Marshal(rules)
+	require.NoError(t, err)
+	unmarshalled := lexer.Rules{}
+	require.NoError(t, json.Unmarshal(data, &unmarshalled))
+	require.Equal(t, rules, unmarshalled)
+}
+
+func TestLexBytes(t *testing.T) {
+	def := lexer.MustStateful(lexer.Rules{
+		"Root": {
+			{"whitespace", `\s+`, nil},
+			{"Ident", `\w+`, nil},
+		},
+	})
+	input := []byte("hello world")
+	lex, err := def.LexBytes("", input)
+	require.NoError(t, err)
+	tokens, err := lexer.ConsumeAll(lex)
+	require.NoError(t, err)
+	require.Equal(t, []string{"hello", "world", ""}, []string{tokens[0].Value, tokens[1].Value, tokens[2].Value})
+	// Values are copied from the input.
+	input[0] = 'j'
+	require.Equal(t, "hello", tokens[0].Value)
+}
+
+func TestLexBytesZeroCopy(t *testing.T) {
+	def := lexer.MustStateful(lexer.Rules{
+		"Root": {
+			{"whitespace", `\s+`, nil},
+			{"Ident", `\w+`, nil},
+		},
+	}, lexer.ZeroCopy())
+	input := []byte("hello world")
+	lex, err := def.LexBytes("", input)
+	require.NoError(t, err)
+	tokens, err := lexer.ConsumeAll(lex)
+	require.NoError(t, err)
+	// Values are not copied from the input.
+	input[0] = 'j'
+	require.Equal(t, "jello", tokens[0].Value)
+}
+
 func TestHereDoc(t *testing.T) {
 	type Heredoc struct {
 		Idents []string `Heredoc @Ident* End`
@@ -377,6 +662,21 @@ func TestHereDoc(t *testing.T) {
 	require.Equal(t, expected, actual)
 }
 
+func TestErrorRemainingInput(t *testing.T) {
+	def := lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\w+`},
+		{"whitespace", `\s+`},
+	})
+	lex, err := def.LexString("", "hello world ! and the remainder of the input")
+	require.NoError(t, err)
+	_, err = lexer.ConsumeAll(lex)
+	var lerr *lexer.Error
+	require.True(t, errors.As(err, &lerr))
+	require.Equal(t, `1:13: invalid input text "! and the remain..."`, lerr.Error())
+	require.Equal(t, 12, lerr.Pos.Offset)
+	require.Equal(t, "! and the remainder of the input", lerr.Remaining)
+}
+
 func BenchmarkStateful(b *testing.B) {
 	source := strings.Repeat(`"hello ${user + "${last}"}"`, 100)
 	def := lexer.Must(lexer.New(interpolatedRules))
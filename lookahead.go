@@ -0,0 +1,420 @@
+package participle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// The longest shared prefix LookaheadReport() looks for.
+const maxReportedLookahead = 8
+
+// LookaheadReport describes the choices in the grammar that need more than one token of lookahead
+// to decide, eg. to find which branches require a larger UseLookahead().
+//
+// Each line names the production containing the choice and the pair of branches involved, either
+// two alternatives of a disjunction, or the body of an optional or repeated group and the
+// expression following it. Branches are attempted in order, and the first branch is abandoned when
+// the input stops matching it, which requires lookahead of at least the number of tokens the
+// branches have in common. eg.
+//
+//	Statement: (<ident> "*" <ident> ";") and (<ident> "*" "=" <int> ";") share the prefix <ident> "*", requiring lookahead 2 (exceeds UseLookahead(1))
+//
+// Where the first branch matches the start of the second in its entirety, the first shadows the
+// second on that input, which no amount of lookahead resolves.
+//
+// The analysis is static and conservative: semantic predicates are ignored, and productions parsed
+// by custom functions or types implementing Parseable are assumed to match any input. The report is
+// empty if no choice needs more than one token of lookahead.
+func (p *Parser[G]) LookaheadReport() string {
+	a := &lookaheadAnalysis{
+		lex:          p.lex,
+		memo:         map[lookaheadKey][]tokenPrefix{},
+		repeats:      map[repeatKey][]tokenPrefix{},
+		visiting:     map[lookaheadKey]bool{},
+		literalTypes: map[string]lexer.TokenType{},
+	}
+	out := []string{}
+	report := func(production string, first, second node, firstPrefixes, secondPrefixes []tokenPrefix) {
+		lcp, prefix, shadow := a.conflict(firstPrefixes, secondPrefixes)
+		switch {
+		case shadow:
+			out = append(out, fmt.Sprintf("%s: %s shadows %s on input starting with %s",
+				production, describeBranch(first), describeBranch(second), prefix))
+		case lcp == maxReportedLookahead:
+			out = append(out, fmt.Sprintf("%s: %s and %s share the prefix %s, requiring lookahead of at least %d%s",
+				production, describeBranch(first), describeBranch(second), prefix, lcp, p.exceedsLookahead(lcp)))
+		case lcp > 1:
+			out = append(out, fmt.Sprintf("%s: %s and %s share the prefix %s, requiring lookahead %d%s",
+				production, describeBranch(first), describeBranch(second), prefix, lcp, p.exceedsLookahead(lcp)))
+		}
+	}
+	seen := map[node]bool{}
+	production := ""
+	_ = visit(p.typeNodes[p.rootType], func(n node, next func() error) error {
+		if seen[n] {
+			return nil
+		}
+		seen[n] = true
+		switch n := n.(type) {
+		case *strct:
+			parent := production
+			production = productionName(n.typ)
+			defer func() { production = parent }()
+
+		case *union:
+			for i, first := range n.disjunction.nodes {
+				for _, second := range n.disjunction.nodes[i+1:] {
+					report(productionName(n.typ), first, second, a.prefixes(first, maxReportedLookahead), a.prefixes(second, maxReportedLookahead))
+				}
+			}
+
+		case *disjunction:
+			for i, first := range n.nodes {
+				for _, second := range n.nodes[i+1:] {
+					report(production, first, second, a.prefixes(first, maxReportedLookahead), a.prefixes(second, maxReportedLookahead))
+				}
+			}
+
+		case *sequence:
+			// The group is attempted again before the expression following it.
+			g, ok := n.node.(*group)
+			if !ok || !g.optional() || n.next == nil {
+				break
+			}
+			body := a.prefixes(g.expr, maxReportedLookahead)
+			if g.sep != nil {
+				body = a.union(body, a.concat(maxReportedLookahead, a.prefixes(g.sep, maxReportedLookahead), func(k int) []tokenPrefix { return a.prefixes(g.expr, k) }))
+			}
+			report(production, g, n.next, body, a.prefixes(n.next, maxReportedLookahead))
+		}
+		return next()
+	})
+	return strings.Join(out, "\n")
+}
+
+func (p *Parser[G]) exceedsLookahead(lookahead int) string {
+	if p.useLookahead < 0 || lookahead <= p.useLookahead {
+		return ""
+	}
+	return fmt.Sprintf(" (exceeds UseLookahead(%d))", p.useLookahead)
+}
+
+// Whether the group may match again, or not at all, before the expression following it.
+func (g *group) optional() bool {
+	switch g.mode {
+	case groupMatchZeroOrOne, groupMatchZeroOrMore, groupMatchOneOrMore, groupMatchAtLeast, groupMatchUnique:
+		return true
+	}
+	return false
+}
+
+func describeBranch(n node) string {
+	if s, ok := n.(*strct); ok {
+		return productionName(s.typ)
+	}
+	if s, ok := n.(*sequence); ok && s.next != nil {
+		return "(" + ebnf(n) + ")"
+	}
+	return ebnf(n)
+}
+
+// A token as far as lookahead is concerned.
+type lookaheadToken struct {
+	typ   lexer.TokenType // lexer.EOF if unknown.
+	value string          // Empty if any value of the type matches.
+	any   bool            // Set if any token matches.
+	text  string
+}
+
+func (t lookaheadToken) overlaps(o lookaheadToken) bool {
+	if t.any || o.any {
+		return true
+	}
+	if t.typ != lexer.EOF && o.typ != lexer.EOF && t.typ != o.typ {
+		return false
+	}
+	return t.value == "" || o.value == "" || t.value == o.value
+}
+
+// The more specific of two overlapping tokens.
+func (t lookaheadToken) narrowest(o lookaheadToken) lookaheadToken {
+	if t.any || (t.value == "" && o.value != "") {
+		return o
+	}
+	return t
+}
+
+// The first tokens of some input matching a node. A prefix shorter than the number of tokens asked
+// for is complete, ie. the node can match exactly those tokens, unless it is open.
+type tokenPrefix struct {
+	tokens []lookaheadToken
+	open   bool // Set if the tokens that follow are unknown.
+}
+
+func (t tokenPrefix) String() string {
+	out := make([]string, 0, len(t.tokens))
+	for _, token := range t.tokens {
+		out = append(out, token.text)
+	}
+	return strings.Join(out, " ")
+}
+
+type lookaheadKey struct {
+	node node
+	k    int
+}
+
+type repeatKey struct {
+	group *group
+	k     int
+	first bool
+}
+
+type lookaheadAnalysis struct {
+	lex          lexer.Definition
+	memo         map[lookaheadKey][]tokenPrefix
+	repeats      map[repeatKey][]tokenPrefix
+	visiting     map[lookaheadKey]bool
+	literalTypes map[string]lexer.TokenType
+}
+
+// The length of the longest prefix shared by a prefix of "first" and one of "second", and whether a
+// complete prefix of "first" shadows "second".
+func (a *lookaheadAnalysis) conflict(first, second []tokenPrefix) (lcp int, prefix tokenPrefix, shadow bool) {
+	lcp = -1
+	for _, f := range first {
+		for _, s := range second {
+			n := 0
+			for n < len(f.tokens) && n < len(s.tokens) && f.tokens[n].overlaps(s.tokens[n]) {
+				n++
+			}
+			complete := !f.open && len(f.tokens) < maxReportedLookahead
+			if n == len(f.tokens) && complete && len(s.tokens) > 0 {
+				tokens := make([]lookaheadToken, 0, n+1)
+				for i := range f.tokens {
+					tokens = append(tokens, f.tokens[i].narrowest(s.tokens[i]))
+				}
+				if n < len(s.tokens) {
+					tokens = append(tokens, s.tokens[n])
+				}
+				return n, tokenPrefix{tokens: tokens}, true
+			}
+			if n > lcp {
+				lcp, prefix = n, tokenPrefix{tokens: f.tokens[:n]}
+			}
+		}
+	}
+	return lcp, prefix, false
+}
+
+// Prefixes of up to k tokens of the input n can match.
+func (a *lookaheadAnalysis) prefixes(n node, k int) []tokenPrefix {
+	if k == 0 {
+		return []tokenPrefix{{}}
+	}
+	key := lookaheadKey{n, k}
+	if out, ok := a.memo[key]; ok {
+		return out
+	}
+	// Left recursion contributes nothing further.
+	if a.visiting[key] {
+		return nil
+	}
+	a.visiting[key] = true
+	defer delete(a.visiting, key)
+	out := a.computePrefixes(n, k)
+	a.memo[key] = out
+	return out
+}
+
+func (a *lookaheadAnalysis) computePrefixes(n node, k int) []tokenPrefix {
+	switch n := n.(type) {
+	case *strct:
+		return a.prefixes(n.expr, k)
+	case *union:
+		return a.alternatives(n.disjunction.nodes, k)
+	case *disjunction:
+		return a.alternatives(n.nodes, k)
+	case *sequence:
+		if n.next == nil {
+			return a.prefixes(n.node, k)
+		}
+		return a.concat(k, a.prefixes(n.node, k), func(k int) []tokenPrefix { return a.prefixes(n.next, k) })
+	case *capture:
+		return a.prefixes(n.node, k)
+	case *productionRef:
+		return a.prefixes(n.node, k)
+	case *group:
+		return a.group(n, k)
+	case *reference:
+		return []tokenPrefix{{tokens: []lookaheadToken{{typ: n.typ, text: ebnf(n)}}}}
+	case *literal:
+		return []tokenPrefix{{tokens: []lookaheadToken{a.literal(n.s, n.t)}}}
+	case *literalSet:
+		values := make([]string, 0, len(n.values))
+		for value := range n.values {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+		out := make([]tokenPrefix, 0, len(values))
+		for _, value := range values {
+			out = append(out, tokenPrefix{tokens: []lookaheadToken{a.literal(value, lexer.EOF)}})
+		}
+		return out
+	case *balanced:
+		out := make([]tokenPrefix, 0, len(n.open))
+		for _, open := range n.open {
+			out = append(out, tokenPrefix{tokens: []lookaheadToken{a.literal(open.s, open.t)}, open: true})
+		}
+		return out
+	case *backreference, *negation:
+		return []tokenPrefix{{tokens: []lookaheadToken{{any: true, text: ebnf(n)}}}}
+	case *lookaheadGroup, *lookbehindGroup, *predicate:
+		return []tokenPrefix{{}}
+	default: // Custom parse functions and Parseable types.
+		return []tokenPrefix{{tokens: []lookaheadToken{{any: true, text: ebnf(n)}}, open: true}}
+	}
+}
+
+func (a *lookaheadAnalysis) literal(value string, typ lexer.TokenType) lookaheadToken {
+	if typ == lexer.EOF {
+		typ = a.literalType(value)
+	}
+	return lookaheadToken{typ: typ, value: value, text: fmt.Sprintf("%q", value)}
+}
+
+// The type the lexer gives an untyped literal, or lexer.EOF if it does not lex to a single token.
+func (a *lookaheadAnalysis) literalType(value string) lexer.TokenType {
+	if typ, ok := a.literalTypes[value]; ok {
+		return typ
+	}
+	typ := lexer.EOF
+	if lex, err := a.lex.Lex("", strings.NewReader(value)); err == nil {
+		if tokens, err := lexer.ConsumeAll(lex); err == nil && len(tokens) == 2 {
+			typ = tokens[0].Type
+		}
+	}
+	a.literalTypes[value] = typ
+	return typ
+}
+
+func (a *lookaheadAnalysis) group(g *group, k int) []tokenPrefix {
+	switch g.mode {
+	case groupMatchOnce, groupMatchNonEmpty:
+		return a.prefixes(g.expr, k)
+	case groupMatchZeroOrOne:
+		return a.union([]tokenPrefix{{}}, a.prefixes(g.expr, k))
+	}
+	out := a.repeat(g, k, true)
+	if g.mode == groupMatchOneOrMore || g.mode == groupMatchAtLeast {
+		out = out[1:] // At least one element is required.
+	}
+	return out
+}
+
+// Prefixes of a repetition, unrolled until k tokens have been matched.
+func (a *lookaheadAnalysis) repeat(g *group, k int, first bool) []tokenPrefix {
+	if k == 0 {
+		return []tokenPrefix{{}}
+	}
+	key := repeatKey{g, k, first}
+	if out, ok := a.repeats[key]; ok {
+		return out
+	}
+	element := a.prefixes(g.expr, k)
+	if !first && g.sep != nil {
+		element = a.concat(k, a.prefixes(g.sep, k), func(k int) []tokenPrefix { return a.prefixes(g.expr, k) })
+	}
+	// An iteration matching nothing ends the repetition.
+	nonEmpty := []tokenPrefix{}
+	for _, e := range element {
+		if len(e.tokens) > 0 {
+			nonEmpty = append(nonEmpty, e)
+		}
+	}
+	out := a.union([]tokenPrefix{{}}, a.concat(k, nonEmpty, func(k int) []tokenPrefix { return a.repeat(g, k, false) }))
+	a.repeats[key] = out
+	return out
+}
+
+func (a *lookaheadAnalysis) alternatives(nodes []node, k int) []tokenPrefix {
+	set := newPrefixSet(nil)
+	for _, n := range nodes {
+		if !set.addAll(a.prefixes(n, k)) {
+			break
+		}
+	}
+	return set.prefixes
+}
+
+// Prefixes of up to k tokens of "first" followed by those of the next node, as returned by "next"
+// for the remaining number of tokens.
+func (a *lookaheadAnalysis) concat(k int, first []tokenPrefix, next func(k int) []tokenPrefix) []tokenPrefix {
+	set := newPrefixSet(nil)
+	for _, f := range first {
+		remaining := k - len(f.tokens)
+		if f.open || remaining <= 0 {
+			if !set.add(f) {
+				break
+			}
+			continue
+		}
+		for _, n := range next(remaining) {
+			tokens := make([]lookaheadToken, 0, len(f.tokens)+len(n.tokens))
+			tokens = append(append(tokens, f.tokens...), n.tokens...)
+			if !set.add(tokenPrefix{tokens: tokens, open: n.open}) {
+				return set.prefixes
+			}
+		}
+	}
+	return set.prefixes
+}
+
+func (a *lookaheadAnalysis) union(out, prefixes []tokenPrefix) []tokenPrefix {
+	set := newPrefixSet(out)
+	set.addAll(prefixes)
+	return set.prefixes
+}
+
+// The number of prefixes of a node beyond which further prefixes are ignored.
+const maxLookaheadPrefixes = 256
+
+// A set of distinct prefixes, in the order they were added.
+type prefixSet struct {
+	prefixes []tokenPrefix
+	seen     map[string]bool
+}
+
+func newPrefixSet(prefixes []tokenPrefix) *prefixSet {
+	s := &prefixSet{seen: map[string]bool{}}
+	s.addAll(prefixes)
+	return s
+}
+
+// Add a prefix, returning false once the set is full.
+func (s *prefixSet) add(p tokenPrefix) bool {
+	if len(s.prefixes) >= maxLookaheadPrefixes {
+		return false
+	}
+	key := p.String()
+	if p.open {
+		key += " ..."
+	}
+	if !s.seen[key] {
+		s.seen[key] = true
+		s.prefixes = append(s.prefixes, p)
+	}
+	return true
+}
+
+func (s *prefixSet) addAll(prefixes []tokenPrefix) bool {
+	for _, p := range prefixes {
+		if !s.add(p) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,7 +1,10 @@
 package participle_test
 
 import (
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	require "github.com/alecthomas/assert/v2"
 	"github.com/alecthomas/participle/v2"
@@ -359,3 +362,132 @@ func TestRewindRepetition(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, &grammar{Ints: []string{"int", "int"}, Ident: "one"}, ast)
 }
+
+func TestMaxAlternatives(t *testing.T) {
+	type nested struct {
+		First  *nested `  "(" @@ ")" "a"`
+		Second *nested `| "(" @@ ")" "b"`
+		Leaf   string  `| @Ident`
+	}
+	input := strings.Repeat("(", 10) + "x" + strings.Repeat(")b", 10)
+
+	p := mustTestParser[nested](t, participle.UseLookahead(-1))
+	_, err := p.ParseString("", input)
+	require.NoError(t, err)
+
+	p = mustTestParser[nested](t, participle.UseLookahead(-1), participle.MaxAlternatives(100))
+	_, err = p.ParseString("", input)
+	require.EqualError(t, err, `1:11: more than 100 alternatives attempted at this position, the grammar may be backtracking excessively`)
+
+	_, err = participle.Build[nested](participle.MaxAlternatives(0))
+	require.EqualError(t, err, `MaxAlternatives: limit must be positive, got 0`)
+}
+
+func TestDeadline(t *testing.T) {
+	type nested struct {
+		First  *nested `  "(" @@ ")" "a"`
+		Second *nested `| "(" @@ ")" "b"`
+		Leaf   string  `| @Ident`
+	}
+	p := mustTestParser[nested](t, participle.UseLookahead(-1))
+
+	_, err := p.ParseString("", "((x)b)b", participle.Deadline(time.Minute))
+	require.NoError(t, err)
+
+	// Exponential backtracking that would otherwise take a very long time.
+	input := strings.Repeat("(", 40) + "x" + strings.Repeat(")b", 40)
+	_, err = p.ParseString("", input, participle.Deadline(time.Millisecond))
+	var perr participle.Error
+	require.True(t, errors.As(err, &perr))
+	require.Equal(t, "parse timed out", perr.Message())
+}
+
+type lookaheadCall struct {
+	Name string   `@Ident "("`
+	Args []string `@Ident* ")"`
+}
+
+func TestLookaheadProduction(t *testing.T) {
+	type grammar struct {
+		Words []string         `((?! @@:lookaheadCall) @Ident)*`
+		Calls []*lookaheadCall `@@*`
+	}
+	p := mustTestParser[grammar](t)
+	require.Equal(t, `Grammar = ((?! LookaheadCall) <ident>)* LookaheadCall* .
+LookaheadCall = <ident> "(" <ident>* ")" .`, p.String())
+
+	actual, err := p.ParseString("", `a b c(d) e()`)
+	require.NoError(t, err)
+	require.Equal(t, &grammar{
+		Words: []string{"a", "b"},
+		Calls: []*lookaheadCall{{Name: "c", Args: []string{"d"}}, {Name: "e"}},
+	}, actual)
+
+	// Productions can also be referred to by their EBNF name.
+	type positive struct {
+		Callee string           `((?= @@:LookaheadCall) @Ident "(" ")")?`
+		Calls  []*lookaheadCall `@@*`
+	}
+	actualPositive, err := mustTestParser[positive](t).ParseString("", `a() b()`)
+	require.NoError(t, err)
+	require.Equal(t, &positive{Callee: "a", Calls: []*lookaheadCall{{Name: "b"}}}, actualPositive)
+
+	// Anonymous structs in the grammar are not candidates.
+	type anonymous struct {
+		Callee string           `((?= @@:lookaheadCall) @Ident "(" ")")?`
+		Nested *struct {
+			Word string `"[" @Ident "]"`
+		} `@@?`
+		Calls []*lookaheadCall `@@*`
+	}
+	actualAnonymous, err := mustTestParser[anonymous](t).ParseString("", `a() b()`)
+	require.NoError(t, err)
+	require.Equal(t, "a", actualAnonymous.Callee)
+
+	type unknown struct {
+		Call string `(?= @@:lookaheadCall) @Ident`
+	}
+	_, err = participle.Build[unknown]()
+	require.EqualError(t, err, `@@:lookaheadCall does not refer to a production of the grammar`)
+
+	type outside struct {
+		Call string `@@:lookaheadCall`
+	}
+	_, err = participle.Build[outside]()
+	require.EqualError(t, err, `Call: @@:lookaheadCall can only be used in a lookahead group`)
+}
+
+func TestLookaheadReport(t *testing.T) {
+	type Decl struct {
+		SourceFilename string `  "source_filename" "=" @String`
+		DataLayout     string `| "target" "datalayout" "=" @String`
+		TargetTriple   string `| "target" "triple" "=" @String`
+	}
+	type Module struct {
+		Decls []*Decl `@@*`
+	}
+	p := mustTestParser[Module](t)
+	require.Equal(t, "", p.LookaheadReport())
+
+	type Statement struct {
+		Multiply []string `  @Ident "*" @Ident ";"`
+		Assign   []string `| @Ident "*" "=" @Int ";"`
+	}
+	type Program struct {
+		Statements []*Statement `@@*`
+	}
+	p2 := mustTestParser[Program](t)
+	require.Equal(t,
+		`Statement: (<ident> "*" <ident> ";") and (<ident> "*" "=" <int> ";") share the prefix <ident> "*", requiring lookahead 2 (exceeds UseLookahead(1))`,
+		p2.LookaheadReport())
+	p2 = mustTestParser[Program](t, participle.UseLookahead(2))
+	require.Equal(t,
+		`Statement: (<ident> "*" <ident> ";") and (<ident> "*" "=" <int> ";") share the prefix <ident> "*", requiring lookahead 2`,
+		p2.LookaheadReport())
+
+	type Shadowed struct {
+		Value []string `(@Ident | @Ident "." @Ident)*`
+	}
+	p3 := mustTestParser[Shadowed](t)
+	require.Equal(t, `Shadowed: <ident> shadows (<ident> "." <ident>) on input starting with <ident> "."`, p3.LookaheadReport())
+}
@@ -1,9 +1,14 @@
 package participle
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/alecthomas/participle/v2/lexer"
 )
@@ -21,6 +26,9 @@ type Mapper func(token lexer.Token) (lexer.Token, error)
 // This can be useful to eg. upper-case all tokens of a certain type, or dequote strings.
 //
 // "symbols" specifies the token symbols that the Mapper will be applied to. If empty, all tokens will be mapped.
+//
+// Mappers are applied before tokens are elided, so a Mapper may change the type of a token, including
+// to a type that is elided, eg. to drop noise words.
 func Map(mapper Mapper, symbols ...string) Option {
 	return func(p *parserOptions) error {
 		p.mappers = append(p.mappers, mapperByToken{
@@ -48,6 +56,21 @@ func Unquote(types ...string) Option {
 	}, types...)
 }
 
+// UnquoteWith applies a custom unquoting function to tokens of the given type.
+//
+// This supports quoting schemes other than Go's, such as SQL's doubling of quotes within
+// single-quoted strings. Errors returned by "unquote" are reported at the position of the token.
+func UnquoteWith(tokenType string, unquote func(s string) (string, error)) Option {
+	return Map(func(t lexer.Token) (lexer.Token, error) {
+		value, err := unquote(t.Value)
+		if err != nil {
+			return t, Errorf(t.Pos, "invalid quoted string %q: %s", t.Value, err.Error())
+		}
+		t.Value = value
+		return t, nil
+	}, tokenType)
+}
+
 func unquote(s string) (string, error) {
 	quote := s[0]
 	s = s[1 : len(s)-1]
@@ -72,17 +95,137 @@ func Upper(types ...string) Option {
 }
 
 // Elide drops tokens of the specified types.
+//
+// This overrides any trivia declared by a lexer implementing lexer.TriviaDefinition, so Elide()
+// with no types can be used to elide nothing.
 func Elide(types ...string) Option {
 	return func(p *parserOptions) error {
 		p.elide = append(p.elide, types...)
+		if p.elide == nil {
+			p.elide = []string{}
+		}
+		return nil
+	}
+}
+
+// ElideFunc drops every token for which "elide" returns true, regardless of its type.
+//
+// This allows tokens to be elided by value, eg. to drop "//" comments while keeping "///" doc
+// comments of the same type for the grammar to match. Unlike Elide, dropped tokens are removed from
+// the token stream entirely, so they are not included in "Tokens []lexer.Token" or Trivia fields.
+// Tokens are tested after Map() and Coalesce() are applied. EOF is never dropped.
+func ElideFunc(elide func(token lexer.Token) bool) Option {
+	return func(p *parserOptions) error {
+		p.elideFuncs = append(p.elideFuncs, elide)
+		return nil
+	}
+}
+
+// DefaultElide drops tokens of any lexer symbol with a lower-case name, if Elide is not used.
+// It takes precedence over trivia declared by the lexer.
+//
+// This mirrors the stateful lexer's convention that lower-case rules are not significant. As
+// with Elide, the elided tokens are still present in the token stream, so they will be
+// captured by "Tokens []lexer.Token" fields and can be matched explicitly.
+//
+// The stateful and simple lexers discard tokens of lower-case rules themselves, so this has no
+// effect on them. It is intended for lexers that do emit such tokens, such as those generated by
+// codegen.GenerateLexer() or wrapping an external tokeniser.
+func DefaultElide() Option {
+	return func(p *parserOptions) error {
+		p.defaultElide = true
+		return nil
+	}
+}
+
+// CaptureElided populates "Trivia []lexer.Token" fields with the elided tokens, such as comments,
+// immediately preceding the first token of their struct.
+//
+// Consecutive elided tokens are all collected, in order. Elided tokens at the end of the input,
+// which precede no token, are appended to the Trivia field of the root struct. This allows
+// formatters and documentation generators to recover comments attached to nodes.
+func CaptureElided() Option {
+	return func(p *parserOptions) error {
+		p.captureElided = true
+		return nil
+	}
+}
+
+// Coalesce merges each run of consecutive tokens of one of the specified types into a single token.
+//
+// The merged token has the position of the first token in the run and the concatenated values of
+// all of them. This is typically used with elided tokens such as whitespace, where the boundary
+// between two significant tokens matters but the individual tokens making it up do not.
+func Coalesce(types ...string) Option {
+	return func(p *parserOptions) error {
+		p.coalesce = append(p.coalesce, types...)
+		return nil
+	}
+}
+
+// PositionMapper is an Option that remaps the position of every token from the lexer, and of any
+// lexer error.
+//
+// This is useful when parsing generated or preprocessed sources, eg. to honour "#line" directives,
+// so that errors and injected Pos/EndPos fields refer to the original source rather than the
+// apparent one. Multiple mappers are applied in order.
+func PositionMapper(mapper func(pos lexer.Position) lexer.Position) Option {
+	return func(p *parserOptions) error {
+		if prev := p.positionMapper; prev != nil {
+			p.positionMapper = func(pos lexer.Position) lexer.Position { return mapper(prev(pos)) }
+		} else {
+			p.positionMapper = mapper
+		}
 		return nil
 	}
 }
 
+// SkipPrefix is an Option that skips a leading region of the input matching the regular expression
+// "pattern" before lexing, such as a "#!" line or YAML front-matter.
+//
+// The pattern is anchored to the start of the input, and input that does not match it is lexed
+// in full. Positions of tokens and errors are relative to the original input. eg.
+//
+//	participle.SkipPrefix(`#![^\n]*\n`)
+//	participle.SkipPrefix(`(?s)---\n.*?\n---\n`)
+func SkipPrefix(pattern string) Option {
+	return func(p *parserOptions) error {
+		re, err := regexp.Compile(`^(?:` + pattern + `)`)
+		if err != nil {
+			return fmt.Errorf("SkipPrefix(): %w", err)
+		}
+		p.skipPrefix = re
+		return nil
+	}
+}
+
+// Implemented by lexers that retain the values of tokens before they were mapped.
+type rawValueLexer interface {
+	// Values of mapped tokens before mapping, keyed by offset.
+	rawValues() map[int]string
+}
+
+// Lex s with "def", directly if it implements lexer.StringDefinition.
+func lexString(def lexer.Definition, filename string, s string) (lexer.Lexer, error) {
+	if sl, ok := def.(lexer.StringDefinition); ok {
+		return sl.LexString(filename, s)
+	}
+	return def.Lex(filename, strings.NewReader(s))
+}
+
+// Lex b with "def", directly if it implements lexer.BytesDefinition.
+func lexBytes(def lexer.Definition, filename string, b []byte) (lexer.Lexer, error) {
+	if bl, ok := def.(lexer.BytesDefinition); ok {
+		return bl.LexBytes(filename, b)
+	}
+	return def.Lex(filename, bytes.NewReader(b))
+}
+
 // Apply a Mapping to all tokens coming out of a Lexer.
 type mappingLexerDef struct {
-	l      lexer.Definition
-	mapper Mapper
+	l       lexer.Definition
+	mapper  Mapper
+	keepRaw bool // Retain the values of tokens before mapping, eg. for `raw:"<field>"` tags.
 }
 
 var _ lexer.Definition = &mappingLexerDef{}
@@ -94,12 +237,17 @@ func (m *mappingLexerDef) Lex(filename string, r io.Reader) (lexer.Lexer, error)
 	if err != nil {
 		return nil, err
 	}
-	return &mappingLexer{l, m.mapper}, nil
+	out := &mappingLexer{Lexer: l, mapper: m.mapper}
+	if m.keepRaw {
+		out.raw = map[int]string{}
+	}
+	return out, nil
 }
 
 type mappingLexer struct {
 	lexer.Lexer
 	mapper Mapper
+	raw    map[int]string
 }
 
 func (m *mappingLexer) Next() (lexer.Token, error) {
@@ -107,5 +255,254 @@ func (m *mappingLexer) Next() (lexer.Token, error) {
 	if err != nil {
 		return t, err
 	}
-	return m.mapper(t)
+	value := t.Value
+	t, err = m.mapper(t)
+	if m.raw != nil && t.Value != value {
+		m.raw[t.Pos.Offset] = value
+	}
+	return t, err
+}
+
+func (m *mappingLexer) rawValues() map[int]string { return m.raw }
+
+// Merge runs of tokens of the same type coming out of a Lexer.
+type coalescingLexerDef struct {
+	l     lexer.Definition
+	types map[lexer.TokenType]bool
+}
+
+var (
+	_ lexer.StringDefinition = &coalescingLexerDef{}
+	_ lexer.BytesDefinition  = &coalescingLexerDef{}
+)
+
+func (c *coalescingLexerDef) Symbols() map[string]lexer.TokenType { return c.l.Symbols() }
+
+func (c *coalescingLexerDef) Lex(filename string, r io.Reader) (lexer.Lexer, error) {
+	return c.wrap(c.l.Lex(filename, r))
+}
+
+func (c *coalescingLexerDef) LexString(filename string, s string) (lexer.Lexer, error) {
+	return c.wrap(lexString(c.l, filename, s))
+}
+
+func (c *coalescingLexerDef) LexBytes(filename string, b []byte) (lexer.Lexer, error) {
+	return c.wrap(lexBytes(c.l, filename, b))
+}
+
+func (c *coalescingLexerDef) wrap(l lexer.Lexer, err error) (lexer.Lexer, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &coalescingLexer{Lexer: l, types: c.types}, nil
+}
+
+type coalescingLexer struct {
+	lexer.Lexer
+	types   map[lexer.TokenType]bool
+	pending *lexer.Token
+}
+
+func (c *coalescingLexer) rawValues() map[int]string {
+	if raw, ok := c.Lexer.(rawValueLexer); ok {
+		return raw.rawValues()
+	}
+	return nil
+}
+
+func (c *coalescingLexer) Next() (lexer.Token, error) {
+	var t lexer.Token
+	if c.pending != nil {
+		t, c.pending = *c.pending, nil
+	} else {
+		var err error
+		if t, err = c.Lexer.Next(); err != nil {
+			return t, err
+		}
+	}
+	if !c.types[t.Type] || t.EOF() {
+		return t, nil
+	}
+	for {
+		next, err := c.Lexer.Next()
+		if err != nil {
+			return t, err
+		}
+		if next.Type != t.Type {
+			c.pending = &next
+			return t, nil
+		}
+		t.Value += next.Value
+	}
+}
+
+// Drop tokens coming out of a Lexer, for ElideFunc().
+type filteringLexerDef struct {
+	l     lexer.Definition
+	elide []func(lexer.Token) bool
+}
+
+var (
+	_ lexer.StringDefinition = &filteringLexerDef{}
+	_ lexer.BytesDefinition  = &filteringLexerDef{}
+)
+
+func (f *filteringLexerDef) Symbols() map[string]lexer.TokenType { return f.l.Symbols() }
+
+func (f *filteringLexerDef) Lex(filename string, r io.Reader) (lexer.Lexer, error) {
+	return f.wrap(f.l.Lex(filename, r))
+}
+
+func (f *filteringLexerDef) LexString(filename string, s string) (lexer.Lexer, error) {
+	return f.wrap(lexString(f.l, filename, s))
+}
+
+func (f *filteringLexerDef) LexBytes(filename string, b []byte) (lexer.Lexer, error) {
+	return f.wrap(lexBytes(f.l, filename, b))
+}
+
+func (f *filteringLexerDef) wrap(l lexer.Lexer, err error) (lexer.Lexer, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &filteringLexer{Lexer: l, elide: f.elide}, nil
+}
+
+type filteringLexer struct {
+	lexer.Lexer
+	elide []func(lexer.Token) bool
+}
+
+func (f *filteringLexer) rawValues() map[int]string {
+	if raw, ok := f.Lexer.(rawValueLexer); ok {
+		return raw.rawValues()
+	}
+	return nil
+}
+
+func (f *filteringLexer) Next() (lexer.Token, error) {
+next:
+	for {
+		t, err := f.Lexer.Next()
+		if err != nil || t.EOF() {
+			return t, err
+		}
+		for _, elide := range f.elide {
+			if elide(t) {
+				continue next
+			}
+		}
+		return t, nil
+	}
+}
+
+// Remap the positions of tokens and errors coming out of a Lexer.
+type positionMappingLexerDef struct {
+	l      lexer.Definition
+	mapper func(lexer.Position) lexer.Position
+}
+
+var (
+	_ lexer.StringDefinition = &positionMappingLexerDef{}
+	_ lexer.BytesDefinition  = &positionMappingLexerDef{}
+)
+
+func (m *positionMappingLexerDef) Symbols() map[string]lexer.TokenType { return m.l.Symbols() }
+
+func (m *positionMappingLexerDef) Lex(filename string, r io.Reader) (lexer.Lexer, error) {
+	return m.wrap(m.l.Lex(filename, r))
+}
+
+func (m *positionMappingLexerDef) LexString(filename string, s string) (lexer.Lexer, error) {
+	return m.wrap(lexString(m.l, filename, s))
+}
+
+func (m *positionMappingLexerDef) LexBytes(filename string, b []byte) (lexer.Lexer, error) {
+	return m.wrap(lexBytes(m.l, filename, b))
+}
+
+func (m *positionMappingLexerDef) wrap(l lexer.Lexer, err error) (lexer.Lexer, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &positionMappingLexer{Lexer: l, mapper: m.mapper}, nil
+}
+
+type positionMappingLexer struct {
+	lexer.Lexer
+	mapper func(lexer.Position) lexer.Position
+	raw    map[int]string // Raw values of the underlying lexer, rekeyed by mapped offset.
+}
+
+func (m *positionMappingLexer) rawValues() map[int]string { return m.raw }
+
+func (m *positionMappingLexer) Next() (lexer.Token, error) {
+	t, err := m.Lexer.Next()
+	var lerr *lexer.Error
+	if errors.As(err, &lerr) {
+		return t, &lexer.Error{Msg: lerr.Msg, Pos: m.mapper(lerr.Pos), Remaining: lerr.Remaining}
+	}
+	pos := m.mapper(t.Pos)
+	if inner, ok := m.Lexer.(rawValueLexer); ok {
+		if value, ok := inner.rawValues()[t.Pos.Offset]; ok {
+			if m.raw == nil {
+				m.raw = map[int]string{}
+			}
+			m.raw[pos.Offset] = value
+		}
+	}
+	t.Pos = pos
+	return t, err
+}
+
+// Skip a leading region of the input before lexing, offsetting positions to compensate.
+type prefixSkippingLexerDef struct {
+	l      lexer.Definition
+	prefix *regexp.Regexp
+}
+
+var _ lexer.Definition = &prefixSkippingLexerDef{}
+
+func (s *prefixSkippingLexerDef) Symbols() map[string]lexer.TokenType { return s.l.Symbols() }
+
+func (s *prefixSkippingLexerDef) Lex(filename string, r io.Reader) (lexer.Lexer, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	prefix := s.prefix.Find(data)
+	data = data[len(prefix):]
+	l, err := lexBytes(s.l, filename, data)
+	if err != nil || len(prefix) == 0 {
+		return l, err
+	}
+	out := &prefixSkippingLexer{Lexer: l, offset: len(prefix), lines: bytes.Count(prefix, []byte("\n"))}
+	out.columns = utf8.RuneCount(prefix[bytes.LastIndexByte(prefix, '\n')+1:])
+	return out, nil
+}
+
+type prefixSkippingLexer struct {
+	lexer.Lexer
+	offset  int
+	lines   int
+	columns int // Columns to add to positions on the first line of the remaining input.
+}
+
+func (s *prefixSkippingLexer) Next() (lexer.Token, error) {
+	t, err := s.Lexer.Next()
+	var lerr *lexer.Error
+	if errors.As(err, &lerr) {
+		return t, &lexer.Error{Msg: lerr.Msg, Pos: s.adjust(lerr.Pos), Remaining: lerr.Remaining}
+	}
+	t.Pos = s.adjust(t.Pos)
+	return t, err
+}
+
+func (s *prefixSkippingLexer) adjust(pos lexer.Position) lexer.Position {
+	if pos.Line == 1 {
+		pos.Column += s.columns
+	}
+	pos.Offset += s.offset
+	pos.Line += s.lines
+	return pos
 }
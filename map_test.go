@@ -1,6 +1,8 @@
 package participle_test
 
 import (
+	"errors"
+	"io"
 	"strings"
 	"testing"
 
@@ -53,3 +55,282 @@ func TestUnquote(t *testing.T) {
 	}
 	require.Equal(t, expected, actual)
 }
+
+func TestUnquoteWith(t *testing.T) {
+	type grammar struct {
+		Values []string `@String*`
+	}
+	lex := lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "whitespace", Pattern: `\s+`},
+		{Name: "String", Pattern: `'(?:[^']|'')*'`},
+	})
+	unquoteSQL := func(s string) (string, error) {
+		if strings.ContainsRune(s, '\n') {
+			return "", errors.New("strings cannot span lines")
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	parser := mustTestParser[grammar](t, participle.Lexer(lex), participle.UnquoteWith("String", unquoteSQL))
+	actual, err := parser.ParseString("", `'it''s' 'plain'`)
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Values: []string{"it's", "plain"}}, actual)
+
+	_, err = parser.ParseString("", "'a' 'b\nc'")
+	require.EqualError(t, err, `1:5: invalid quoted string "'b\nc'": strings cannot span lines`)
+}
+
+func TestRawValue(t *testing.T) {
+	type value struct {
+		Text    string `parser:"@(String | RawString | Ident)" raw:"RawText"`
+		RawText string
+	}
+	type grammar struct {
+		Values []*value `@@*`
+	}
+	lex := lexer.MustSimple([]lexer.SimpleRule{
+		{"whitespace", `\s+`},
+		{"Ident", `\w+`},
+		{"String", `\"(?:[^\"]|\\.)*\"`},
+		{"RawString", "`[^`]*`"},
+	})
+	parser := mustTestParser[grammar](t, participle.Lexer(lex), participle.Unquote("String", "RawString"))
+	actual, err := parser.ParseString("", "plain \"quoted\\tstring\" `backtick`")
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Values: []*value{
+		{Text: "plain", RawText: "plain"},
+		{Text: "quoted\tstring", RawText: `"quoted\tstring"`},
+		{Text: "backtick", RawText: "`backtick`"},
+	}}, actual)
+
+	// Raw values are retained when positions are also remapped.
+	parser = mustTestParser[grammar](t, participle.Lexer(lex), participle.Unquote("String"),
+		participle.PositionMapper(func(pos lexer.Position) lexer.Position {
+			pos.Offset += 100
+			return pos
+		}))
+	actual, err = parser.ParseString("", `"a\tb"`)
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Values: []*value{{Text: "a\tb", RawText: `"a\tb"`}}}, actual)
+
+	type invalid struct {
+		Text string `parser:"@String" raw:"Missing"`
+	}
+	_, err = participle.Build[invalid](participle.Lexer(lex))
+	require.EqualError(t, err, `Text: raw field "Missing" must be a string field of participle_test.invalid`)
+}
+
+func TestCoalesce(t *testing.T) {
+	type grammar struct {
+		Words []string `(@Ident Whitespace?)+`
+	}
+	def := lexer.MustSimple([]lexer.SimpleRule{
+		{"Whitespace", `\s`},
+		{"Ident", `\w+`},
+	})
+	parser := mustTestParser[grammar](t, participle.Lexer(def), participle.Coalesce("Whitespace"))
+	actual, err := parser.Lex("", strings.NewReader("hello  \t world "))
+	require.NoError(t, err)
+
+	expected := []lexer.Token{
+		{Type: -3, Value: "hello", Pos: lexer.Position{Filename: "", Offset: 0, Line: 1, Column: 1}},
+		{Type: -2, Value: "  \t ", Pos: lexer.Position{Filename: "", Offset: 5, Line: 1, Column: 6}},
+		{Type: -3, Value: "world", Pos: lexer.Position{Filename: "", Offset: 9, Line: 1, Column: 10}},
+		{Type: -2, Value: " ", Pos: lexer.Position{Filename: "", Offset: 14, Line: 1, Column: 15}},
+		{Type: lexer.EOF, Value: "", Pos: lexer.Position{Filename: "", Offset: 15, Line: 1, Column: 16}},
+	}
+	require.Equal(t, expected, actual)
+
+	ast, err := parser.ParseString("", "hello  \t world ")
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Words: []string{"hello", "world"}}, ast)
+
+	_, err = participle.Build[grammar](participle.Lexer(def), participle.Coalesce("Comment"))
+	require.EqualError(t, err, `Coalesce() uses unknown token "Comment"`)
+}
+
+func TestCaptureElided(t *testing.T) {
+	type statement struct {
+		Trivia []lexer.Token
+		Name   string `@Ident ";"`
+	}
+	type grammar struct {
+		Trivia     []lexer.Token
+		Statements []*statement `@@*`
+	}
+	def := lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "Comment", Pattern: `//[^\n]*`},
+		{Name: "Whitespace", Pattern: `\s+`},
+		{Name: "Ident", Pattern: `\w+`},
+		{Name: "Punct", Pattern: `;`},
+	})
+	options := []participle.Option{participle.Lexer(def), participle.Elide("Comment", "Whitespace")}
+	parser := mustTestParser[grammar](t, append(options, participle.CaptureElided())...)
+	ast, err := parser.ParseString("", "// a\na;\n// b1\n// b2\nb; c; // end\n")
+	require.NoError(t, err)
+	values := func(tokens []lexer.Token) (out []string) {
+		for _, t := range tokens {
+			out = append(out, t.Value)
+		}
+		return out
+	}
+	// The root has the leading trivia of its first token, followed by any trailing trivia.
+	require.Equal(t, []string{"// a", "\n", " ", "// end", "\n"}, values(ast.Trivia))
+	require.Equal(t, 3, len(ast.Statements))
+	require.Equal(t, []string{"// a", "\n"}, values(ast.Statements[0].Trivia))
+	require.Equal(t, []string{"\n", "// b1", "\n", "// b2", "\n"}, values(ast.Statements[1].Trivia))
+	require.Equal(t, []string{" "}, values(ast.Statements[2].Trivia))
+
+	// Without the option, Trivia fields are not populated.
+	ast, err = mustTestParser[grammar](t, options...).ParseString("", "// a\na;")
+	require.NoError(t, err)
+	require.Equal(t, 0, len(ast.Trivia))
+	require.Equal(t, 0, len(ast.Statements[0].Trivia))
+}
+
+func TestPositionMapper(t *testing.T) {
+	type statement struct {
+		Pos  lexer.Position
+		Name string `@Ident ";"`
+	}
+	type grammar struct {
+		Statements []*statement `@@*`
+	}
+	// Lines of the generated source originate from line 100 of "original.src".
+	parser := mustTestParser[grammar](t, participle.PositionMapper(func(pos lexer.Position) lexer.Position {
+		pos.Filename = "original.src"
+		pos.Line += 99
+		return pos
+	}))
+	ast, err := parser.ParseString("generated.go", "a;\nb;")
+	require.NoError(t, err)
+	require.Equal(t, lexer.Position{Filename: "original.src", Offset: 3, Line: 101, Column: 1}, ast.Statements[1].Pos)
+
+	_, err = parser.ParseString("generated.go", "a;\nb c;")
+	require.EqualError(t, err, `original.src:101:3: unexpected token "c" (expected ";")`)
+
+	_, err = parser.ParseString("generated.go", "a;\n`")
+	require.EqualError(t, err, `original.src:101:2: literal not terminated`)
+}
+
+// Records which of its methods were used to lex input.
+type recordingDefinition struct {
+	*lexer.StatefulDefinition
+	used []string
+}
+
+func (r *recordingDefinition) Lex(filename string, reader io.Reader) (lexer.Lexer, error) {
+	r.used = append(r.used, "Lex")
+	return r.StatefulDefinition.Lex(filename, reader)
+}
+
+func (r *recordingDefinition) LexString(filename string, s string) (lexer.Lexer, error) {
+	r.used = append(r.used, "LexString")
+	return r.StatefulDefinition.LexString(filename, s)
+}
+
+func (r *recordingDefinition) LexBytes(filename string, b []byte) (lexer.Lexer, error) {
+	r.used = append(r.used, "LexBytes")
+	return r.StatefulDefinition.LexBytes(filename, b)
+}
+
+func TestWrappingLexersUseFastPaths(t *testing.T) {
+	type grammar struct {
+		Words []string `(@Ident Whitespace?)+`
+	}
+	def := &recordingDefinition{StatefulDefinition: lexer.MustSimple([]lexer.SimpleRule{
+		{"Whitespace", `\s`},
+		{"Ident", `\w+`},
+	})}
+	parser := mustTestParser[grammar](t, participle.Lexer(def), participle.Coalesce("Whitespace"),
+		participle.PositionMapper(func(pos lexer.Position) lexer.Position { return pos }))
+	_, err := parser.ParseString("", "hello  world")
+	require.NoError(t, err)
+	_, err = parser.ParseBytes("", []byte("hello  world"))
+	require.NoError(t, err)
+	_, err = parser.Parse("", strings.NewReader("hello  world"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"LexString", "LexBytes", "Lex"}, def.used)
+}
+
+func TestSkipPrefix(t *testing.T) {
+	type statement struct {
+		Pos  lexer.Position
+		Name string `@Ident ";"`
+	}
+	type grammar struct {
+		Statements []*statement `@@*`
+	}
+	parser := mustTestParser[grammar](t, participle.SkipPrefix(`#![^\n]*\n`))
+	ast, err := parser.ParseString("", "#!/usr/bin/env run\na;\nb;")
+	require.NoError(t, err)
+	require.Equal(t, lexer.Position{Offset: 19, Line: 2, Column: 1}, ast.Statements[0].Pos)
+	require.Equal(t, lexer.Position{Offset: 22, Line: 3, Column: 1}, ast.Statements[1].Pos)
+
+	// Input without the prefix is parsed in full.
+	ast, err = parser.ParseString("", "a;")
+	require.NoError(t, err)
+	require.Equal(t, lexer.Position{Line: 1, Column: 1}, ast.Statements[0].Pos)
+
+	// Positions on the first line after a prefix not ending in a newline are offset by its columns.
+	parser = mustTestParser[grammar](t, participle.SkipPrefix(`(?s)---\n.*?\n---`))
+	_, err = parser.ParseString("", "---\ntitle: x\n--- a; b c;")
+	require.EqualError(t, err, `3:10: unexpected token "c" (expected ";")`)
+
+	_, err = participle.Build[grammar](participle.SkipPrefix(`(`))
+	require.EqualError(t, err, "SkipPrefix(): error parsing regexp: missing closing ): `^(?:()`")
+}
+
+func TestMapAllTokensBeforeElision(t *testing.T) {
+	type grammar struct {
+		Keywords []string `@Keyword*`
+		Idents   []string `@Ident*`
+	}
+	lex := lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "Keyword", Pattern: `SELECT|FROM`},
+		{Name: "Ident", Pattern: `\w+`},
+		{Name: "Whitespace", Pattern: `\s+`},
+	})
+	symbols := lex.Symbols()
+	// Normalise the case of every token, and retype the noise word "please" as elided whitespace.
+	parser := mustTestParser[grammar](t, participle.Lexer(lex), participle.Elide("Whitespace"),
+		participle.Map(func(token lexer.Token) (lexer.Token, error) {
+			token.Value = strings.ToLower(token.Value)
+			if token.Value == "please" {
+				token.Type = symbols["Whitespace"]
+			}
+			return token, nil
+		}))
+	actual, err := parser.ParseString("", `SELECT Please FROM A please B`)
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Keywords: []string{"select", "from"}, Idents: []string{"a", "b"}}, actual)
+}
+
+func TestElideFunc(t *testing.T) {
+	type Decl struct {
+		Doc  []string `@Comment*`
+		Name string   `"let" @Ident`
+	}
+	type grammar struct {
+		Decls []*Decl `@@*`
+	}
+	lex := lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "Comment", Pattern: `//[^\n]*`},
+		{Name: "Ident", Pattern: `\w+`},
+		{Name: "Whitespace", Pattern: `\s+`},
+	})
+	parser := mustTestParser[grammar](t, participle.Lexer(lex), participle.Elide("Whitespace"),
+		participle.ElideFunc(func(token lexer.Token) bool {
+			return token.Type == lex.Symbols()["Comment"] && !strings.HasPrefix(token.Value, "///")
+		}))
+	actual, err := parser.ParseString("", "/// Doc.\n// Note.\nlet a // Trailing.\nlet b")
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Decls: []*Decl{{Doc: []string{"/// Doc."}, Name: "a"}, {Name: "b"}}}, actual)
+
+	tokens, err := parser.Lex("", strings.NewReader("// Note.\nlet"))
+	require.NoError(t, err)
+	require.Equal(t, []lexer.Token{
+		{Type: lex.Symbols()["Whitespace"], Value: "\n", Pos: lexer.Position{Offset: 8, Line: 1, Column: 9}},
+		{Type: lex.Symbols()["Ident"], Value: "let", Pos: lexer.Position{Offset: 9, Line: 2, Column: 1}},
+		{Type: lexer.EOF, Pos: lexer.Position{Offset: 12, Line: 2, Column: 4}},
+	}, tokens)
+}
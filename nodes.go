@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
+	"unsafe"
 
 	"github.com/alecthomas/participle/v2/lexer"
 )
@@ -19,6 +22,7 @@ var (
 	tokenType           = reflect.TypeOf(lexer.Token{})
 	tokensType          = reflect.TypeOf([]lexer.Token{})
 	captureType         = reflect.TypeOf((*Capture)(nil)).Elem()
+	tokenCaptureType    = reflect.TypeOf((*TokenCapture)(nil)).Elem()
 	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
 	parseableType       = reflect.TypeOf((*Parseable)(nil)).Elem()
 
@@ -75,8 +79,9 @@ func (p *parseable) Parse(ctx *parseContext, parent reflect.Value) (out []reflec
 
 // @@ (but for a custom production)
 type custom struct {
-	typ     reflect.Type
-	parseFn reflect.Value
+	typ         reflect.Type
+	parseFn     reflect.Value
+	withContext bool
 }
 
 func (c *custom) String() string   { return ebnf(c) }
@@ -84,7 +89,11 @@ func (c *custom) GoString() string { return c.typ.Name() }
 
 func (c *custom) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
 	defer ctx.printTrace(c)()
-	results := c.parseFn.Call([]reflect.Value{reflect.ValueOf(&ctx.PeekingLexer)})
+	arg := reflect.ValueOf(&ctx.PeekingLexer)
+	if c.withContext {
+		arg = reflect.ValueOf(&ParseContext{PeekingLexer: &ctx.PeekingLexer, ctx: ctx})
+	}
+	results := c.parseFn.Call([]reflect.Value{arg})
 	if err, _ := results[1].Interface().(error); err != nil {
 		if err == NextMatch {
 			return nil, nil
@@ -98,6 +107,10 @@ func (c *custom) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.V
 type union struct {
 	unionDef
 	disjunction disjunction
+	// Only used by UnionByToken().
+	byValue  map[string]int
+	byType   map[lexer.TokenType]int
+	expected string
 }
 
 func (u *union) String() string   { return ebnf(u) }
@@ -105,6 +118,9 @@ func (u *union) GoString() string { return u.typ.Name() }
 
 func (u *union) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
 	defer ctx.printTrace(u)()
+	if u.keys != nil {
+		return u.parseByToken(ctx, parent)
+	}
 	vals, err := u.disjunction.Parse(ctx, parent)
 	if err != nil {
 		return nil, err
@@ -115,14 +131,44 @@ func (u *union) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Va
 	return vals, nil
 }
 
+// Parse only the member selected by the next token.
+func (u *union) parseByToken(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	t := ctx.Peek()
+	i, ok := u.byValue[t.Value]
+	if !ok {
+		i, ok = u.byType[t.Type]
+	}
+	if !ok {
+		return nil, &UnexpectedTokenError{Unexpected: *t, Expect: u.expected}
+	}
+	member := u.disjunction.nodes[i]
+	vals, err := member.Parse(ctx, parent)
+	if err != nil {
+		return nil, err
+	}
+	if vals == nil {
+		return nil, &UnexpectedTokenError{Unexpected: *t, expectNode: member}
+	}
+	for j := range vals {
+		vals[j] = maybeRef(u.members[i], vals[j]).Convert(u.typ)
+	}
+	return vals, nil
+}
+
 // @@
 type strct struct {
-	typ              reflect.Type
-	expr             node
-	tokensFieldIndex []int
-	posFieldIndex    []int
-	endPosFieldIndex []int
-	usages           int
+	typ                   reflect.Type
+	expr                  node
+	tokensFieldIndex      []int
+	triviaFieldIndex      []int
+	posFieldIndex         []int
+	endPosFieldIndex      []int
+	alternativeFieldIndex []int
+	depthFieldIndex       []int
+	sourceFieldIndex      []int
+	usages                int
+	doc                   string
+	leftRecursive         bool // Set with AllowLeftRecursion() if the production refers to itself before consuming input.
 }
 
 func newStrct(typ reflect.Type) *strct {
@@ -142,6 +188,22 @@ func newStrct(typ reflect.Type) *strct {
 	if ok && field.Type == tokensType {
 		s.tokensFieldIndex = field.Index
 	}
+	field, ok = typ.FieldByName("Trivia")
+	if ok && field.Type == tokensType {
+		s.triviaFieldIndex = field.Index
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Name == "_" && s.doc == "" {
+			s.doc = field.Tag.Get("doc")
+		} else if depthField(field) && field.Type.Kind() == reflect.Int && field.PkgPath == "" {
+			s.depthFieldIndex = field.Index
+		} else if alternativeField(field) && isIntKind(field.Type.Kind()) && field.PkgPath == "" {
+			s.alternativeFieldIndex = field.Index
+		} else if sourceField(field) && field.Type.Kind() == reflect.String && field.PkgPath == "" {
+			s.sourceFieldIndex = field.Index
+		}
+	}
 	return s
 }
 
@@ -149,12 +211,92 @@ func (s *strct) String() string   { return ebnf(s) }
 func (s *strct) GoString() string { return s.typ.Name() }
 
 func (s *strct) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	if s.leftRecursive {
+		return s.parseLeftRecursive(ctx, parent)
+	}
+	return s.parse(ctx, parent)
+}
+
+// Parse a left-recursive production by growing a seed. The production is parsed repeatedly at the
+// same position, with its reference to itself matching the result of the previous attempt, until an
+// attempt fails to consume more input than the last. eg. for `Expr = Expr "+" Term | Term`, the
+// attempts on "1 + 2 + 3" match "1", "1 + 2" and then "1 + 2 + 3".
+func (s *strct) parseLeftRecursive(ctx *parseContext, parent reflect.Value) ([]reflect.Value, error) {
+	key := seedKey{s, ctx.RawCursor()}
+	if grown, ok := ctx.seeds[key]; ok {
+		if grown.value == nil {
+			return nil, nil
+		}
+		ctx.LoadCheckpoint(grown.end)
+		ctx.matchedEOF = ctx.matchedEOF || grown.matchedEOF
+		return grown.value, nil
+	}
+	if ctx.seeds == nil {
+		ctx.seeds = map[seedKey]*seed{} // Shared with the branches parsing the seed.
+	}
+	grown := &seed{}
+	ctx.seeds[key] = grown
+	defer delete(ctx.seeds, key)
+	var accepted *parseContext
+	for {
+		branch := ctx.Branch()
+		out, err := s.parse(branch, parent)
+		if err != nil {
+			// An attempt to grow the seed fails like an alternative, without error unless it progressed
+			// beyond the lookahead from the end of the seed.
+			if accepted != nil && (ctx.hasInfiniteLookahead() || branch.Cursor() <= grown.end.Cursor()+ctx.lookahead) {
+				ctx.trackError(err, branch)
+				break
+			}
+			ctx.Accept(branch)
+			return out, err
+		}
+		if out == nil || (accepted != nil && branch.RawCursor() <= grown.end.RawCursor()) {
+			break
+		}
+		grown.value, grown.end, accepted = out, branch.MakeCheckpoint(), branch
+		grown.matchedEOF = branch.matchedEOF
+	}
+	if accepted == nil {
+		return nil, nil
+	}
+	ctx.Accept(accepted)
+	return grown.value, nil
+}
+
+func (s *strct) parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
 	defer ctx.printTrace(s)()
-	sv := reflect.New(s.typ).Elem()
+	defer ctx.leaveProduction()
+	if err := ctx.enterProduction(); err != nil {
+		return nil, err
+	}
+	if ctx.rootRepetition != nil {
+		ctx.productions++
+		defer func() { ctx.productions-- }()
+	}
+	var sv reflect.Value
+	// The root of a left-recursive grammar is not reused, as earlier attempts become its operands.
+	if ctx.reuse.IsValid() && ctx.reuse.Type() == s.typ && !s.leftRecursive {
+		sv, ctx.reuse = ctx.reuse, reflect.Value{}
+	} else {
+		sv = reflect.New(s.typ).Elem()
+	}
 	start := ctx.RawCursor()
 	t := ctx.Peek()
 	s.maybeInjectStartToken(t, sv)
-	if out, err = s.expr.Parse(ctx, sv); err != nil {
+	if s.depthFieldIndex != nil {
+		sv.FieldByIndex(s.depthFieldIndex).SetInt(int64(ctx.nesting[s.typ]))
+		ctx.nesting[s.typ]++
+		defer func() { ctx.nesting[s.typ]-- }()
+	}
+	if before, ok := sv.Addr().Interface().(BeforeParse); ok {
+		if err := before.BeforeParse(&ctx.PeekingLexer); err == NextMatch {
+			return nil, nil
+		} else if err != nil {
+			return []reflect.Value{sv}, withPosition(t.Pos, err)
+		}
+	}
+	if out, err = s.parseExpr(ctx, sv); err != nil {
 		_ = ctx.Apply() // Best effort to give partial AST.
 		ctx.MaybeUpdateError(err)
 		return []reflect.Value{sv}, err
@@ -162,10 +304,55 @@ func (s *strct) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Va
 		return nil, nil
 	}
 	end := ctx.RawCursor()
-	t = ctx.RawPeek()
-	s.maybeInjectEndToken(t, sv)
-	s.maybeInjectTokens(ctx.Range(start, end), sv)
-	return []reflect.Value{sv}, ctx.Apply()
+	tokens := ctx.Range(start, end)
+	s.maybeInjectEndPos(ctx, tokens, sv)
+	s.maybeInjectTokens(tokens, sv)
+	s.maybeInjectSource(ctx, tokens, sv)
+	s.maybeInjectTrivia(ctx, tokens, sv)
+	if err := ctx.Apply(); err != nil {
+		return []reflect.Value{sv}, err
+	}
+	if err := ctx.checkStringCaptures(sv); err != nil {
+		return []reflect.Value{sv}, err
+	}
+	return []reflect.Value{sv}, s.maybeAfterParse(ctx, t.Pos, sv)
+}
+
+func (s *strct) maybeAfterParse(ctx *parseContext, pos lexer.Position, v reflect.Value) error {
+	// Fields are not populated by Validate(), so hooks would see an incomplete struct.
+	if ctx.validate {
+		return nil
+	}
+	after, ok := v.Addr().Interface().(AfterParse)
+	if !ok {
+		return nil
+	}
+	return withPosition(pos, after.AfterParse())
+}
+
+// Give errors, such as those returned from BeforeParse and AfterParse hooks, a position if they
+// don't have one.
+func withPosition(pos lexer.Position, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(Error); ok {
+		return err
+	}
+	return &wrappingParseError{err: err, ParseError: ParseError{Msg: err.Error(), Pos: pos}}
+}
+
+// Parse the struct's expression, recording the matched alternative if requested.
+func (s *strct) parseExpr(ctx *parseContext, sv reflect.Value) ([]reflect.Value, error) {
+	d, ok := s.expr.(*disjunction)
+	if !ok || s.alternativeFieldIndex == nil {
+		return s.expr.Parse(ctx, sv)
+	}
+	out, alternative, err := d.parseAlternatives(ctx, sv)
+	if err == nil && out != nil {
+		sv.FieldByIndex(s.alternativeFieldIndex).SetInt(int64(alternative))
+	}
+	return out, err
 }
 
 func (s *strct) maybeInjectStartToken(token *lexer.Token, v reflect.Value) {
@@ -176,12 +363,27 @@ func (s *strct) maybeInjectStartToken(token *lexer.Token, v reflect.Value) {
 	f.Set(reflect.ValueOf(token.Pos).Convert(f.Type()))
 }
 
-func (s *strct) maybeInjectEndToken(token *lexer.Token, v reflect.Value) {
+// EndPos is the position immediately after the last non-elided token of the struct.
+//
+// If the struct did not consume any non-elided tokens, it is the position of the next token.
+func (s *strct) maybeInjectEndPos(ctx *parseContext, tokens []lexer.Token, v reflect.Value) {
 	if s.endPosFieldIndex == nil {
 		return
 	}
+	pos := ctx.RawPeek().Pos
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if !ctx.IsElided(tokens[i].Type) {
+			pos = tokens[i].Pos
+			value := tokens[i].Value
+			if raw, ok := ctx.rawValues[pos.Offset]; ok {
+				value = raw
+			}
+			pos.Advance(value)
+			break
+		}
+	}
 	f := v.FieldByIndex(s.endPosFieldIndex)
-	f.Set(reflect.ValueOf(token.Pos).Convert(f.Type()))
+	f.Set(reflect.ValueOf(pos).Convert(f.Type()))
 }
 
 func (s *strct) maybeInjectTokens(tokens []lexer.Token, v reflect.Value) {
@@ -191,6 +393,49 @@ func (s *strct) maybeInjectTokens(tokens []lexer.Token, v reflect.Value) {
 	v.FieldByIndex(s.tokensFieldIndex).Set(reflect.ValueOf(tokens))
 }
 
+// Source is the input from the start of the first non-elided token of the struct to the end of the
+// last, with CaptureSource().
+func (s *strct) maybeInjectSource(ctx *parseContext, tokens []lexer.Token, v reflect.Value) {
+	if s.sourceFieldIndex == nil || ctx.source == nil {
+		return
+	}
+	start, end := -1, -1
+	for _, t := range tokens {
+		if ctx.IsElided(t.Type) {
+			continue
+		}
+		if start < 0 {
+			start = t.Pos.Offset
+		}
+		value := t.Value
+		if raw, ok := ctx.rawValues[t.Pos.Offset]; ok {
+			value = raw
+		}
+		end = t.Pos.Offset + len(value)
+	}
+	source := ""
+	if start >= 0 && start <= end && end <= len(*ctx.source) {
+		source = (*ctx.source)[start:end]
+	}
+	v.FieldByIndex(s.sourceFieldIndex).SetString(source)
+}
+
+// Trivia is the elided tokens preceding the first non-elided token of the struct, with CaptureElided().
+func (s *strct) maybeInjectTrivia(ctx *parseContext, tokens []lexer.Token, v reflect.Value) {
+	if s.triviaFieldIndex == nil || !ctx.captureElided {
+		return
+	}
+	n := 0
+	for n < len(tokens) && ctx.IsElided(tokens[n].Type) {
+		n++
+	}
+	if n == len(tokens) {
+		// No non-elided tokens were consumed, so the trivia precedes the next token instead.
+		return
+	}
+	v.FieldByIndex(s.triviaFieldIndex).Set(reflect.ValueOf(tokens[:n:n]))
+}
+
 type groupMatchMode int
 
 func (g groupMatchMode) String() string {
@@ -205,6 +450,10 @@ func (g groupMatchMode) String() string {
 		return "n+"
 	case groupMatchNonEmpty:
 		return "n!"
+	case groupMatchUnique:
+		return "n!:unique"
+	case groupMatchAtLeast:
+		return "n!:min"
 	}
 	panic("??")
 }
@@ -215,6 +464,8 @@ const (
 	groupMatchZeroOrMore                = iota
 	groupMatchOneOrMore                 = iota
 	groupMatchNonEmpty                  = iota
+	groupMatchUnique                    = iota
+	groupMatchAtLeast                   = iota
 )
 
 // ( <expr> ) - match once
@@ -222,17 +473,31 @@ const (
 // ( <expr> )+ - match one or more times
 // ( <expr> )? - match zero or once
 // ( <expr> )! - must be a non-empty match
+// ( <expr> )!:unique - match each alternative of <expr> at most once, in any order
+// ( <expr> )!:min(n, "message") - match at least n times, otherwise fail with "message"
+// ( <expr> )*%<sep> - match zero or more times, separated by <sep>
+// ( <expr> )+%<sep>? - match one or more times, separated by <sep>, with an optional trailing <sep>
 //
 // The additional modifier "!" forces the content of the group to be non-empty if it does match.
 type group struct {
 	expr node
 	mode groupMatchMode
+	// Only used by groupMatchAtLeast.
+	min     int
+	message string
+	// Only used by groupMatchZeroOrMore and groupMatchOneOrMore.
+	sep      node
+	trailing bool
 }
 
 func (g *group) String() string   { return ebnf(g) }
 func (g *group) GoString() string { return fmt.Sprintf("group{%s}", g.mode) }
 func (g *group) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
 	defer ctx.printTrace(g)()
+	// Nested uses of the root production share its repetition, but do not continue after errors.
+	if g == ctx.rootRepetition && ctx.productions == 0 {
+		return g.parseContinuing(ctx, parent)
+	}
 	// Configure min/max matches.
 	min := 1
 	max := 1
@@ -249,6 +514,8 @@ func (g *group) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Va
 		return out, nil
 	case groupMatchOnce:
 		return g.expr.Parse(ctx, parent)
+	case groupMatchUnique:
+		return g.parseUnique(ctx, parent)
 	case groupMatchZeroOrOne:
 		min = 0
 	case groupMatchZeroOrMore:
@@ -257,11 +524,17 @@ func (g *group) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Va
 	case groupMatchOneOrMore:
 		min = 1
 		max = MaxIterations
+	case groupMatchAtLeast:
+		min = g.min
+		max = MaxIterations
 	}
 	matches := 0
 	for ; matches < max; matches++ {
+		if err := ctx.checkDeadline(); err != nil {
+			return out, err
+		}
 		branch := ctx.Branch()
-		v, err := g.expr.Parse(branch, parent)
+		v, last, err := g.parseElement(branch, parent, matches > 0)
 		if err != nil {
 			ctx.MaybeUpdateError(err)
 			// Optional part failed to match.
@@ -273,7 +546,8 @@ func (g *group) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Va
 		}
 		out = append(out, v...)
 		ctx.Accept(branch)
-		if v == nil {
+		// With MatchEOF(), EOF is matched at most once by a repetition, as it does not advance the lexer.
+		if v == nil || last || ctx.matchedEOF {
 			break
 		}
 	}
@@ -282,6 +556,9 @@ func (g *group) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Va
 	if matches >= MaxIterations {
 		return nil, Errorf(t.Pos, "too many iterations of %s (> %d)", g, MaxIterations)
 	}
+	if g.mode == groupMatchAtLeast && matches < min {
+		return out, g.atLeastError(t)
+	}
 	// avoid returning errors in parent nodes if the group is optional
 	if matches > 0 && matches < min {
 		return out, Errorf(t.Pos, "sub-expression %s must match at least once", g)
@@ -293,6 +570,119 @@ func (g *group) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Va
 	return out, nil
 }
 
+// Parse a single element of the group, preceded by the separator if this is not the first element.
+//
+// "last" is true if a trailing separator was matched.
+func (g *group) parseElement(ctx *parseContext, parent reflect.Value, separated bool) (out []reflect.Value, last bool, err error) {
+	if g.sep == nil || !separated {
+		out, err = g.expr.Parse(ctx, parent)
+		return out, false, err
+	}
+	out, err = g.sep.Parse(ctx, parent)
+	if err != nil || out == nil {
+		return out, false, err
+	}
+	branch := ctx.Branch()
+	v, err := g.expr.Parse(branch, parent)
+	if err == nil && v != nil {
+		ctx.Accept(branch)
+		return append(out, v...), false, nil
+	}
+	if err == nil {
+		err = &UnexpectedTokenError{Unexpected: *branch.Peek(), expectNode: g.expr}
+	}
+	if !g.trailing || ctx.Stop(err, branch) {
+		return append(out, v...), false, err
+	}
+	ctx.MaybeUpdateError(err)
+	return out, true, nil
+}
+
+func (g *group) atLeastError(t *lexer.Token) error {
+	switch {
+	case g.message != "":
+		return &ParseError{Msg: g.message, Pos: t.Pos}
+	case g.min == 1:
+		return Errorf(t.Pos, "expected at least one %s", g.expr)
+	default:
+		return Errorf(t.Pos, "expected at least %d %s", g.min, g.expr)
+	}
+}
+
+// Parse the root repetition for ContinueOnError(), recording and skipping over elements that fail to parse.
+func (g *group) parseContinuing(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	for !ctx.Peek().EOF() {
+		branch := ctx.Branch()
+		v, err := g.expr.Parse(branch, parent)
+		if err == nil && v != nil {
+			out = append(out, v...)
+			progressed := branch.Cursor() > ctx.Cursor()
+			ctx.Accept(branch)
+			if !progressed {
+				break
+			}
+			continue
+		}
+		if err == nil {
+			err = &UnexpectedTokenError{Unexpected: *ctx.Peek(), expectNode: g.expr}
+		}
+		ctx.errors = append(ctx.errors, withPosition(ctx.Peek().Pos, branch.DeepestError(err)))
+		// Skip to the next token at which an element parses successfully.
+		for ctx.Next(); !ctx.Peek().EOF(); ctx.Next() {
+			if v, err := g.expr.Parse(ctx.Branch(), parent); err == nil && v != nil {
+				break
+			}
+		}
+	}
+	if out == nil && (g.mode == groupMatchZeroOrMore || len(ctx.errors) > 0) {
+		out = []reflect.Value{}
+	}
+	return out, nil
+}
+
+// Match each alternative of the group expression at most once, in any order.
+func (g *group) parseUnique(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	alternatives := []node{g.expr}
+	if d, ok := g.expr.(*disjunction); ok {
+		alternatives = d.nodes
+	}
+	matched := make([]bool, len(alternatives))
+	for {
+		progressed := false
+		for i, a := range alternatives {
+			branch := ctx.Branch()
+			v, err := a.Parse(branch, parent)
+			if err != nil {
+				ctx.MaybeUpdateError(err)
+				if ctx.Stop(err, branch) {
+					return append(out, v...), err
+				}
+				continue
+			}
+			// Alternatives that match without consuming input can not be repeated, so are never duplicates.
+			if v == nil || branch.Cursor() == ctx.Cursor() {
+				continue
+			}
+			if matched[i] {
+				t := ctx.Peek()
+				return out, Errorf(t.Pos, "%s can only be matched once", a)
+			}
+			matched[i] = true
+			progressed = true
+			out = append(out, v...)
+			ctx.Accept(branch)
+			break
+		}
+		if !progressed {
+			break
+		}
+	}
+	if out == nil {
+		out = []reflect.Value{}
+	}
+	return out, nil
+}
+
 // (?= <expr> ) for positive lookahead, (?! <expr> ) for negative lookahead; neither consumes input
 type lookaheadGroup struct {
 	expr     node
@@ -315,6 +705,196 @@ func (l *lookaheadGroup) Parse(ctx *parseContext, parent reflect.Value) (out []r
 	return []reflect.Value{}, nil // Empty match slice means a match, unlike nil
 }
 
+// @@:<production> matches a production by name, without capturing it. It is only used in lookahead
+// groups, where it is resolved once all productions are known.
+type productionRef struct {
+	name string
+	node node
+}
+
+func (p *productionRef) String() string   { return ebnf(p) }
+func (p *productionRef) GoString() string { return "productionRef{" + p.name + "}" }
+
+func (p *productionRef) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	defer ctx.printTrace(p)()
+	return p.node.Parse(ctx, parent)
+}
+
+// &<name> calls a user-defined predicate; it does not consume input
+type predicate struct {
+	name string
+	fn   func(ctx *ParseContext) bool
+}
+
+func (p *predicate) String() string   { return ebnf(p) }
+func (p *predicate) GoString() string { return "predicate{" + p.name + "}" }
+
+func (p *predicate) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	defer ctx.printTrace(p)()
+	if !p.fn(&ParseContext{PeekingLexer: &ctx.PeekingLexer, ctx: ctx}) {
+		return nil, nil
+	}
+	return []reflect.Value{}, nil
+}
+
+// =<field> - match a token equal to the value previously captured into a field of the struct
+type backreference struct {
+	field structLexerField
+}
+
+func (b *backreference) String() string   { return ebnf(b) }
+func (b *backreference) GoString() string { return "backreference{" + b.field.Name + "}" }
+
+func (b *backreference) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	defer ctx.printTrace(b)()
+	expected := ctx.capturedValue(parent, b.field)
+	token := ctx.Peek()
+	if token.EOF() || token.Value != expected {
+		return nil, &ParseError{
+			Msg: fmt.Sprintf("mismatched %s: expected %q but got %q", b.field.Name, expected, token.Value),
+			Pos: token.Pos,
+		}
+	}
+	ctx.Next()
+	return []reflect.Value{ctx.tokenValue(token.Value)}, nil
+}
+
+// <name> - match any token with a value in the set registered with LiteralSet()
+type literalSet struct {
+	name   string
+	values map[string]bool
+}
+
+func (l *literalSet) String() string   { return ebnf(l) }
+func (l *literalSet) GoString() string { return "literalSet{" + l.name + "}" }
+
+func (l *literalSet) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	defer ctx.printTrace(l)()
+	token := ctx.Peek()
+	if token.EOF() || !l.values[token.Value] {
+		if ctx.expected != nil {
+			values := make([]string, 0, len(l.values))
+			for value := range l.values {
+				values = append(values, value)
+			}
+			sort.Strings(values)
+			ctx.expected.add(token, l, values...)
+		}
+		return nil, nil
+	}
+	ctx.Next()
+	return []reflect.Value{ctx.tokenValue(token.Value)}, nil
+}
+
+// Balanced("<open>", "<close>", ...) - match a region delimited by one of the pairs of
+// literals, allowing nested regions delimited by any of the pairs
+type balanced struct {
+	open  []*literal
+	close []*literal
+	// If set, the tokens between the outermost delimiters are captured into this field.
+	field *structLexerField
+}
+
+func (b *balanced) String() string   { return ebnf(b) }
+func (b *balanced) GoString() string { return "balanced{}" }
+
+func (b *balanced) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	defer ctx.printTrace(b)()
+	stack := []int{b.opener(ctx, *ctx.Peek())}
+	if stack[0] == -1 {
+		return nil, nil
+	}
+	ctx.Next()
+	start := ctx.RawCursor()
+	out = []reflect.Value{}
+	for {
+		end := ctx.RawCursor()
+		t := ctx.Peek()
+		top := stack[len(stack)-1]
+		switch {
+		case b.close[top].matches(ctx, *t):
+			stack = stack[:len(stack)-1]
+		case t.EOF():
+			return out, &UnexpectedTokenError{Unexpected: *t, expectNode: b.close[top]}
+		case b.opener(ctx, *t) != -1:
+			stack = append(stack, b.opener(ctx, *t))
+		case b.closer(ctx, *t):
+			return out, &UnexpectedTokenError{Unexpected: *t, expectNode: b.close[top]}
+		}
+		ctx.Next()
+		if len(stack) == 0 {
+			if b.field == nil {
+				return out, nil
+			}
+			ctx.Defer(ctx.Range(start, end), parent, *b.field, out)
+			return []reflect.Value{parent}, nil
+		}
+		out = append(out, ctx.tokenValue(t.Value))
+	}
+}
+
+// Returns the index of the pair opened by t, or -1.
+func (b *balanced) opener(ctx *parseContext, t lexer.Token) int {
+	for i, open := range b.open {
+		if open.matches(ctx, t) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (b *balanced) closer(ctx *parseContext, t lexer.Token) bool {
+	for _, c := range b.close {
+		if c.matches(ctx, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// (?<= <expr> ) for positive lookbehind, (?<! <expr> ) for negative lookbehind; neither consumes input
+//
+// <expr> is matched against the single, previously consumed, non-elided token.
+type lookbehindGroup struct {
+	expr     node
+	negative bool
+}
+
+func (l *lookbehindGroup) String() string   { return ebnf(l) }
+func (l *lookbehindGroup) GoString() string { return "lookbehindGroup{}" }
+
+func (l *lookbehindGroup) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	defer ctx.printTrace(l)()
+	prev := ctx.Previous()
+	matchedLookbehind := prev != nil && matchesToken(ctx, l.expr, *prev)
+	expectingMatch := !l.negative
+	if matchedLookbehind != expectingMatch {
+		return nil, &UnexpectedTokenError{Unexpected: *ctx.Peek()}
+	}
+	return []reflect.Value{}, nil // Empty match slice means a match, unlike nil
+}
+
+// Returns true if the single-token expression n matches token t.
+func matchesToken(ctx *parseContext, n node, t lexer.Token) bool {
+	switch n := n.(type) {
+	case *literal:
+		return n.matches(ctx, t)
+	case *reference:
+		return t.Type == n.typ
+	case *disjunction:
+		for _, child := range n.nodes {
+			if matchesToken(ctx, child, t) {
+				return true
+			}
+		}
+		return false
+	case *group:
+		return matchesToken(ctx, n.expr, t)
+	default:
+		panic(fmt.Sprintf("unsupported lookbehind node %T", n))
+	}
+}
+
 // <expr> {"|" <expr>}
 type disjunction struct {
 	nodes []node
@@ -324,18 +904,39 @@ func (d *disjunction) String() string   { return ebnf(d) }
 func (d *disjunction) GoString() string { return "disjunction{}" }
 
 func (d *disjunction) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
+	out, _, err = d.parseAlternatives(ctx, parent)
+	return out, err
+}
+
+// Parse the disjunction, additionally returning the index of the matched alternative.
+func (d *disjunction) parseAlternatives(ctx *parseContext, parent reflect.Value) (out []reflect.Value, alternative int, err error) {
 	defer ctx.printTrace(d)()
+	// With InsertExpected(), tokens are only inserted once no alternative matches without insertion.
+	if ctx.insertExpected && len(d.nodes) > 1 {
+		branch := ctx.Branch()
+		branch.insertExpected = false
+		if value, i, err := d.parseAlternatives(branch, parent); err == nil && value != nil {
+			ctx.Accept(branch)
+			return value, i, nil
+		}
+	}
 	var (
 		deepestError = 0
 		firstError   error
 		firstValues  []reflect.Value
 	)
-	for _, a := range d.nodes {
+	for i, a := range d.nodes {
+		if err := ctx.countAlternative(); err != nil {
+			return nil, i, err
+		}
+		if err := ctx.checkDeadline(); err != nil {
+			return nil, i, err
+		}
 		branch := ctx.Branch()
 		if value, err := a.Parse(branch, parent); err != nil {
 			// If this branch progressed too far and still didn't match, error out.
 			if ctx.Stop(err, branch) {
-				return value, err
+				return value, i, err
 			}
 			// Show the closest error returned. The idea here is that the further the parser progresses
 			// without error, the more difficult it is to trace the error back to its root.
@@ -351,14 +952,14 @@ func (d *disjunction) Parse(ctx *parseContext, parent reflect.Value) (out []refl
 				panic(Errorf(bt.Pos, "branch %s was accepted but did not progress the lexer at %s (%q)", a, bt.Pos, bt.Value))
 			}
 			ctx.Accept(branch)
-			return value, nil
+			return value, i, nil
 		}
 	}
 	if firstError != nil {
 		ctx.MaybeUpdateError(firstError)
-		return firstValues, firstError
+		return firstValues, 0, firstError
 	}
-	return nil, nil
+	return nil, 0, nil
 }
 
 // <node> ...
@@ -385,7 +986,15 @@ func (s *sequence) Parse(ctx *parseContext, parent reflect.Value) (out []reflect
 				return nil, nil
 			}
 			token := ctx.Peek()
-			return out, &UnexpectedTokenError{Unexpected: *token, expectNode: n}
+			if !ctx.insertExpected {
+				return out, &UnexpectedTokenError{Unexpected: *token, expectNode: n}
+			}
+			if child, err = ctx.insert(n.node, parent); err != nil {
+				return out, err
+			} else if child == nil {
+				return out, &UnexpectedTokenError{Unexpected: *token, expectNode: n}
+			}
+			out = append(out, child...)
 		}
 		// Special-case for when children return an empty match.
 		// Appending an empty, non-nil slice to a nil slice returns a nil slice.
@@ -399,8 +1008,12 @@ func (s *sequence) Parse(ctx *parseContext, parent reflect.Value) (out []reflect
 
 // @<expr>
 type capture struct {
-	field structLexerField
-	node  node
+	field      structLexerField
+	node       node
+	registry   *valueRegistry                 // Set if the field type is registered with FuncRegistry(), Enum() or Flags().
+	transforms []func(string) (string, error) // Applied to each captured value (`transform:"<name>,..."`).
+	skip       bool                           // Match and validate, but discard the captured value (`skip:"true"`).
+	raw        *structLexerField              // Receives the captured text before mapping (`raw:"<field>"`).
 }
 
 func (c *capture) String() string   { return ebnf(c) }
@@ -409,9 +1022,30 @@ func (c *capture) GoString() string { return "capture{}" }
 func (c *capture) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
 	defer ctx.printTrace(c)()
 	start := ctx.RawCursor()
+	var pos lexer.Position
+	if c.registry != nil || c.transforms != nil {
+		pos = ctx.Peek().Pos
+	}
 	v, err := c.node.Parse(ctx, parent)
-	if v != nil {
-		ctx.Defer(ctx.Range(start, ctx.RawCursor()), parent, c.field, v)
+	if v != nil && err == nil && c.transforms != nil {
+		if v, err = c.transform(pos, v); err != nil {
+			return []reflect.Value{parent}, err
+		}
+	}
+	if v != nil && err == nil && c.registry != nil {
+		if v, err = c.registry.lookup(pos, v, c.field.Type.Kind() == reflect.Slice); err != nil {
+			return []reflect.Value{parent}, err
+		}
+	}
+	if v != nil && !c.skip {
+		tokens := ctx.Range(start, ctx.RawCursor())
+		if len(tokens) == 0 && ctx.matchEOF && ctx.Peek().EOF() {
+			tokens = []lexer.Token{*ctx.Peek()}
+		}
+		ctx.Defer(tokens, parent, c.field, v)
+		if c.raw != nil {
+			ctx.Defer(nil, parent, *c.raw, []reflect.Value{reflect.ValueOf(c.rawText(ctx, start))})
+		}
 	}
 	if err != nil {
 		return []reflect.Value{parent}, err
@@ -422,6 +1056,82 @@ func (c *capture) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.
 	return []reflect.Value{parent}, nil
 }
 
+// Apply the capture's transforms to each captured value.
+func (c *capture) transform(pos lexer.Position, values []reflect.Value) ([]reflect.Value, error) {
+	out := make([]reflect.Value, 0, len(values))
+	for _, v := range values {
+		s := v.String()
+		for _, transform := range c.transforms {
+			var err error
+			if s, err = transform(s); err != nil {
+				return nil, Wrapf(pos, err, "%s", c.field.Name)
+			}
+		}
+		out = append(out, reflect.ValueOf(s))
+	}
+	return out, nil
+}
+
+// The text of the non-elided tokens consumed since "start", as it was before any mapping.
+func (c *capture) rawText(ctx *parseContext, start lexer.RawCursor) string {
+	out := ""
+	for _, t := range ctx.Range(start, ctx.RawCursor()) {
+		if ctx.IsElided(t.Type) {
+			continue
+		}
+		if raw, ok := ctx.rawValues[t.Pos.Offset]; ok {
+			out += raw
+		} else {
+			out += t.Value
+		}
+	}
+	return out
+}
+
+// Values registered with FuncRegistry(), Enum() or Flags(), keyed by captured text.
+type valueRegistry struct {
+	name   string
+	values reflect.Value // map[string]T
+	flags  bool          // Combine the bits of all values, for Flags().
+}
+
+// Map captured values to their registered values, either individually or concatenated into a single key.
+func (f *valueRegistry) lookup(pos lexer.Position, values []reflect.Value, individually bool) ([]reflect.Value, error) {
+	keys := []string{}
+	for _, v := range values {
+		if individually || f.flags || len(keys) == 0 {
+			keys = append(keys, v.String())
+		} else {
+			keys[0] += v.String()
+		}
+	}
+	out := make([]reflect.Value, 0, len(keys))
+	for _, key := range keys {
+		value := f.values.MapIndex(reflect.ValueOf(key))
+		if !value.IsValid() {
+			return nil, Errorf(pos, "unknown %s %q", f.name, key)
+		}
+		out = append(out, value)
+	}
+	if f.flags {
+		return []reflect.Value{combineFlags(out)}, nil
+	}
+	return out, nil
+}
+
+// Bitwise OR of integer values of the same type.
+func combineFlags(values []reflect.Value) reflect.Value {
+	out := reflect.New(values[0].Type()).Elem()
+	for _, v := range values {
+		if out.CanUint() {
+			out.SetUint(out.Uint() | v.Uint())
+		} else {
+			out.SetInt(out.Int() | v.Int())
+		}
+	}
+	return out
+}
+
 // <identifier> - named lexer token reference
 type reference struct {
 	typ        lexer.TokenType
@@ -437,17 +1147,24 @@ func (r *reference) Parse(ctx *parseContext, parent reflect.Value) (out []reflec
 		return t.Type == r.typ
 	})
 	if token.Type != r.typ {
+		if ctx.expected != nil {
+			ctx.expected.add(ctx.Peek(), r)
+		}
 		return nil, nil
 	}
 	ctx.FastForward(cursor)
-	return []reflect.Value{reflect.ValueOf(token.Value)}, nil
+	if token.EOF() && ctx.matchEOF {
+		ctx.matchedEOF = true
+	}
+	return []reflect.Value{ctx.tokenValue(token.Value)}, nil
 }
 
 // Match a token literal exactly "..."[:<type>].
 type literal struct {
-	s  string
-	t  lexer.TokenType
-	tt string // Used for display purposes - symbolic name of t.
+	s        string
+	t        lexer.TokenType
+	tt       string // Used for display purposes - symbolic name of t.
+	adjacent bool   // May match a sequence of adjacent tokens, see AdjacentLiterals().
 }
 
 func (l *literal) String() string   { return ebnf(l) }
@@ -455,23 +1172,74 @@ func (l *literal) GoString() string { return fmt.Sprintf("literal{%q, %q}", l.s,
 
 func (l *literal) Parse(ctx *parseContext, parent reflect.Value) (out []reflect.Value, err error) {
 	defer ctx.printTrace(l)()
-	match := func(t lexer.Token) bool {
-		var equal bool
-		if ctx.caseInsensitive[t.Type] {
-			equal = l.s == "" || strings.EqualFold(t.Value, l.s)
-		} else {
-			equal = l.s == "" || t.Value == l.s
-		}
-		return (l.t == lexer.EOF || l.t == t.Type) && equal
-	}
+	match := func(t lexer.Token) bool { return l.matches(ctx, t) }
 	token, cursor := ctx.PeekAny(match)
 	if match(token) {
 		ctx.FastForward(cursor)
-		return []reflect.Value{reflect.ValueOf(token.Value)}, nil
+		return []reflect.Value{ctx.tokenValue(token.Value)}, nil
+	}
+	if l.adjacent && l.matchAdjacent(ctx) {
+		return []reflect.Value{ctx.tokenValue(l.s)}, nil
+	}
+	if ctx.expected != nil {
+		ctx.expected.add(ctx.Peek(), l, l.s)
+	}
+	if l.t != lexer.EOF && l.s != "" && l.valueMatches(ctx, token) {
+		ctx.recordTypeMismatch(token, l)
 	}
 	return nil, nil
 }
 
+// Consume a sequence of adjacent tokens whose values join to form the literal, if present.
+func (l *literal) matchAdjacent(ctx *parseContext) bool {
+	checkpoint := ctx.MakeCheckpoint()
+	token := ctx.Peek()
+	value := ""
+	for !token.EOF() && token.Value != "" && strings.HasPrefix(l.s, value+token.Value) {
+		value += token.Value
+		end := token.Pos.Offset + len(token.Value)
+		if raw, ok := ctx.rawValues[token.Pos.Offset]; ok {
+			end = token.Pos.Offset + len(raw)
+		}
+		ctx.Next()
+		if value == l.s {
+			return true
+		}
+		// Anything between the tokens, elided or not, breaks the literal.
+		token = ctx.RawPeek()
+		if ctx.IsElided(token.Type) || token.Pos.Offset != end {
+			break
+		}
+	}
+	ctx.LoadCheckpoint(checkpoint)
+	return false
+}
+
+func (l *literal) matches(ctx *parseContext, t lexer.Token) bool {
+	if l.t != lexer.EOF && l.t != t.Type {
+		return l.matchesContextually(ctx, t)
+	}
+	return l.valueMatches(ctx, t)
+}
+
+// Whether ContextualKeyword() resolves a token of another type with the same value as the literal.
+func (l *literal) matchesContextually(ctx *parseContext, t lexer.Token) bool {
+	return ctx.contextualKeyword != nil && l.s != "" && !t.EOF() && !ctx.IsElided(t.Type) &&
+		l.valueMatches(ctx, t) && ctx.contextualKeyword(t, l.s)
+}
+
+func (l *literal) valueMatches(ctx *parseContext, t lexer.Token) bool {
+	if l.s == "" {
+		return true
+	}
+	if match, ok := ctx.literalMatch[t.Type]; ok {
+		return match(l.s, t.Value)
+	} else if ctx.caseInsensitive[t.Type] {
+		return strings.EqualFold(t.Value, l.s)
+	}
+	return t.Value == l.s
+}
+
 type negation struct {
 	node node
 }
@@ -498,7 +1266,7 @@ func (n *negation) Parse(ctx *parseContext, parent reflect.Value) (out []reflect
 
 	// Just give the next token
 	next := ctx.Next()
-	return []reflect.Value{reflect.ValueOf(next.Value)}, nil
+	return []reflect.Value{ctx.tokenValue(next.Value)}, nil
 }
 
 // Attempt to transform values to given type.
@@ -551,6 +1319,14 @@ func conform(t reflect.Type, values []reflect.Value) (out []reflect.Value, err e
 			}
 			v = reflect.New(t).Elem()
 			v.SetFloat(n)
+
+		case reflect.Complex64, reflect.Complex128:
+			n, err := strconv.ParseComplex(v.String(), sizeOfKind(kind))
+			if err != nil {
+				return nil, err
+			}
+			v = reflect.New(t).Elem()
+			v.SetComplex(n)
 		}
 
 		out = append(out, v)
@@ -566,8 +1342,10 @@ func sizeOfKind(kind reflect.Kind) int {
 		return 16
 	case reflect.Int32, reflect.Uint32, reflect.Float32:
 		return 32
-	case reflect.Int64, reflect.Uint64, reflect.Float64:
+	case reflect.Int64, reflect.Uint64, reflect.Float64, reflect.Complex64:
 		return 64
+	case reflect.Complex128:
+		return 128
 	case reflect.Int, reflect.Uint:
 		return strconv.IntSize
 	}
@@ -589,6 +1367,133 @@ func maybeRef(tmpl reflect.Type, strct reflect.Value) reflect.Value {
 	return strct
 }
 
+// Join captured values into a single string.
+func joinValues(values []reflect.Value) string {
+	out := []string{}
+	for _, v := range values {
+		out = append(out, v.String())
+	}
+	return strings.Join(out, "")
+}
+
+// Decode each captured value as a single character for the `rune:"true"` tag, unquoting Go character literals.
+func decodeRunes(values []reflect.Value) ([]reflect.Value, error) {
+	out := make([]reflect.Value, 0, len(values))
+	for _, v := range values {
+		s := v.String()
+		if len(s) > 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+			if unquoted, err := strconv.Unquote(s); err == nil {
+				s = unquoted
+			}
+		}
+		r, size := utf8.DecodeRuneInString(s)
+		if s == "" || size != len(s) || (r == utf8.RuneError && size == 1) {
+			return nil, fmt.Errorf("expected a single character but got %q", v.String())
+		}
+		out = append(out, reflect.ValueOf(r))
+	}
+	return out, nil
+}
+
+// Ensure that the "key" field of each element appended to a slice is unique, for the `unique:"<key>"` tag.
+//
+// The error is positioned at the duplicate element's Pos field if it has one, otherwise at its first token.
+func checkUnique(tokens []lexer.Token, slice reflect.Value, key string, elements []reflect.Value) error {
+	seen := map[any]bool{}
+	for i := 0; i < slice.Len(); i++ {
+		if k, ok := uniqueKey(reflect.Indirect(slice.Index(i)).FieldByName(key)); ok {
+			seen[k] = true
+		}
+	}
+	for _, elem := range elements {
+		ev := reflect.Indirect(elem)
+		k, ok := uniqueKey(ev.FieldByName(key))
+		if !ok {
+			continue
+		}
+		if !seen[k] {
+			seen[k] = true
+			continue
+		}
+		return Errorf(elementPos(tokens, ev), "duplicate %s %#v", key, k)
+	}
+	return nil
+}
+
+// The value of a unique key, dereferencing pointers so that keys are compared by value. Nil keys
+// are never duplicates.
+func uniqueKey(v reflect.Value) (any, bool) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, false
+		}
+		v = v.Elem()
+	}
+	return v.Interface(), true
+}
+
+// The position of a captured element, from its Pos field if it has one, otherwise its first token.
+func elementPos(tokens []lexer.Token, v reflect.Value) lexer.Position {
+	if pos, ok := positionField(v, "Pos"); ok {
+		return pos
+	} else if len(tokens) > 0 {
+		return tokens[0].Pos
+	}
+	return lexer.Position{}
+}
+
+// Add captured structs to a map, keyed by their MapKey() method or field tagged `mapkey:"true"`.
+func setMapField(tokens []lexer.Token, f reflect.Value, field structLexerField, fieldValue []reflect.Value) error {
+	fieldValue, err := conform(f.Type().Elem(), fieldValue)
+	if err != nil {
+		return err
+	}
+	if f.IsNil() {
+		f.Set(reflect.MakeMap(f.Type()))
+	}
+	for _, v := range fieldValue {
+		ev := reflect.Indirect(v)
+		var key reflect.Value
+		if method := mapKeyMethod(ev); method.IsValid() {
+			key = method.Call(nil)[0]
+		} else {
+			key = ev.FieldByIndex(mapKeyField(ev.Type()))
+		}
+		key = key.Convert(f.Type().Key())
+		if !field.DuplicateKeys && f.MapIndex(key).IsValid() {
+			return Errorf(elementPos(tokens, ev), "duplicate key %#v", key.Interface())
+		}
+		f.SetMapIndex(key, v)
+	}
+	return nil
+}
+
+func mapKeyMethod(v reflect.Value) reflect.Value {
+	if method := v.MethodByName("MapKey"); method.IsValid() {
+		return method
+	}
+	if v.CanAddr() {
+		return v.Addr().MethodByName("MapKey")
+	}
+	ptr := reflect.New(v.Type())
+	ptr.Elem().Set(v)
+	return ptr.MethodByName("MapKey")
+}
+
+// Unmarshal the i'th captured value, positioning any error at the token it was captured from
+// unless the values do not correspond to tokens.
+func unmarshalText(d encoding.TextUnmarshaler, tokens []lexer.Token, values []reflect.Value, i int) error {
+	err := d.UnmarshalText([]byte(values[i].Interface().(string)))
+	if _, ok := err.(Error); err == nil || ok || len(tokens) == 0 {
+		return err
+	}
+	pos := tokens[0].Pos
+	if len(tokens) == len(values) {
+		pos = tokens[i].Pos
+	}
+	return &wrappingParseError{err: err, ParseError: ParseError{Msg: err.Error(), Pos: pos}}
+}
+
 // Set field.
 //
 // If field is a pointer the pointer will be set to the value. If field is a string, value will be
@@ -596,11 +1501,22 @@ func maybeRef(tmpl reflect.Type, strct reflect.Value) reflect.Value {
 //
 // For all other types, an attempt will be made to convert the string to the corresponding
 // type (int, float32, etc.).
-func setField(tokens []lexer.Token, strct reflect.Value, field structLexerField, fieldValue []reflect.Value) (err error) { // nolint: gocognit
+func setField(ctx *parseContext, tokens []lexer.Token, strct reflect.Value, field structLexerField, fieldValue []reflect.Value) (err error) { // nolint: gocognit
 	defer decorate(&err, func() string { return strct.Type().Name() + "." + field.Name })
 
 	f := strct.FieldByIndex(field.Index)
 
+	// Unexported fields are only present in the grammar with AllowUnexported().
+	if !f.CanSet() && f.CanAddr() {
+		f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+	}
+
+	if field.Exclusive {
+		if err := ctx.recordCaptureSite(strct, field); err != nil {
+			return err
+		}
+	}
+
 	// Any kind of pointer, hydrate it first.
 	if f.Kind() == reflect.Ptr {
 		if f.IsNil() {
@@ -623,15 +1539,18 @@ func setField(tokens []lexer.Token, strct reflect.Value, field structLexerField,
 	}
 
 	if f.CanAddr() {
-		if d, ok := f.Addr().Interface().(Capture); ok {
+		if d, ok := f.Addr().Interface().(TokenCapture); ok {
+			return d.CaptureTokens(ctx.capturedTokens(tokens))
+		} else if d, ok := f.Addr().Interface().(Capture); ok {
 			ifv := make([]string, 0, len(fieldValue))
 			for _, v := range fieldValue {
 				ifv = append(ifv, v.Interface().(string))
 			}
 			return d.Capture(ifv)
 		} else if d, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
-			for _, v := range fieldValue {
-				if err := d.UnmarshalText([]byte(v.Interface().(string))); err != nil {
+			captured := ctx.capturedTokens(tokens)
+			for i := range fieldValue {
+				if err := unmarshalText(d, captured, fieldValue, i); err != nil {
 					return err
 				}
 			}
@@ -639,15 +1558,44 @@ func setField(tokens []lexer.Token, strct reflect.Value, field structLexerField,
 		}
 	}
 
+	if field.Tag.Get("rune") == "true" {
+		if f.Kind() != reflect.Slice && len(fieldValue) > 1 {
+			fieldValue = []reflect.Value{reflect.ValueOf(joinValues(fieldValue))}
+		}
+		if fieldValue, err = decodeRunes(fieldValue); err != nil {
+			return err
+		}
+	}
+
+	if f.Kind() == reflect.Map {
+		return setMapField(tokens, f, field, fieldValue)
+	}
+
 	if f.Kind() == reflect.Slice {
 		sliceElemType := f.Type().Elem()
-		if sliceElemType.Implements(captureType) || reflect.PtrTo(sliceElemType).Implements(captureType) {
+		// Values captured with @@ are already of the element type, so are not unmarshalled.
+		unmarshal := implements(sliceElemType, textUnmarshalerType) && len(fieldValue) > 0 &&
+			fieldValue[0].Kind() == reflect.String
+		if implements(sliceElemType, captureType) || implements(sliceElemType, tokenCaptureType) || unmarshal {
 			if sliceElemType.Kind() == reflect.Ptr {
 				sliceElemType = sliceElemType.Elem()
 			}
-			for _, v := range fieldValue {
-				d := reflect.New(sliceElemType).Interface().(Capture)
-				if err := d.Capture([]string{v.Interface().(string)}); err != nil {
+			captured := ctx.capturedTokens(tokens)
+			for i, v := range fieldValue {
+				d := reflect.New(sliceElemType).Interface()
+				if td, ok := d.(TokenCapture); ok {
+					// Each element receives its own token, unless values do not correspond to tokens.
+					elementTokens := captured
+					if len(captured) == len(fieldValue) {
+						elementTokens = captured[i : i+1]
+					}
+					err = td.CaptureTokens(elementTokens)
+				} else if cd, ok := d.(Capture); ok {
+					err = cd.Capture([]string{v.Interface().(string)})
+				} else {
+					err = unmarshalText(d.(encoding.TextUnmarshaler), captured, fieldValue, i)
+				}
+				if err != nil {
 					return err
 				}
 				eltValue := reflect.ValueOf(d)
@@ -661,6 +1609,11 @@ func setField(tokens []lexer.Token, strct reflect.Value, field structLexerField,
 			if err != nil {
 				return err
 			}
+			if key := field.Tag.Get("unique"); key != "" {
+				if err := checkUnique(tokens, f, key, fieldValue); err != nil {
+					return err
+				}
+			}
 			f.Set(reflect.Append(f, fieldValue...))
 		}
 		return nil
@@ -686,11 +1639,7 @@ func setField(tokens []lexer.Token, strct reflect.Value, field structLexerField,
 	// Coalesce multiple tokens into one. This allows eg. ["-", "10"] to be captured as separate tokens but
 	// parsed as a single string "-10".
 	if len(fieldValue) > 1 {
-		out := []string{}
-		for _, v := range fieldValue {
-			out = append(out, v.String())
-		}
-		fieldValue = []reflect.Value{reflect.ValueOf(strings.Join(out, ""))}
+		fieldValue = []reflect.Value{reflect.ValueOf(joinValues(fieldValue))}
 	}
 
 	fieldValue, err = conform(f.Type(), fieldValue)
@@ -704,26 +1653,15 @@ func setField(tokens []lexer.Token, strct reflect.Value, field structLexerField,
 	fv := fieldValue[0]
 
 	switch f.Kind() { // nolint: exhaustive
-	// Numeric types will increment if the token can not be coerced.
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		if fv.Type() != f.Type() {
-			f.SetInt(f.Int() + 1)
-		} else {
-			f.Set(fv)
-		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		if fv.Type() != f.Type() {
-			f.SetUint(f.Uint() + 1)
-		} else {
-			f.Set(fv)
-		}
-
-	case reflect.Float32, reflect.Float64:
-		if fv.Type() != f.Type() {
-			f.SetFloat(f.Float() + 1)
-		} else {
-			f.Set(fv)
+	// Numeric values have been parsed by conform(), which fails if the token is not numeric, so a
+	// field is never set to anything other than the captured number.
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		if field.Flags {
+			fv = combineFlags([]reflect.Value{f, fv})
 		}
+		f.Set(fv)
 
 	case reflect.Bool, reflect.Struct, reflect.Interface:
 		if f.Kind() == reflect.Bool && fv.Kind() == reflect.Bool {
@@ -735,6 +1673,13 @@ func setField(tokens []lexer.Token, strct reflect.Value, field structLexerField,
 		}
 		f.Set(fv)
 
+	case reflect.Func:
+		// Only populated by FuncRegistry().
+		if fv.Type() != f.Type() {
+			return fmt.Errorf("no FuncRegistry for %s", f.Type())
+		}
+		f.Set(fv)
+
 	default:
 		return fmt.Errorf("unsupported field type %s for field %s", f.Type(), field.Name)
 	}
@@ -0,0 +1,27 @@
+package participle
+
+import (
+	"strconv"
+)
+
+// Number is a numeric literal that preserves the exact text it was captured from, similar to
+// json.Number.
+//
+// This allows eg. formatters to round-trip "1.0" or "0x10" unchanged, while still converting to a
+// numeric value on demand. Multiple captured tokens are concatenated, so "-" "10" becomes "-10".
+type Number string
+
+// String returns the literal text of the number.
+func (n Number) String() string { return string(n) }
+
+// Float64 returns the number as a float64.
+func (n Number) Float64() (float64, error) {
+	return strconv.ParseFloat(string(n), 64)
+}
+
+// Int64 returns the number as an int64.
+//
+// Base prefixes such as "0x" and underscore digit separators are supported.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(string(n), 0, 64)
+}
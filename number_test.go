@@ -0,0 +1,32 @@
+package participle_test
+
+import (
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
+)
+
+func TestNumber(t *testing.T) {
+	type grammar struct {
+		Values []participle.Number `@(Int | Float)*`
+		Single participle.Number   `"=" @("-"? (Int | Float))`
+	}
+	parser := mustTestParser[grammar](t)
+	actual, err := parser.ParseString("", `1.0 0x10 1e3 = - 1.50`)
+	require.NoError(t, err)
+	require.Equal(t, &grammar{Values: []participle.Number{"1.0", "0x10", "1e3"}, Single: "-1.50"}, actual)
+
+	f, err := actual.Values[0].Float64()
+	require.NoError(t, err)
+	require.Equal(t, 1.0, f)
+	i, err := actual.Values[1].Int64()
+	require.NoError(t, err)
+	require.Equal(t, int64(16), i)
+	f, err = actual.Single.Float64()
+	require.NoError(t, err)
+	require.Equal(t, -1.5, f)
+	_, err = actual.Values[0].Int64()
+	require.Error(t, err)
+}
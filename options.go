@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"sort"
+	"time"
 
 	"github.com/alecthomas/participle/v2/lexer"
 )
@@ -45,6 +47,153 @@ func UseLookahead(n int) Option {
 	}
 }
 
+// MaxAlternatives limits the number of alternatives the parser will attempt at any one position in the input.
+//
+// Ambiguous grammars, particularly with a large lookahead, can backtrack exponentially. With this option,
+// the parse fails with an error once the limit is exceeded, rather than appearing to hang. Attempts are
+// counted across all backtracking, so the limit should be set well above the number of alternatives
+// the grammar legitimately tries at a single position.
+func MaxAlternatives(n int) Option {
+	return func(p *parserOptions) error {
+		if n <= 0 {
+			return fmt.Errorf("MaxAlternatives: limit must be positive, got %d", n)
+		}
+		p.maxAlternatives = n
+		return nil
+	}
+}
+
+// MaxDepth limits how deeply productions may be nested while parsing.
+//
+// Deeply nested input, such as "((((...))))", recurses through the parser until the Go stack
+// overflows, crashing the process. With this option the parse instead fails with an error
+// positioned at the token where the limit was exceeded. This is intended for parsing untrusted
+// input. By default nesting is unlimited.
+func MaxDepth(n int) Option {
+	return func(p *parserOptions) error {
+		if n <= 0 {
+			return fmt.Errorf("MaxDepth: limit must be positive, got %d", n)
+		}
+		p.maxDepth = n
+		return nil
+	}
+}
+
+// SortedEBNF sorts the productions output by Parser.String() by name, after the root production.
+//
+// This keeps the EBNF stable when the grammar is refactored without being changed, eg. when struct
+// fields are reordered, which is useful for grammar snapshot tests.
+func SortedEBNF() Option {
+	return func(p *parserOptions) error {
+		p.sortEBNF = true
+		return nil
+	}
+}
+
+// AnnotatedEBNF prefixes each capture in the EBNF output by Parser.String() with the name of the
+// field it captures into, eg. Name:<ident>.
+//
+// This makes it easier to correlate the EBNF, and railroad diagrams generated from it, with the
+// grammar's structs.
+func AnnotatedEBNF() Option {
+	return func(p *parserOptions) error {
+		p.annotateEBNF = true
+		return nil
+	}
+}
+
+// LeftFactor rewrites alternatives that share a common literal prefix so that the prefix is only matched once.
+//
+// For example, the alternatives "<" "a" ">" | "<" "b" ">" are rewritten to "<" ("a" ">" | "b" ">").
+// This reduces the lookahead required to disambiguate the alternatives, and the cost of backtracking.
+//
+// Only adjacent alternatives starting with an uncaptured literal are factored, so the order in which
+// alternatives are attempted is preserved. However, note that because the rewritten grammar can
+// require less lookahead, some inputs that would fail to parse without this option may succeed.
+func LeftFactor() Option {
+	return func(p *parserOptions) error {
+		p.leftFactor = true
+		return nil
+	}
+}
+
+// AllowLeftRecursion permits productions that refer to themselves before consuming any input, eg.
+//
+//	type Expr struct {
+//		Left  *Expr  `  @@`
+//		Op    string `  @("+" | "-")`
+//		Right *Term  `  @@`
+//		Term  *Term  `| @@`
+//	}
+//
+// which would otherwise be rejected by Build(). A left-recursive production is parsed repeatedly at
+// the same position, with its reference to itself matching the result of the previous attempt, for
+// as long as each attempt consumes more input than the last. The result is left-associative, eg.
+// "1 - 2 - 3" is parsed as ((1 - 2) - 3).
+//
+// Only direct left recursion, where a production refers to itself, is supported.
+func AllowLeftRecursion() Option {
+	return func(p *parserOptions) error {
+		p.allowLeftRecursion = true
+		return nil
+	}
+}
+
+// SuggestAlternatives adds a "did you mean" suggestion to unexpected token errors, naming the
+// expected literal closest to the unexpected token by edit distance, if any is close enough.
+//
+// The suggestion is also available programmatically as UnexpectedTokenError.Suggestion.
+func SuggestAlternatives() Option {
+	return func(p *parserOptions) error {
+		p.suggestAlternatives = true
+		return nil
+	}
+}
+
+// CollectExpected reports every literal and token type that could have matched at the position of an
+// unexpected token error, eg. (expected one of "add", "remove", "}"), rather than only the expectation
+// of the last alternative attempted.
+//
+// The terminals attempted at the furthest token reached are tracked across backtracking.
+func CollectExpected() Option {
+	return func(p *parserOptions) error {
+		p.collectExpected = true
+		return nil
+	}
+}
+
+// ErrorFormatter renders the message of each UnexpectedTokenError returned by a parse with the given
+// function, in place of the default "<line>:<column>: unexpected token ..." form, eg. to produce
+// multi-line diagnostics quoting the source, or JSON.
+//
+// The formatter is passed the position of the unexpected token, the token itself, and the expected
+// grammar, eg. `"union" <ident>`, which is empty if unknown. Message() is unaffected, and any
+// suggestion remains available as UnexpectedTokenError.Suggestion.
+func ErrorFormatter(formatter ErrorFormatterFunc) Option {
+	return func(p *parserOptions) error {
+		p.errorFormatter = formatter
+		return nil
+	}
+}
+
+// ContextualKeyword resolves literals with an explicit token type, such as "count":Keyword, against
+// tokens of another type with the same value. When such a literal would otherwise fail to match
+// because of the token's type, eg. an Ident "count", "resolve" is called with the token and the
+// literal, and the token matches if it returns true.
+//
+// This allows non-reserved keywords to be lexed as identifiers, and still be matched as keywords
+// where the grammar expects them:
+//
+//	participle.ContextualKeyword(func(token lexer.Token, keyword string) bool {
+//		return token.Type == identType && nonReserved[strings.ToUpper(keyword)]
+//	})
+func ContextualKeyword(resolve func(token lexer.Token, keyword string) bool) Option {
+	return func(p *parserOptions) error {
+		p.contextualKeyword = resolve
+		return nil
+	}
+}
+
 // CaseInsensitive allows the specified token types to be matched case-insensitively.
 //
 // Note that the lexer itself will also have to be case-insensitive; this option
@@ -58,6 +207,152 @@ func CaseInsensitive(tokens ...string) Option {
 	}
 }
 
+// CaseInsensitiveFold matches literals against tokens of the given type by comparing their values
+// after normalising both with "fold".
+//
+// Unlike CaseInsensitive, which uses the simple Unicode case folding of strings.EqualFold, this
+// allows language-specific rules to be applied, such as Turkish dotted and dotless "i":
+//
+//	participle.CaseInsensitiveFold("Keyword", func(s string) string {
+//		return strings.ToLowerSpecial(unicode.TurkishCase, s)
+//	})
+//
+// This is a LiteralMatch, so it takes precedence over CaseInsensitive for the same token type.
+func CaseInsensitiveFold(tokenType string, fold func(string) string) Option {
+	return LiteralMatch(tokenType, func(literal, value string) bool {
+		return fold(literal) == fold(value)
+	})
+}
+
+// StrictStringCapture disallows capturing more than one token into a string field.
+//
+// By default all tokens captured into a string field are concatenated, so that eg. @"."+ captures
+// "...". This can hide grammar mistakes where a field unexpectedly matches multiple tokens, so with
+// this option a capture of multiple tokens into a string field is an error. Slices of strings, and
+// fields implementing Capture or encoding.TextUnmarshaler, are unaffected.
+func StrictStringCapture() Option {
+	return func(p *parserOptions) error {
+		p.strictStringCapture = true
+		return nil
+	}
+}
+
+// AllowDuplicateMapKeys allows map fields to capture values with the same key, with later values
+// replacing earlier values.
+//
+// By default a duplicate key is an error.
+func AllowDuplicateMapKeys() Option {
+	return func(p *parserOptions) error {
+		p.allowDuplicateMapKeys = true
+		return nil
+	}
+}
+
+// CaptureSource populates a string field tagged with `source:"true"` in each struct in the AST with
+// the text of the input the struct matched, from the start of its first non-elided token to the end of its
+// last, including any elided tokens, such as whitespace or comments, between them. Text is taken
+// from the input before any mapping, eg. by Unquote(). A struct that matched no tokens receives an
+// empty string.
+//
+// This requires the input to be retained for the duration of the parse, so input read by Parse()
+// is buffered. Source is not populated by ParseTokens(), ParseFromLexer() or ParseStream(), nor if
+// PositionMapper() changes offsets.
+func CaptureSource() Option {
+	return func(p *parserOptions) error {
+		p.captureSource = true
+		return nil
+	}
+}
+
+// MatchEOF treats EOF as a regular token that is matched at most once, so that it can be referenced
+// anywhere in the grammar, eg. to terminate a line with ( "\n" | @EOF ).
+//
+// EOF does not advance the lexer, so by default a repeated sub-expression that can match it, such as
+// a line in @@*, matches it repeatedly until MaxIterations is exceeded. With this option a repetition
+// stops after an element that matched EOF. Captures that match at EOF, such as @EOF, receive the EOF
+// token, which has an empty value, so they can populate string, bool, pointer, lexer.Token and
+// TokenCapture fields.
+func MatchEOF() Option {
+	return func(p *parserOptions) error {
+		p.matchEOF = true
+		return nil
+	}
+}
+
+// InternTokens deduplicates the values of tokens within a parse, so that tokens with the same value
+// share a single string.
+//
+// Input with many repeated identifiers or keywords, such as generated code or logs, otherwise
+// allocates each captured value separately. With this option a value is allocated once per parse,
+// reducing allocations and the memory retained by the AST. With ParseStream() values are shared
+// within a record rather than across the whole input.
+func InternTokens() Option {
+	return func(p *parserOptions) error {
+		p.internTokens = true
+		return nil
+	}
+}
+
+// SplitLiterals allows grammar literals containing whitespace to match a sequence of tokens.
+//
+// For example, with this option the literal "order by" is equivalent to "order" "by". Literals
+// with an explicit token type, such as "/* comment */":Comment, are never split.
+func SplitLiterals() Option {
+	return func(p *parserOptions) error {
+		p.splitLiterals = true
+		return nil
+	}
+}
+
+// AdjacentLiterals allows each of the given grammar literals to match a sequence of tokens whose
+// values join to form the literal, provided the tokens are immediately adjacent in the input.
+//
+// For example, with a lexer producing "!" and "=" as separate tokens, AdjacentLiterals("!=") makes
+// the literal "!=" match the input "!=" but not "! =", as tokens separated by whitespace or any
+// other elided token do not match. The captured value is the literal itself. Literals with an
+// explicit token type, such as "!=":Operator, only ever match a single token.
+func AdjacentLiterals(literals ...string) Option {
+	return func(p *parserOptions) error {
+		if p.adjacentLiterals == nil {
+			p.adjacentLiterals = map[string]bool{}
+		}
+		for _, literal := range literals {
+			p.adjacentLiterals[literal] = true
+		}
+		return nil
+	}
+}
+
+// LiteralMatch overrides how grammar literals are compared against tokens of the given type.
+//
+// "match" is called with the literal from the grammar and the value of the token, and should return
+// true if they are equal. For example, to match the literal "foo" against a quoted String token:
+//
+//	participle.LiteralMatch("String", func(literal, value string) bool {
+//		return strings.Trim(value, `"`) == literal
+//	})
+//
+// This takes precedence over CaseInsensitive for the same token type.
+func LiteralMatch(tokenType string, match func(literal, value string) bool) Option {
+	return func(p *parserOptions) error {
+		p.literalMatch[tokenType] = match
+		return nil
+	}
+}
+
+// AllowUnexported allows grammar annotations on unexported struct fields.
+//
+// By default unexported fields are ignored. With this option they are parsed into by
+// circumventing Go's visibility rules with the unsafe package. This is intended for packages that
+// want to keep the fields of their AST private, and should be used with care: values will be
+// written into unexported fields of any type in the grammar, including those from other packages.
+func AllowUnexported() Option {
+	return func(p *parserOptions) error {
+		p.allowUnexported = true
+		return nil
+	}
+}
+
 // ParseTypeWith associates a custom parsing function with some interface type T.
 // When the parser encounters a value of type T, it will use the given parse function to
 // parse a value from the input.
@@ -70,18 +365,114 @@ func CaseInsensitive(tokens ...string) Option {
 // This can be useful if you want to parse a DSL within the larger grammar, or if you want
 // to implement an optimized parsing scheme for some portion of the grammar.
 func ParseTypeWith[T any](parseFn func(*lexer.PeekingLexer) (T, error)) Option {
+	return parseTypeWith("ParseTypeWith", parseFn, false)
+}
+
+// ParseTypeWithContext is like ParseTypeWith, except that the parse function receives
+// a ParseContext rather than the bare lexer.
+//
+// This allows custom parse functions to participate in the parser's own machinery,
+// such as error reporting and tracing.
+func ParseTypeWithContext[T any](parseFn func(*ParseContext) (T, error)) Option {
+	return parseTypeWith("ParseTypeWithContext", parseFn, true)
+}
+
+func parseTypeWith(name string, parseFn any, withContext bool) Option {
 	return func(p *parserOptions) error {
 		parseFnVal := reflect.ValueOf(parseFn)
 		parseFnType := parseFnVal.Type()
 		if parseFnType.Out(0).Kind() != reflect.Interface {
-			return fmt.Errorf("ParseTypeWith: T must be an interface type (got %s)", parseFnType.Out(0))
+			return fmt.Errorf("%s: T must be an interface type (got %s)", name, parseFnType.Out(0))
 		}
 		prodType := parseFnType.Out(0)
-		p.customDefs = append(p.customDefs, customDef{prodType, parseFnVal})
+		p.customDefs = append(p.customDefs, customDef{prodType, parseFnVal, withContext})
 		return nil
 	}
 }
 
+// FuncRegistry populates fields of the function type F by looking up the captured text in "funcs".
+//
+// This allows eg. operators to be bound directly to the Go functions that evaluate them while parsing.
+// Text missing from the registry is a parse error, which describes the text using "name". A single
+// field captures the concatenation of all its tokens, while each token captured into a slice is
+// looked up separately.
+func FuncRegistry[F any](name string, funcs map[string]F) Option {
+	return func(p *parserOptions) error {
+		funcType := reflect.TypeOf(funcs).Elem()
+		if funcType.Kind() != reflect.Func {
+			return fmt.Errorf("FuncRegistry: F must be a function type (got %s)", funcType)
+		}
+		return p.registerValues("FuncRegistry", name, funcType, reflect.ValueOf(funcs))
+	}
+}
+
+// Enum populates fields of type E by looking up the captured keyword in "values".
+//
+// This maps keywords directly to enum values, without implementing Capture:
+//
+//	type Colour int
+//
+//	const (
+//		Red Colour = iota
+//		Green
+//	)
+//
+//	type Pixel struct {
+//		Colour Colour `@("red" | "green")`
+//	}
+//
+//	parser := participle.MustBuild[Pixel](participle.Enum("colour", map[string]Colour{"red": Red, "green": Green}))
+//
+// Lookup behaves as for FuncRegistry, including the parse error for unknown text.
+func Enum[E any](name string, values map[string]E) Option {
+	return func(p *parserOptions) error {
+		enumType := reflect.TypeOf(values).Elem()
+		if enumType.Kind() == reflect.Func || enumType.Kind() == reflect.Interface {
+			return fmt.Errorf("Enum: E must be a concrete, non-function type (got %s)", enumType)
+		}
+		return p.registerValues("Enum", name, enumType, reflect.ValueOf(values))
+	}
+}
+
+// Flags populates integer fields of type F by combining the bits of each captured keyword in "flags".
+//
+// This allows a set of independent, unordered flags to be captured into a single field:
+//
+//	type Perm uint
+//
+//	type File struct {
+//		Perm Perm `@("r" | "w" | "x")*`
+//	}
+//
+//	parser := participle.MustBuild[File](participle.Flags("perm", map[string]Perm{"r": 4, "w": 2, "x": 1}))
+//
+// Keywords may be captured in any order, and F should be a named type as every field of type F is
+// populated this way. Lookup otherwise behaves as for FuncRegistry.
+func Flags[F any](name string, flags map[string]F) Option {
+	return func(p *parserOptions) error {
+		flagsType := reflect.TypeOf(flags).Elem()
+		switch flagsType.Kind() { // nolint: exhaustive
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return fmt.Errorf("Flags: F must be an integer type (got %s)", flagsType)
+		}
+		if err := p.registerValues("Flags", name, flagsType, reflect.ValueOf(flags)); err != nil {
+			return err
+		}
+		p.valueRegistries[flagsType].flags = true
+		return nil
+	}
+}
+
+func (p *parserOptions) registerValues(option, name string, t reflect.Type, values reflect.Value) error {
+	if _, ok := p.valueRegistries[t]; ok {
+		return fmt.Errorf("%s: duplicate registry for %s", option, t)
+	}
+	p.valueRegistries[t] = &valueRegistry{name: name, values: values}
+	return nil
+}
+
 // Union associates several member productions with some interface type T.
 // Given members X, Y, Z, and W for a union type U, then the EBNF rule is:
 //
@@ -107,7 +498,146 @@ func Union[T any](members ...T) Option {
 		for _, m := range members {
 			memberTypes = append(memberTypes, reflect.TypeOf(m))
 		}
-		p.unionDefs = append(p.unionDefs, unionDef{unionType, memberTypes})
+		p.unionDefs = append(p.unionDefs, unionDef{typ: unionType, members: memberTypes})
+		return nil
+	}
+}
+
+// UnionByToken associates member productions with some interface type T, like Union, but
+// selects the single member to parse by its first token rather than attempting each in turn.
+//
+// Keys naming a lexer symbol, such as "Ident", select the member for tokens of that type, while
+// other keys select the member for tokens with that value, which take precedence. This is much
+// faster than Union for large unions where each member starts with a distinct keyword:
+//
+//	participle.UnionByToken(map[string]Statement{"select": &Select{}, "insert": &Insert{}})
+//
+// If no key matches the first token, parsing fails with an error listing the keys.
+func UnionByToken[T any](members map[string]T) Option {
+	return func(p *parserOptions) error {
+		var t T
+		unionType := reflect.TypeOf(&t).Elem()
+		if unionType.Kind() != reflect.Interface {
+			return fmt.Errorf("UnionByToken: union type must be an interface (got %s)", unionType)
+		}
+		keys := make([]string, 0, len(members))
+		for key := range members {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		def := unionDef{typ: unionType, keys: map[string]int{}}
+		index := map[reflect.Type]int{}
+		for _, key := range keys {
+			memberType := reflect.TypeOf(members[key])
+			i, ok := index[memberType]
+			if !ok {
+				i = len(def.members)
+				index[memberType] = i
+				def.members = append(def.members, memberType)
+			}
+			def.keys[key] = i
+		}
+		p.unionDefs = append(p.unionDefs, def)
+		return nil
+	}
+}
+
+// Predicate registers a named semantic predicate, referenced in the grammar as "&name".
+//
+// The predicate is called with the parse context when it is reached, without consuming any input,
+// and the match fails if it returns false. Combined with State, this allows grammars to make
+// context-sensitive decisions, such as whether an identifier is a previously declared type name:
+//
+//	type Declaration struct {
+//		Type string `@Ident &isType`
+//		Name string `@Ident &declare`
+//	}
+//
+// Predicates are also called on branches the parser later abandons, so any side effects, such as
+// recording a declaration, should tolerate that.
+func Predicate(name string, predicate func(ctx *ParseContext) bool) Option {
+	return func(p *parserOptions) error {
+		if _, ok := p.predicates[name]; ok {
+			return fmt.Errorf("duplicate predicate %q", name)
+		}
+		p.predicates[name] = predicate
+		return nil
+	}
+}
+
+// Transform registers a named transformation of captured values, applied to fields tagged with
+// `transform:"<name>"`. This avoids defining a type implementing Capture for each field whose value
+// needs adjusting, eg.
+//
+//	parser := participle.MustBuild[Statement](participle.Transform("upper", strings.ToUpper))
+//
+//	type Statement struct {
+//		Keyword string `parser:"@Ident" transform:"upper"`
+//	}
+//
+// "transform" is either a func(string) string or a func(string) (string, error), and is applied to
+// the value of each captured token before it is assigned to the field, and before any lookup in a
+// FuncRegistry(), Enum() or Flags(). Multiple transforms can be applied in order, eg.
+// `transform:"trim,upper"`. An error fails the parse, positioned at the start of the capture.
+func Transform[F func(string) string | func(string) (string, error)](name string, transform F) Option {
+	return func(p *parserOptions) error {
+		if _, ok := p.transforms[name]; ok {
+			return fmt.Errorf("duplicate transform %q", name)
+		}
+		switch transform := any(transform).(type) {
+		case func(string) string:
+			p.transforms[name] = func(s string) (string, error) { return transform(s), nil }
+		case func(string) (string, error):
+			p.transforms[name] = transform
+		}
+		return nil
+	}
+}
+
+// LiteralSet registers a named set of values that can be referenced in the grammar like a token type.
+//
+// A reference to "name" matches any token whose value is in "values", regardless of its type. This
+// allows eg. a configuration-driven keyword list to vary between parsers without changing the lexer.
+//
+//	parser := participle.MustBuild[Statement](participle.LiteralSet("Keyword", keywords))
+//
+//	type Statement struct {
+//		Keyword string `@Keyword`
+//	}
+func LiteralSet(name string, values []string) Option {
+	return func(p *parserOptions) error {
+		if _, ok := p.literalSets[name]; ok {
+			return fmt.Errorf("duplicate literal set %q", name)
+		}
+		set := make(map[string]bool, len(values))
+		for _, value := range values {
+			set[value] = true
+		}
+		p.literalSets[name] = set
+		return nil
+	}
+}
+
+// Interleave registers a production T that may appear between any two tokens of the input, such as
+// "#pragma" directives or structured comments that should be collected rather than parsed in place.
+//
+// Before parsing, T is attempted at every token. Each match is removed from the token stream and
+// appended to the sink passed to the parse with InterleaveInto(), if any, then the grammar is parsed
+// from the remaining tokens. T must be a struct or a pointer to a struct. A match that fails after
+// consuming input is reported as a parse error.
+//
+// Interleaving applies to Parse(), ParseString() and ParseBytes(), but not to ParseFromLexer().
+//
+//	parser := participle.MustBuild[File](participle.Interleave[*Pragma]())
+//	var pragmas []*Pragma
+//	ast, err := parser.ParseString("", source, participle.InterleaveInto(&pragmas))
+func Interleave[T any]() Option {
+	return func(p *parserOptions) error {
+		typ := reflect.TypeOf((*T)(nil)).Elem()
+		if indirectType(typ).Kind() != reflect.Struct {
+			return fmt.Errorf("Interleave(): production must be a struct or pointer to a struct, not %s", typ)
+		}
+		p.interleave = append(p.interleave, &interleaveDef{typ: typ})
 		return nil
 	}
 }
@@ -122,6 +652,16 @@ func Trace(w io.Writer) ParseOption {
 	}
 }
 
+// InterleaveInto appends the matches of the production T registered with Interleave() to "sink".
+func InterleaveInto[T any](sink *[]T) ParseOption {
+	return func(p *parseContext) {
+		if p.interleaveSinks == nil {
+			p.interleaveSinks = map[reflect.Type]reflect.Value{}
+		}
+		p.interleaveSinks[reflect.TypeOf(sink).Elem().Elem()] = reflect.ValueOf(sink).Elem()
+	}
+}
+
 // AllowTrailing tokens without erroring.
 //
 // That is, do not error if a full parse completes but additional tokens remain.
@@ -130,3 +670,53 @@ func AllowTrailing(ok bool) ParseOption {
 		p.allowTrailing = ok
 	}
 }
+
+// ContinueOnError keeps parsing after an element of the root production's repetition fails to parse.
+//
+// The repetition must be the last element of the root production, eg. `@@*`, or the root
+// grammar must be a slice. Tokens are skipped until an element parses successfully again, and an
+// Errors value holding one error for each failed region is returned alongside the AST of the
+// elements that did parse. This is a lightweight alternative to error recovery, intended for tools
+// such as linters that want to report as many errors as possible.
+func ContinueOnError() ParseOption {
+	return func(p *parseContext) {
+		p.continueOnError = true
+	}
+}
+
+// InsertExpected recovers from a missing token by pretending that it was present, eg. a missing
+// closing brace or semicolon.
+//
+// When a sequence fails because a single literal or token type is expected, an error is recorded
+// and parsing continues as if the token had been matched. A captured literal is captured as if it
+// had been matched, while a captured token type captures nothing. Once parsing completes, an Errors
+// value holding one error for each inserted token is returned alongside the partial AST. This is
+// intended for editors, which want an AST for as much of the input as possible.
+//
+// Note that as a sequence with an inserted token matches, alternatives following it are not
+// attempted.
+func InsertExpected() ParseOption {
+	return func(p *parseContext) {
+		p.insertExpected = true
+	}
+}
+
+// State associates a user-defined value, such as a symbol table, with a single parse.
+//
+// It is available to predicates and custom parse functions through ParseContext.State().
+func State(state any) ParseOption {
+	return func(p *parseContext) {
+		p.state = state
+	}
+}
+
+// Deadline aborts the parse with a "parse timed out" error if it takes longer than "d".
+//
+// The deadline is checked periodically as the parser attempts alternatives and repetitions, so it
+// guards against pathological inputs, such as those causing excessive backtracking, without any
+// context plumbing. The error is positioned at the token being parsed when the deadline expired.
+func Deadline(d time.Duration) ParseOption {
+	return func(p *parseContext) {
+		p.deadline = &parseDeadline{at: time.Now().Add(d)}
+	}
+}
@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
+	"unicode"
+	"unsafe"
 
 	"github.com/alecthomas/participle/v2/lexer"
 )
@@ -13,11 +18,13 @@ import (
 type unionDef struct {
 	typ     reflect.Type
 	members []reflect.Type
+	keys    map[string]int // Index of the member selected by each first token, for UnionByToken().
 }
 
 type customDef struct {
-	typ     reflect.Type
-	parseFn reflect.Value
+	typ         reflect.Type
+	parseFn     reflect.Value
+	withContext bool
 }
 
 type parserOptions struct {
@@ -25,12 +32,45 @@ type parserOptions struct {
 	rootType              reflect.Type
 	typeNodes             map[reflect.Type]node
 	useLookahead          int
+	maxAlternatives       int
+	maxDepth              int
 	caseInsensitive       map[string]bool
 	caseInsensitiveTokens map[lexer.TokenType]bool
+	literalMatch          map[string]func(literal, value string) bool
+	predicates            map[string]func(ctx *ParseContext) bool
+	transforms            map[string]func(string) (string, error)
+	literalSets           map[string]map[string]bool
+	adjacentLiterals      map[string]bool
+	literalMatchTokens    map[lexer.TokenType]func(literal, value string) bool
 	mappers               []mapperByToken
 	unionDefs             []unionDef
 	customDefs            []customDef
+	interleave            []*interleaveDef
+	valueRegistries       map[reflect.Type]*valueRegistry
 	elide                 []string
+	elideFuncs            []func(lexer.Token) bool
+	trivia                []lexer.TokenType
+	coalesce              []string
+	positionMapper        func(lexer.Position) lexer.Position
+	errorFormatter        ErrorFormatterFunc
+	contextualKeyword     func(lexer.Token, string) bool
+	skipPrefix            *regexp.Regexp
+	defaultElide          bool
+	splitLiterals         bool
+	leftFactor            bool
+	allowLeftRecursion    bool
+	suggestAlternatives   bool
+	collectExpected       bool
+	sortEBNF              bool
+	annotateEBNF          bool
+	allowUnexported       bool
+	captureElided         bool
+	strictStringCapture   bool
+	allowDuplicateMapKeys bool
+	internTokens          bool
+	captureSource         bool
+	matchEOF              bool
+	backreferences        bool // Set if the grammar contains a backreference.
 }
 
 // A Parser for a particular grammar and lexer.
@@ -39,6 +79,8 @@ type Parser[G any] struct {
 }
 
 // ParserForProduction returns a new parser for the given production in grammar G.
+//
+// See ParseFromLexer for parsing a production from the middle of an existing token stream.
 func ParserForProduction[P, G any](parser *Parser[G]) (*Parser[P], error) {
 	t := reflect.TypeOf(*new(P))
 	_, ok := parser.typeNodes[t]
@@ -69,6 +111,11 @@ func Build[G any](options ...Option) (parser *Parser[G], err error) {
 		parserOptions: parserOptions{
 			lex:             lexer.TextScannerLexer,
 			caseInsensitive: map[string]bool{},
+			literalMatch:    map[string]func(literal, value string) bool{},
+			predicates:      map[string]func(ctx *ParseContext) bool{},
+			transforms:      map[string]func(string) (string, error){},
+			literalSets:     map[string]map[string]bool{},
+			valueRegistries: map[reflect.Type]*valueRegistry{},
 			useLookahead:    1,
 		},
 	}
@@ -79,6 +126,25 @@ func Build[G any](options ...Option) (parser *Parser[G], err error) {
 	}
 
 	symbols := p.lex.Symbols()
+	var mapping *mappingLexerDef
+	for name := range p.literalSets {
+		if _, ok := symbols[name]; ok {
+			return nil, fmt.Errorf("LiteralSet() name %q conflicts with a lexer symbol", name)
+		}
+	}
+	// Resolved before the lexer is wrapped by mappers.
+	if trivia, ok := p.lex.(lexer.TriviaDefinition); ok {
+		for _, symbol := range trivia.Trivia() {
+			rn, ok := symbols[symbol]
+			if !ok {
+				return nil, fmt.Errorf("lexer trivia uses unknown token %q", symbol)
+			}
+			p.trivia = append(p.trivia, rn)
+		}
+	}
+	if p.skipPrefix != nil {
+		p.lex = &prefixSkippingLexerDef{p.lex, p.skipPrefix}
+	}
 	if len(p.mappers) > 0 {
 		mappers := map[lexer.TokenType][]Mapper{}
 		for _, mapper := range p.mappers {
@@ -94,7 +160,7 @@ func Build[G any](options ...Option) (parser *Parser[G], err error) {
 				}
 			}
 		}
-		p.lex = &mappingLexerDef{p.lex, func(t lexer.Token) (lexer.Token, error) {
+		mapping = &mappingLexerDef{l: p.lex, mapper: func(t lexer.Token) (lexer.Token, error) {
 			combined := make([]Mapper, 0, len(mappers[t.Type])+len(mappers[lexer.EOF]))
 			combined = append(combined, mappers[lexer.EOF]...)
 			combined = append(combined, mappers[t.Type]...)
@@ -108,9 +174,37 @@ func Build[G any](options ...Option) (parser *Parser[G], err error) {
 			}
 			return t, nil
 		}}
+		p.lex = mapping
+	}
+
+	if len(p.coalesce) > 0 {
+		coalesce := map[lexer.TokenType]bool{}
+		for _, symbol := range p.coalesce {
+			rn, ok := symbols[symbol]
+			if !ok {
+				return nil, fmt.Errorf("Coalesce() uses unknown token %q", symbol)
+			}
+			coalesce[rn] = true
+		}
+		p.lex = &coalescingLexerDef{p.lex, coalesce}
+	}
+	if len(p.elideFuncs) > 0 {
+		p.lex = &filteringLexerDef{p.lex, p.elideFuncs}
+	}
+	if p.positionMapper != nil {
+		p.lex = &positionMappingLexerDef{p.lex, p.positionMapper}
 	}
 
 	context := newGeneratorContext(p.lex)
+	context.splitLiterals = p.splitLiterals
+	context.adjacent = p.adjacentLiterals
+	context.allowUnexported = p.allowUnexported
+	context.strictStrings = p.strictStringCapture
+	context.duplicateKeys = p.allowDuplicateMapKeys
+	context.predicates = p.predicates
+	context.transforms = p.transforms
+	context.literalSets = p.literalSets
+	context.valueRegistries = p.valueRegistries
 	if err := context.addCustomDefs(p.customDefs); err != nil {
 		return nil, err
 	}
@@ -128,12 +222,43 @@ func Build[G any](options ...Option) (parser *Parser[G], err error) {
 	if err != nil {
 		return nil, err
 	}
+	for _, def := range p.interleave {
+		if def.node, err = context.parseType(def.typ); err != nil {
+			return nil, err
+		}
+	}
+	if err := context.resolveProductionRefs(); err != nil {
+		return nil, err
+	}
+	if p.allowLeftRecursion {
+		markLeftRecursion(rootNode)
+	}
 	if err := validate(rootNode); err != nil {
 		return nil, err
 	}
+	for _, def := range p.interleave {
+		if err := validate(def.node); err != nil {
+			return nil, err
+		}
+	}
+	// Pre-mapping token values are only retained if the grammar uses them.
+	if mapping != nil && (context.rawValues || p.captureSource) {
+		mapping.keepRaw = true
+	}
+	if p.leftFactor {
+		leftFactor(rootNode)
+	}
+	p.backreferences = context.backreferences
 	p.typeNodes = context.typeNodes
 	p.typeNodes[p.rootType] = rootNode
+	// A slice grammar is parsed as zero or more of its elements.
+	if p.rootType.Elem().Kind() == reflect.Slice {
+		p.typeNodes[p.rootType.Elem()] = &group{expr: rootNode, mode: groupMatchZeroOrMore}
+	}
 	p.setCaseInsensitiveTokens()
+	if err := p.setLiteralMatchTokens(); err != nil {
+		return nil, err
+	}
 	return p, nil
 }
 
@@ -156,24 +281,78 @@ func (p *Parser[G]) Lex(filename string, r io.Reader) ([]lexer.Token, error) {
 // ParseFromLexer into grammar v which must be of the same type as the grammar passed to
 // Build().
 //
+// The lexer may be positioned anywhere in its token stream, and is left positioned after the
+// parsed input (or where parsing stopped, on error). Combined with ParserForProduction and
+// AllowTrailing(true), this allows a single production to be parsed from the middle of a token
+// stream, interleaved with hand-written parsing. Use lexer.PeekingLexer.MakeCheckpoint() to
+// backtrack if the production fails to parse.
+//
 // This may return a Error.
 func (p *Parser[G]) ParseFromLexer(lex *lexer.PeekingLexer, options ...ParseOption) (*G, error) {
-	v := new(G)
+	ctx := newParseContext(lex, p.useLookahead, p.caseInsensitiveTokens, p.literalMatchTokens)
+	defer func() { *lex = ctx.PeekingLexer }()
+	for _, option := range options {
+		option(&ctx)
+	}
+	v, ok := ctx.target.(*G)
+	if !ok {
+		v = new(G)
+	}
 	rv := reflect.ValueOf(v)
 	parseNode, err := p.parseNodeFor(rv)
 	if err != nil {
 		return nil, err
 	}
-	ctx := newParseContext(lex, p.useLookahead, p.caseInsensitiveTokens)
-	defer func() { *lex = ctx.PeekingLexer }()
-	for _, option := range options {
-		option(&ctx)
+	if ok {
+		resetTarget(rv.Elem())
+		ctx.reuse = rv.Elem()
+	}
+	p.configure(&ctx)
+	if ctx.continueOnError {
+		root := p.typeNodes[rv.Type().Elem()]
+		ctx.rootRepetition = rootRepetition(root)
+		if _, ok := root.(*strct); ok {
+			ctx.productions = -1 // The root struct itself is at depth zero.
+		}
 	}
 	// If the grammar implements Parseable, use it.
 	if parseable, ok := any(v).(Parseable); ok {
 		return v, p.rootParseable(&ctx, parseable)
 	}
-	return v, p.parseOne(&ctx, parseNode, rv)
+	err = p.parseOne(&ctx, parseNode, rv)
+	if err == nil && ctx.captureElided {
+		appendTrailingTrivia(&ctx, p.typeNodes[rv.Type().Elem()], rv.Elem())
+	}
+	return v, err
+}
+
+// Configure a parse context with the parser's options.
+func (p *Parser[G]) configure(ctx *parseContext) {
+	if p.maxAlternatives > 0 {
+		ctx.alternatives = &alternativeLimit{max: p.maxAlternatives, attempts: map[int]int{}}
+	}
+	if p.maxDepth > 0 {
+		ctx.depthLimit = &depthLimit{max: p.maxDepth}
+	}
+	if p.suggestAlternatives || p.collectExpected {
+		ctx.expected = &expectedLiterals{}
+	}
+	ctx.captureElided = p.captureElided
+	ctx.contextualKeyword = p.contextualKeyword
+	ctx.matchEOF = p.matchEOF
+}
+
+// Elided tokens at the end of the input are appended to the root struct's Trivia field.
+func appendTrailingTrivia(ctx *parseContext, root node, v reflect.Value) {
+	s, ok := root.(*strct)
+	if !ok || s.triviaFieldIndex == nil || !ctx.Peek().EOF() {
+		return
+	}
+	_, eof := ctx.PeekAny(func(lexer.Token) bool { return false })
+	if trailing := ctx.Range(ctx.RawCursor(), eof); len(trailing) > 0 {
+		f := v.FieldByIndex(s.triviaFieldIndex)
+		f.Set(reflect.AppendSlice(f, reflect.ValueOf(trailing)))
+	}
 }
 
 func (p *Parser[G]) setCaseInsensitiveTokens() {
@@ -185,14 +364,143 @@ func (p *Parser[G]) setCaseInsensitiveTokens() {
 	}
 }
 
+func (p *Parser[G]) setLiteralMatchTokens() error {
+	symbols := p.lex.Symbols()
+	p.literalMatchTokens = map[lexer.TokenType]func(literal, value string) bool{}
+	for sym, match := range p.literalMatch {
+		tt, ok := symbols[sym]
+		if !ok {
+			return fmt.Errorf("LiteralMatch() uses unknown token %q", sym)
+		}
+		p.literalMatchTokens[tt] = match
+	}
+	return nil
+}
+
 func (p *Parser[G]) parse(lex lexer.Lexer, options ...ParseOption) (v *G, err error) {
-	peeker, err := lexer.Upgrade(lex, p.getElidedTypes()...)
+	peeker, options, err := p.upgrade(lex, options)
 	if err != nil {
 		return nil, err
 	}
 	return p.ParseFromLexer(peeker, options...)
 }
 
+// Upgrade a lexer from the parser's lexer definition, adding any parse options it requires.
+func (p *Parser[G]) upgrade(lex lexer.Lexer, options []ParseOption) (*lexer.PeekingLexer, []ParseOption, error) {
+	if raw, ok := lex.(rawValueLexer); ok {
+		options = appendOptions(options, func(p *parseContext) { p.rawValues = raw.rawValues() })
+	}
+	lex, options, _ = p.intern(lex, options)
+	elide := p.getElidedTypes()
+	peeker, err := lexer.Upgrade(lex, elide...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(p.interleave) > 0 {
+		if peeker, err = p.removeInterleaved(peeker, elide, resolveParseOptions(options).interleaveSinks); err != nil {
+			return nil, nil, err
+		}
+	}
+	return peeker, options, nil
+}
+
+// Resolve the settings of parse options that are needed before parsing starts, eg. the sinks of
+// InterleaveInto().
+func resolveParseOptions(options []ParseOption) *parseContext {
+	ctx := &parseContext{}
+	for _, option := range options {
+		option(ctx)
+	}
+	return ctx
+}
+
+// ParseStream parses r as a sequence of G, passing each to fn as it is parsed rather than
+// accumulating an AST of the entire input. This suits large inputs of repetitive records, such as
+// logs. For a grammar whose root is a repetition of records, eg. a slice of Entry, use
+// ParserForProduction to obtain a parser for the record type.
+//
+// Tokens are read from r as they are needed, and discarded once each record has been parsed, so
+// memory use is bounded by the size of the largest record rather than that of the input. This is
+// not the case with Interleave(), which requires all tokens up front.
+//
+// Parsing stops at the first error, unless ContinueOnError() is passed, in which case tokens after
+// a record that fails to parse are skipped until a record parses successfully again, and an Errors
+// value holding one error for each failed region is returned once the input is exhausted. An error
+// returned by fn always stops parsing, and is given the position of the record if it has none.
+func (p *Parser[G]) ParseStream(filename string, r io.Reader, fn func(*G) error, options ...ParseOption) error {
+	if filename == "" {
+		filename = lexer.NameOfReader(r)
+	}
+	lex, err := p.lex.Lex(filename, r)
+	if err != nil {
+		return err
+	}
+	var (
+		peeker *lexer.PeekingLexer
+		pool   *internPool
+	)
+	if len(p.interleave) > 0 {
+		if peeker, options, err = p.upgrade(lex, options); err != nil {
+			return err
+		}
+	} else {
+		if raw, ok := lex.(rawValueLexer); ok {
+			options = appendOptions(options, func(p *parseContext) { p.rawValues = raw.rawValues() })
+		}
+		lex, options, pool = p.intern(lex, options)
+		peeker = lexer.UpgradeStream(lex, p.getElidedTypes()...)
+	}
+	continueOnError := resolveParseOptions(options).continueOnError
+	options = appendOptions(options, func(ctx *parseContext) {
+		// Parsing continues after errors between records, not within them.
+		ctx.continueOnError = false
+		ctx.allowTrailing = true
+	})
+	var errs Errors
+	for !peeker.Peek().EOF() {
+		peeker.Discard()
+		if pool != nil {
+			// Interned values are only shared within a record, keeping memory use bounded.
+			pool.reset()
+		}
+		start := peeker.MakeCheckpoint()
+		pos := peeker.Peek().Pos
+		v, err := p.ParseFromLexer(peeker, options...)
+		if err == nil && peeker.Cursor() == start.Cursor() {
+			err = &UnexpectedTokenError{Unexpected: *peeker.Peek()}
+		}
+		if err == nil {
+			if err := fn(v); err != nil {
+				return withPosition(pos, err)
+			}
+			continue
+		}
+		// A record ending prematurely due to a lexing error is not a parse error.
+		if lexErr := peeker.Err(); lexErr != nil {
+			return lexErr
+		}
+		if !continueOnError {
+			return err
+		}
+		errs = append(errs, withPosition(peeker.Peek().Pos, err))
+		peeker.LoadCheckpoint(start)
+		// Skip to the next token at which a record parses successfully.
+		for peeker.Next(); !peeker.Peek().EOF(); peeker.Next() {
+			branch := *peeker
+			if _, err := p.ParseFromLexer(&branch, options...); err == nil && branch.Cursor() > peeker.Cursor() {
+				break
+			}
+		}
+	}
+	if err := peeker.Err(); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
 // Parse from r into grammar v which must be of the same type as the grammar passed to
 // Build(). Parameter filename is used as an opaque prefix in error messages.
 //
@@ -201,6 +509,14 @@ func (p *Parser[G]) Parse(filename string, r io.Reader, options ...ParseOption)
 	if filename == "" {
 		filename = lexer.NameOfReader(r)
 	}
+	if p.captureSource {
+		// The input is retained for CaptureSource().
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return p.ParseBytes(filename, b, options...)
+	}
 	lex, err := p.lex.Lex(filename, r)
 	if err != nil {
 		return nil, err
@@ -208,11 +524,26 @@ func (p *Parser[G]) Parse(filename string, r io.Reader, options ...ParseOption)
 	return p.parse(lex, options...)
 }
 
+// ParseFile reads and parses the file at path into grammar v which must be of the same type as the
+// grammar passed to Build(). The base name of path is used as the filename in positions and error
+// messages.
+//
+// Errors opening or reading the file are returned as *fs.PathError, while parse errors may be an
+// Error.
+func (p *Parser[G]) ParseFile(path string, options ...ParseOption) (v *G, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return p.ParseBytes(filepath.Base(path), b, options...)
+}
+
 // ParseString from s into grammar v which must be of the same type as the grammar passed to
 // Build(). Parameter filename is used as an opaque prefix in error messages.
 //
 // This may return an Error.
 func (p *Parser[G]) ParseString(filename string, s string, options ...ParseOption) (v *G, err error) {
+	options = p.withSource(s, options)
 	var lex lexer.Lexer
 	if sl, ok := p.lex.(lexer.StringDefinition); ok {
 		lex, err = sl.LexString(filename, s)
@@ -225,11 +556,143 @@ func (p *Parser[G]) ParseString(filename string, s string, options ...ParseOptio
 	return p.parse(lex, options...)
 }
 
+// Validate parses s like ParseString, but discards the result and returns only the error, if any.
+// This suits linters and other checks that only need to know whether input conforms to the grammar.
+//
+// Validate is faster than ParseString as captured values are not assigned to fields, with the
+// consequence that errors only detected when assigning a value, eg. by a Capture implementation or
+// when converting a value to an integer field, are not reported. For the same reason, AfterParse
+// hooks are not called.
+func (p *Parser[G]) Validate(filename string, s string, options ...ParseOption) error {
+	options = appendOptions(options, func(ctx *parseContext) {
+		ctx.validate = true
+		ctx.validateStrings = p.backreferences
+	})
+	_, err := p.ParseString(filename, s, options...)
+	return err
+}
+
+// ParseStringWithTokens is like ParseString, but also returns the non-elided tokens the parser
+// saw, excluding EOF, for rendering token-level diagnostics.
+//
+// The tokens are returned even if parsing fails. If lexing fails, they are the tokens preceding the
+// lexing error.
+func (p *Parser[G]) ParseStringWithTokens(filename string, s string, options ...ParseOption) (*G, []lexer.Token, error) {
+	var (
+		lex lexer.Lexer
+		err error
+	)
+	options = p.withSource(s, options)
+	if sl, ok := p.lex.(lexer.StringDefinition); ok {
+		lex, err = sl.LexString(filename, s)
+	} else {
+		lex, err = p.lex.Lex(filename, strings.NewReader(s))
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if raw, ok := lex.(rawValueLexer); ok {
+		options = appendOptions(options, func(p *parseContext) { p.rawValues = raw.rawValues() })
+	}
+	recorder := &recordingLexer{Lexer: lex}
+	peeker, options, err := p.upgrade(recorder, options)
+	if err != nil {
+		elide := map[lexer.TokenType]bool{}
+		for _, tt := range p.getElidedTypes() {
+			elide[tt] = true
+		}
+		tokens := []lexer.Token{}
+		for _, t := range recorder.tokens {
+			if !elide[t.Type] {
+				tokens = append(tokens, t)
+			}
+		}
+		return nil, tokens, err
+	}
+	tokens := []lexer.Token{}
+	for all := *peeker; !all.Peek().EOF(); {
+		tokens = append(tokens, *all.Next())
+	}
+	v, err := p.ParseFromLexer(peeker, options...)
+	return v, tokens, err
+}
+
+// Records the tokens read from a lexer.
+type recordingLexer struct {
+	lexer.Lexer
+	tokens []lexer.Token
+}
+
+func (r *recordingLexer) Next() (lexer.Token, error) {
+	t, err := r.Lexer.Next()
+	if err == nil {
+		r.tokens = append(r.tokens, t)
+	}
+	return t, err
+}
+
+// ParseInto is like Parse, but populates "out" instead of allocating a new G, so that it can be
+// reused across parses.
+//
+// Before parsing "out" is reset to its zero value, except that slice fields of the root struct
+// are truncated to zero length and their capacity reused. The backing arrays of such slices from a
+// previous parse will therefore be overwritten, so they must not be retained.
+func (p *Parser[G]) ParseInto(filename string, r io.Reader, out *G, options ...ParseOption) error {
+	_, err := p.Parse(filename, r, appendOptions(options, parseInto(out))...)
+	return err
+}
+
+// ParseStringInto is like ParseString, but populates "out" instead of allocating a new G.
+//
+// See ParseInto for how "out" is reset.
+func (p *Parser[G]) ParseStringInto(filename string, s string, out *G, options ...ParseOption) error {
+	_, err := p.ParseString(filename, s, appendOptions(options, parseInto(out))...)
+	return err
+}
+
+// ParseBytesInto is like ParseBytes, but populates "out" instead of allocating a new G.
+//
+// See ParseInto for how "out" is reset.
+func (p *Parser[G]) ParseBytesInto(filename string, b []byte, out *G, options ...ParseOption) error {
+	_, err := p.ParseBytes(filename, b, appendOptions(options, parseInto(out))...)
+	return err
+}
+
+func parseInto(out any) ParseOption {
+	return func(p *parseContext) { p.target = out }
+}
+
+// Reset v to its zero value for reuse, retaining the capacity of top-level slices.
+func resetTarget(v reflect.Value) {
+	if v.Kind() != reflect.Struct {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if !f.CanSet() {
+			f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+		}
+		if f.Kind() == reflect.Slice && !f.IsNil() {
+			f.Set(f.Slice(0, 0))
+		} else {
+			f.Set(reflect.Zero(f.Type()))
+		}
+	}
+}
+
 // ParseBytes from b into grammar v which must be of the same type as the grammar passed to
 // Build(). Parameter filename is used as an opaque prefix in error messages.
 //
+// If the lexer implements lexer.BytesDefinition, b is lexed directly. A stateful lexer built with
+// lexer.ZeroCopy() does so without copying b, in which case values captured into the AST share
+// memory with b, so b must not be modified while the AST is in use.
+//
 // This may return an Error.
 func (p *Parser[G]) ParseBytes(filename string, b []byte, options ...ParseOption) (v *G, err error) {
+	if p.captureSource {
+		options = p.withSource(string(b), options)
+	}
 	var lex lexer.Lexer
 	if sl, ok := p.lex.(lexer.BytesDefinition); ok {
 		lex, err = sl.LexBytes(filename, b)
@@ -242,16 +705,92 @@ func (p *Parser[G]) ParseBytes(filename string, b []byte, options ...ParseOption
 	return p.parse(lex, options...)
 }
 
+// Retain the input for CaptureSource().
+func (p *Parser[G]) withSource(s string, options []ParseOption) []ParseOption {
+	if !p.captureSource {
+		return options
+	}
+	return appendOptions(options, func(ctx *parseContext) { ctx.source = &s })
+}
+
+// Append extra to a copy of options, so that the caller's backing array is never overwritten.
+func appendOptions(options []ParseOption, extra ...ParseOption) []ParseOption {
+	out := make([]ParseOption, 0, len(options)+len(extra))
+	out = append(out, options...)
+	return append(out, extra...)
+}
+
+// ParseTokens parses previously lexed tokens into grammar v which must be of the same type as the
+// grammar passed to Build(), eg. tokens cached between edits in an editor. Parameter filename is used
+// as an opaque prefix in error messages.
+//
+// The lexer is not used, so the tokens must have the types of the parser's lexer definition, and
+// Map() is not applied to them. Tokens are elided as if they had been lexed.
+//
+// This may return an Error.
+func (p *Parser[G]) ParseTokens(filename string, tokens []lexer.Token, options ...ParseOption) (v *G, err error) {
+	return p.parse(lexer.FromTokens(filename, tokens), options...)
+}
+
 func (p *Parser[G]) parseOne(ctx *parseContext, parseNode node, rv reflect.Value) error {
 	err := p.parseInto(ctx, parseNode, rv)
-	if err != nil {
+	if err := ctx.limitError(); err != nil {
 		return err
 	}
-	token := ctx.Peek()
-	if !token.EOF() && !ctx.allowTrailing {
-		return ctx.DeepestError(&UnexpectedTokenError{Unexpected: *token})
+	if err == nil {
+		token := ctx.Peek()
+		if !token.EOF() && !ctx.allowTrailing {
+			err = ctx.DeepestError(&UnexpectedTokenError{Unexpected: *token})
+			// An error recorded at the trailing token, eg. by UnionByToken(), is more informative.
+			if ctx.deepestError != nil && ctx.deepestErrorDepth == ctx.Cursor() {
+				err = ctx.deepestError
+			}
+		}
+	}
+	if len(ctx.errors) > 0 {
+		if err != nil {
+			ctx.errors = append(ctx.errors, withPosition(ctx.Peek().Pos, err))
+		}
+		err = Errors(ctx.errors)
+	}
+	if err != nil && p.collectExpected {
+		collectExpected(err, ctx.expected)
+	}
+	if err != nil && p.suggestAlternatives {
+		suggestAlternatives(err, ctx.expected)
+	}
+	if err != nil && ctx.typeMismatch != nil {
+		explainTypeMismatch(err, ctx.typeMismatch, lexer.SymbolsByRune(p.lex))
+	}
+	if err != nil && p.errorFormatter != nil {
+		formatUnexpectedTokens(err, p.errorFormatter)
+	}
+	return err
+}
+
+// Find the repetition ending the root production, which ContinueOnError() continues after errors in.
+func rootRepetition(n node) *group {
+	if s, ok := n.(*strct); ok {
+		n = s.expr
+	}
+	for {
+		switch child := n.(type) {
+		case *sequence:
+			for child.next != nil {
+				child = child.next
+			}
+			n = child.node
+		case *capture:
+			n = child.node
+		case *group:
+			if child.mode == groupMatchZeroOrMore || child.mode == groupMatchOneOrMore {
+				return child
+			}
+			return nil
+		default:
+			return nil
+		}
 	}
-	return nil
 }
 
 func (p *Parser[G]) parseInto(ctx *parseContext, parseNode node, rv reflect.Value) error {
@@ -259,7 +798,12 @@ func (p *Parser[G]) parseInto(ctx *parseContext, parseNode node, rv reflect.Valu
 		return fmt.Errorf("target must be a non-nil pointer to a struct or interface, but is a nil %s", rv.Type())
 	}
 	pv, err := p.typeNodes[rv.Type().Elem()].Parse(ctx, rv.Elem())
-	if len(pv) > 0 && pv[0].Type() == rv.Elem().Type() {
+	if rv.Elem().Kind() == reflect.Slice {
+		elemType := rv.Type().Elem().Elem()
+		for _, v := range pv {
+			rv.Elem().Set(reflect.Append(rv.Elem(), maybeRef(elemType, v)))
+		}
+	} else if len(pv) > 0 && pv[0].Type() == rv.Elem().Type() {
 		rv.Elem().Set(reflect.Indirect(pv[0]))
 	}
 	if err != nil {
@@ -290,6 +834,18 @@ func (p *Parser[G]) rootParseable(ctx *parseContext, parseable Parseable) error
 
 func (p *Parser[G]) getElidedTypes() []lexer.TokenType {
 	symbols := p.lex.Symbols()
+	if p.elide == nil && p.defaultElide {
+		elideTypes := []lexer.TokenType{}
+		for symbol, rn := range symbols {
+			if rn != lexer.EOF && symbol != "" && unicode.IsLower([]rune(symbol)[0]) {
+				elideTypes = append(elideTypes, rn)
+			}
+		}
+		return elideTypes
+	}
+	if p.elide == nil {
+		return p.trivia
+	}
 	elideTypes := make([]lexer.TokenType, 0, len(p.elide))
 	for _, elide := range p.elide {
 		rn, ok := symbols[elide]
@@ -306,8 +862,8 @@ func (p *Parser[G]) parseNodeFor(v reflect.Value) (node, error) {
 	if t.Kind() == reflect.Interface {
 		t = t.Elem()
 	}
-	if t.Kind() != reflect.Ptr || (t.Elem().Kind() != reflect.Struct && t.Elem().Kind() != reflect.Interface) {
-		return nil, fmt.Errorf("expected a pointer to a struct or interface, but got %s", t)
+	if t.Kind() != reflect.Ptr || (t.Elem().Kind() != reflect.Struct && t.Elem().Kind() != reflect.Interface && t.Elem().Kind() != reflect.Slice) {
+		return nil, fmt.Errorf("expected a pointer to a struct, interface or slice, but got %s", t)
 	}
 	parseNode := p.typeNodes[t]
 	if parseNode == nil {
@@ -1,15 +1,21 @@
 package participle_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/fs"
 	"math"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
 	"text/scanner"
+	"time"
+	"unicode"
 
 	"github.com/alecthomas/assert/v2"
 
@@ -659,6 +665,52 @@ func TestTextUnmarshalerInterface(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestTextUnmarshalerSlice(t *testing.T) {
+	type grammar struct {
+		Dates    []time.Time  `"on" @Date ("," @Date)*`
+		Optional []*time.Time `("until" @Date)?`
+	}
+	parser := mustTestParser[grammar](t, participle.Lexer(lexer.MustSimple([]lexer.SimpleRule{
+		{"Date", `\d{4}-\d\d-\d\dT[\d:]+Z`},
+		{"Keyword", `\w+`},
+		{"Punct", `,`},
+		{"whitespace", `\s+`},
+	})))
+	date := func(s string) time.Time {
+		d, err := time.Parse(time.RFC3339, s)
+		assert.NoError(t, err)
+		return d
+	}
+	until := date("2022-01-01T00:00:00Z")
+	actual, err := parser.ParseString("", `on 2021-01-01T10:00:00Z, 2021-06-30T12:30:00Z until 2022-01-01T00:00:00Z`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{
+		Dates:    []time.Time{date("2021-01-01T10:00:00Z"), date("2021-06-30T12:30:00Z")},
+		Optional: []*time.Time{&until},
+	}, actual)
+
+	_, err = parser.ParseString("", `on 2021-01-01T10:00:00Z, 2021-13-30T12:30:00Z`)
+	assert.EqualError(t, err, `1:26: grammar.Dates: parsing time "2021-13-30T12:30:00Z": month out of range`)
+}
+
+type unmarshallableStruct struct {
+	Name string `@Ident`
+}
+
+func (u *unmarshallableStruct) UnmarshalText(text []byte) error {
+	u.Name = string(text)
+	return nil
+}
+
+func TestTextUnmarshalerStructSlice(t *testing.T) {
+	type grammar struct {
+		Items []*unmarshallableStruct `@@*`
+	}
+	actual, err := mustTestParser[grammar](t).ParseString("", "a b")
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Items: []*unmarshallableStruct{{"a"}, {"b"}}}, actual)
+}
+
 func TestLiteralTypeConstraint(t *testing.T) {
 	type grammar struct {
 		Literal string `@"123456":String`
@@ -672,7 +724,13 @@ func TestLiteralTypeConstraint(t *testing.T) {
 	assert.Equal(t, expected, actual)
 
 	_, err = parser.ParseString("", `123456`)
-	assert.Error(t, err)
+	assert.EqualError(t, err, `1:1: unexpected token "123456": the value matches "123456":String but the token type is Int, not String`)
+
+	type sequence struct {
+		Keyword string `"set" @"123456":String`
+	}
+	_, err = mustTestParser[sequence](t, participle.Unquote()).ParseString("", `set 123456`)
+	assert.EqualError(t, err, `1:5: unexpected token "123456" (expected "123456"): the value matches "123456":String but the token type is Int, not String`)
 }
 
 type nestedCapture struct {
@@ -741,6 +799,24 @@ func TestStringConcat(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestStrictStringCapture(t *testing.T) {
+	type grammar struct {
+		Field  string   `@"."+`
+		Name   *string  `@Ident?`
+		Values []string `@Int*`
+	}
+
+	parser := mustTestParser[grammar](t, participle.StrictStringCapture())
+
+	actual, err := parser.ParseString("", `. foo 1 2`)
+	assert.NoError(t, err)
+	name := "foo"
+	assert.Equal(t, &grammar{Field: ".", Name: &name, Values: []string{"1", "2"}}, actual)
+
+	_, err = parser.ParseString("", `. . . .`)
+	assert.EqualError(t, err, `1:1: grammar.Field: 4 tokens captured into a string field, capture into a slice to allow multiple tokens`)
+}
+
 func TestParseIntSlice(t *testing.T) {
 	type grammar struct {
 		Field []int `@Int+`
@@ -918,6 +994,29 @@ func TestCaseInsensitive(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestCaseInsensitiveFold(t *testing.T) {
+	type grammar struct {
+		Name string `"İF" @Ident`
+	}
+	lex := lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\pL+`},
+		{"whitespace", `\s+`},
+	})
+	turkish := func(s string) string { return strings.ToLowerSpecial(unicode.TurkishCase, s) }
+	p := mustTestParser[grammar](t, participle.Lexer(lex), participle.CaseInsensitiveFold("Ident", turkish))
+	actual, err := p.ParseString("", `if foo`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{"foo"}, actual)
+
+	actual, err = p.ParseString("", `İf foo`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{"foo"}, actual)
+
+	// Dotless "ı" is a different letter in Turkish.
+	_, err = p.ParseString("", `IF foo`)
+	assert.EqualError(t, err, `1:1: unexpected token "IF"`)
+}
+
 func TestTokenAfterRepeatErrors(t *testing.T) {
 	type grammar struct {
 		Text string `@Ident* "foo"`
@@ -1098,6 +1197,24 @@ func TestIssue71(t *testing.T) {
 	assert.Error(t, err)
 }
 
+// nolint: structcheck, unused
+func TestAllowUnexported(t *testing.T) {
+	type Sub struct {
+		name string `@Ident`
+	}
+	type grammar struct {
+		pattern *Sub     `@@`
+		values  []string `@Ident*`
+		count   int      `@Int?`
+	}
+
+	p, err := participle.Build[grammar](participle.AllowUnexported())
+	assert.NoError(t, err)
+	actual, err := p.ParseString("", `foo bar baz 3`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{pattern: &Sub{name: "foo"}, values: []string{"bar", "baz"}, count: 3}, actual)
+}
+
 func TestAllowTrailing(t *testing.T) {
 	type G struct {
 		Name string `@Ident`
@@ -1385,6 +1502,55 @@ func TestASTTokens(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestASTTokensInRepetition(t *testing.T) {
+	type item struct {
+		Tokens []lexer.Token
+
+		Name  string `@Ident`
+		Value *int   `("=" @Int)?`
+	}
+	type list struct {
+		Tokens []lexer.Token
+
+		Items []*item `"[" (@@ ("," @@)*)? "]"`
+	}
+
+	p := mustTestParser[list](t,
+		participle.Elide("Whitespace"),
+		participle.Lexer(lexer.MustSimple([]lexer.SimpleRule{
+			{"Ident", `[a-z]+`},
+			{"Int", `\d+`},
+			{"Punct", `[][,=]`},
+			{"Whitespace", `\s+`},
+		})))
+	actual, err := p.ParseString("", "[a=1, b]")
+	assert.NoError(t, err)
+	tokens := []lexer.Token{
+		{-4, "[", lexer.Position{Line: 1, Column: 1}},
+		{-2, "a", lexer.Position{Offset: 1, Line: 1, Column: 2}},
+		{-4, "=", lexer.Position{Offset: 2, Line: 1, Column: 3}},
+		{-3, "1", lexer.Position{Offset: 3, Line: 1, Column: 4}},
+		{-4, ",", lexer.Position{Offset: 4, Line: 1, Column: 5}},
+		{-5, " ", lexer.Position{Offset: 5, Line: 1, Column: 6}},
+		{-2, "b", lexer.Position{Offset: 6, Line: 1, Column: 7}},
+		{-4, "]", lexer.Position{Offset: 7, Line: 1, Column: 8}},
+	}
+	one := 1
+	expected := &list{
+		Tokens: tokens,
+		Items: []*item{
+			{Tokens: tokens[1:4], Name: "a", Value: &one},
+			{Tokens: tokens[5:7], Name: "b"},
+		},
+	}
+	assert.Equal(t, expected, actual)
+
+	// Appending to a node's tokens must not clobber those of other nodes.
+	actual.Items[0].Tokens = append(actual.Items[0].Tokens, lexer.Token{Value: ";"})
+	assert.Equal(t, tokens, actual.Tokens)
+	assert.Equal(t, tokens[5:7], actual.Items[1].Tokens)
+}
+
 func TestCaptureIntoToken(t *testing.T) {
 	type ast struct {
 		Head lexer.Token   `@Ident`
@@ -1434,6 +1600,112 @@ func TestEndPos(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 0, mod.First.Pos.Offset)
 	assert.Equal(t, 3, mod.First.EndPos.Offset)
+
+	// EndPos follows the token as it appeared in the input, before mapping.
+	type String struct {
+		Pos    lexer.Position
+		EndPos lexer.Position
+		Value  string `parser:"@String"`
+	}
+	str, err := participle.MustBuild[String](participle.Unquote()).ParseString("", `"a\nb" `)
+	assert.NoError(t, err)
+	assert.Equal(t, "a\nb", str.Value)
+	assert.Equal(t, lexer.Position{Offset: 6, Line: 1, Column: 7}, str.EndPos)
+}
+
+func TestEndPosExcludesTrailingElidedTokens(t *testing.T) {
+	type Ident struct {
+		Pos     lexer.Position
+		EndPos  lexer.Position
+		Text    string `parser:"@Ident"`
+		Comment string `parser:"@Comment?"`
+	}
+
+	type AST struct {
+		Idents []*Ident `parser:"@@*"`
+	}
+
+	parser := participle.MustBuild[AST](
+		participle.Lexer(lexer.MustSimple([]lexer.SimpleRule{
+			{"Ident", `\w+`},
+			{"Comment", `#[^\n]*`},
+			{"whitespace", `\s+`},
+		})),
+		participle.Elide("Comment"),
+	)
+
+	ast, err := parser.ParseString("", "foo #comment\nbar  baz  ")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(ast.Idents))
+	assert.Equal(t, "#comment", ast.Idents[0].Comment)
+	assert.Equal(t, lexer.Position{Offset: 3, Line: 1, Column: 4}, ast.Idents[0].EndPos)
+	assert.Equal(t, lexer.Position{Offset: 16, Line: 2, Column: 4}, ast.Idents[1].EndPos)
+	assert.Equal(t, lexer.Position{Offset: 21, Line: 2, Column: 9}, ast.Idents[2].EndPos)
+}
+
+func TestCaptureSource(t *testing.T) {
+	type Call struct {
+		Text string   `source:"true"`
+		Name string   `parser:"@Ident '('"`
+		Args []string `parser:"(@String (',' @String)*)? ')'"`
+	}
+	type Program struct {
+		Source string  `source:"true"`
+		Calls  []*Call `parser:"@@*"`
+	}
+	def := lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\w+`},
+		{"String", `"[^"]*"`},
+		{"Comment", `#[^\n]*`},
+		{"Punct", `[(),]`},
+		{"whitespace", `\s+`},
+	})
+	parser := participle.MustBuild[Program](participle.Lexer(def), participle.Elide("Comment", "whitespace"),
+		participle.Unquote("String"), participle.CaptureSource())
+
+	source := "  f( \"a\" , # comment\n\"b\")\ng()  # trailing\n"
+	ast, err := parser.ParseString("", source)
+	assert.NoError(t, err)
+	assert.Equal(t, "f( \"a\" , # comment\n\"b\")\ng()", ast.Source)
+	assert.Equal(t, "f( \"a\" , # comment\n\"b\")", ast.Calls[0].Text)
+	assert.Equal(t, []string{"a", "b"}, ast.Calls[0].Args)
+	assert.Equal(t, "g()", ast.Calls[1].Text)
+
+	ast, err = parser.Parse("", strings.NewReader(source))
+	assert.NoError(t, err)
+	assert.Equal(t, "g()", ast.Calls[1].Text)
+
+	// The caller's options are not overwritten when the input is retained.
+	options := make([]participle.ParseOption, 1, 2)
+	options[0] = participle.AllowTrailing(true)
+	_, err = parser.ParseString("", source, options[:1]...)
+	assert.NoError(t, err)
+	assert.True(t, options[:2][1] == nil)
+
+	// A struct matching no tokens has empty source.
+	ast, err = parser.ParseString("", "  # nothing\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "", ast.Source)
+
+	// Source is not captured without the option.
+	ast, err = participle.MustBuild[Program](participle.Lexer(def), participle.Elide("Comment", "whitespace")).ParseString("", source)
+	assert.NoError(t, err)
+	assert.Equal(t, "", ast.Calls[1].Text)
+
+	// An untagged Source field is captured by the grammar as usual.
+	type untagged struct {
+		Source string `parser:"@Ident"`
+	}
+	actual, err := participle.MustBuild[untagged](participle.Lexer(def), participle.CaptureSource()).ParseString("", "f")
+	assert.NoError(t, err)
+	assert.Equal(t, &untagged{Source: "f"}, actual)
+
+	type invalid struct {
+		Source int    `source:"true"`
+		Name   string `parser:"@Ident"`
+	}
+	_, err = participle.Build[invalid]()
+	assert.EqualError(t, err, `participle_test.invalid: Source: source tag requires a string field, not int`)
 }
 
 func TestBug(t *testing.T) {
@@ -1513,6 +1785,41 @@ func TestCaptureOnSliceElements(t *testing.T) { // nolint:dupl
 	assert.Equal(t, expected, captured)
 }
 
+type typedNumber struct {
+	Float  bool
+	Offset int
+}
+
+func (n *typedNumber) CaptureTokens(tokens []lexer.Token) error {
+	if tokens[0].Value == "0" {
+		return participle.Errorf(tokens[0].Pos, "zero is not allowed")
+	}
+	*n = typedNumber{Float: tokens[0].Type == lexer.TokenType(scanner.Float), Offset: tokens[0].Pos.Offset}
+	return nil
+}
+
+// Capture is ignored in favour of CaptureTokens.
+func (n *typedNumber) Capture(values []string) error {
+	panic("Capture called")
+}
+
+func TestTokenCapture(t *testing.T) {
+	type grammar struct {
+		Single *typedNumber  `@(Int | Float)`
+		Slice  []typedNumber `@(Int | Float)*`
+	}
+	parser := mustTestParser[grammar](t)
+	actual, err := parser.ParseString("", `1.5 2  3.0`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{
+		Single: &typedNumber{Float: true},
+		Slice:  []typedNumber{{Offset: 4}, {Float: true, Offset: 7}},
+	}, actual)
+
+	_, err = parser.ParseString("", `1 2 0`)
+	assert.EqualError(t, err, `1:5: grammar.Slice: zero is not allowed`)
+}
+
 type sliceParse string
 
 func (s *sliceParse) Parse(lex *lexer.PeekingLexer) error {
@@ -1651,6 +1958,60 @@ func TestMatchEOF(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestMatchEOFOption(t *testing.T) {
+	lex := lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\w+`},
+		{"EOL", `\n`},
+		{"whitespace", `[ \t]+`},
+	})
+	type line struct {
+		Words []string `@Ident*`
+		End   string   `( @EOL | @EOF )`
+	}
+	type lines struct {
+		Lines []*line `@@*`
+	}
+	p := mustTestParser[lines](t, participle.Lexer(lex), participle.MatchEOF())
+	actual, err := p.ParseString("", "a b\nc d")
+	assert.NoError(t, err)
+	assert.Equal(t, &lines{Lines: []*line{
+		{Words: []string{"a", "b"}, End: "\n"},
+		{Words: []string{"c", "d"}},
+	}}, actual)
+
+	actual, err = p.ParseString("", "a b\n")
+	assert.NoError(t, err)
+	assert.Equal(t, &lines{Lines: []*line{
+		{Words: []string{"a", "b"}, End: "\n"},
+		{},
+	}}, actual)
+
+	type words struct {
+		Words []string `( @Ident | @EOF )*`
+	}
+	wp := mustTestParser[words](t, participle.Lexer(lex), participle.MatchEOF())
+	w, err := wp.ParseString("", "a b")
+	assert.NoError(t, err)
+	assert.Equal(t, &words{Words: []string{"a", "b", ""}}, w)
+
+	type captures struct {
+		Word  string      `@Ident`
+		EOF   bool        `@EOF`
+		Value *string     `@EOF`
+		Token lexer.Token `@EOF`
+	}
+	cp := mustTestParser[captures](t, participle.Lexer(lex), participle.MatchEOF())
+	c, err := cp.ParseString("", "a ")
+	assert.NoError(t, err)
+	empty := ""
+	assert.Equal(t, &captures{
+		Word:  "a",
+		EOF:   true,
+		Value: &empty,
+		Token: lexer.Token{Type: lexer.EOF, Pos: lexer.Position{Offset: 2, Line: 1, Column: 3}},
+	}, c)
+}
+
 func TestParseExplicitElidedIdent(t *testing.T) { // nolint
 	lex := lexer.MustSimple([]lexer.SimpleRule{
 		{"Ident", `[a-zA-Z](\w|\.|/|:|-)*`},
@@ -1693,6 +2054,38 @@ func TestParseExplicitElidedTypedLiteral(t *testing.T) { // nolint
 	assert.Equal(t, &grammar{Comment: `/* Comment */`, Ident: "hello"}, actual)
 }
 
+func TestParseExplicitElidedDocComments(t *testing.T) {
+	lex := lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\w+`},
+		{"Comment", `//[^\n]*`},
+		{"Punct", `[=+;]`},
+		{"Whitespace", `\s+`},
+	})
+	type statement struct {
+		Doc   []string `@Comment*`
+		Name  string   `@Ident "="`
+		Terms []string `@Ident ("+" @Ident)* ";"`
+	}
+	type grammar struct {
+		Statements []*statement `@@*`
+	}
+	p := mustTestParser[grammar](t, participle.Lexer(lex), participle.Elide("Whitespace", "Comment"))
+
+	// Comments are captured where the grammar refers to them, and elided elsewhere.
+	actual, err := p.ParseString("", `
+// The sum.
+// Of a and b.
+sum = a + // The second term.
+  b;
+product = c;
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Statements: []*statement{
+		{Doc: []string{"// The sum.", "// Of a and b."}, Name: "sum", Terms: []string{"a", "b"}},
+		{Name: "product", Terms: []string{"c"}},
+	}}, actual)
+}
+
 func TestEmptySequenceMatches(t *testing.T) {
 	lex := lexer.MustSimple([]lexer.SimpleRule{
 		{"Ident", `[a-zA-Z](\w|\.|/|:|-)*`},
@@ -1846,6 +2239,70 @@ BMember2 = "{" TestUnionA "}" .
 	`), parser.String())
 }
 
+func TestParserWithUnregisteredUnion(t *testing.T) {
+	type grammar struct {
+		A TestUnionA `@@`
+	}
+	_, err := participle.Build[grammar]()
+	assert.EqualError(t, err, `A: interface type participle_test.TestUnionA requires participle.Union[participle_test.TestUnionA](...)`)
+
+	type nested struct {
+		Bs []TestUnionB `@@*`
+	}
+	_, err = participle.Build[nested]()
+	assert.EqualError(t, err, `Bs: interface type participle_test.TestUnionB requires participle.Union[participle_test.TestUnionB](...)`)
+
+	// Members of a registered union are checked too.
+	_, err = participle.Build[grammar](participle.Union[TestUnionA](AMember1{}, AMember2{}))
+	assert.EqualError(t, err, `V: interface type participle_test.TestUnionB requires participle.Union[participle_test.TestUnionB](...)`)
+}
+
+type tokenStatement interface{ isTokenStatement() }
+
+type (
+	selectStatement struct {
+		Column string `"select" @Ident`
+	}
+	dropStatement struct {
+		Table string `"drop" "table" @Ident`
+	}
+	assignStatement struct {
+		Name  string `@Ident "="`
+		Value int    `@Int`
+	}
+)
+
+func (*selectStatement) isTokenStatement() {}
+func (*dropStatement) isTokenStatement()   {}
+func (*assignStatement) isTokenStatement() {}
+
+func TestUnionByToken(t *testing.T) {
+	type grammar struct {
+		Statements []tokenStatement `(@@ ";")*`
+	}
+	parser := mustTestParser[grammar](t, participle.UnionByToken(map[string]tokenStatement{
+		"select": &selectStatement{},
+		"drop":   &dropStatement{},
+		"Ident":  &assignStatement{},
+	}))
+	actual, err := parser.ParseString("", `select a; drop table b; c = 1;`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Statements: []tokenStatement{
+		&selectStatement{Column: "a"},
+		&dropStatement{Table: "b"},
+		&assignStatement{Name: "c", Value: 1},
+	}}, actual)
+
+	_, err = parser.ParseString("", `select a; 1;`)
+	assert.EqualError(t, err, `1:11: unexpected token "1" (expected "drop" | "select" | <ident>)`)
+
+	_, err = parser.ParseString("", `drop b;`)
+	assert.EqualError(t, err, `1:6: unexpected token "b" (expected "table" <ident>)`)
+
+	_, err = participle.Build[grammar](participle.UnionByToken(map[string]string{}))
+	assert.EqualError(t, err, `UnionByToken: union type must be an interface (got string)`)
+}
+
 func TestParseSubProduction(t *testing.T) {
 	type (
 		ListItem struct {
@@ -1885,6 +2342,39 @@ func TestParseSubProduction(t *testing.T) {
 	assert.Equal(t, &expectedItem2, actualItem2)
 }
 
+func TestParseProductionFromLexer(t *testing.T) {
+	type Value struct {
+		Number *float64 `  @Float | @Int`
+		String *string  `| @String`
+	}
+	type Grammar struct {
+		Values []*Value `@@*`
+	}
+	p := mustTestParser[Grammar](t, participle.Unquote())
+	vp, err := participle.ParserForProduction[Value](p)
+	assert.NoError(t, err)
+
+	// A hand-written parser for "let <ident> = <value> ;", delegating to participle for values.
+	source, err := p.Lexer().Lex("", strings.NewReader(`let a = "hello"; let b = 1.5;`))
+	assert.NoError(t, err)
+	lex, err := lexer.Upgrade(source)
+	assert.NoError(t, err)
+	names := []string{}
+	values := []*Value{}
+	for !lex.Peek().EOF() {
+		assert.Equal(t, "let", lex.Next().Value)
+		names = append(names, lex.Next().Value)
+		assert.Equal(t, "=", lex.Next().Value)
+		value, err := vp.ParseFromLexer(lex, participle.AllowTrailing(true))
+		assert.NoError(t, err)
+		values = append(values, value)
+		assert.Equal(t, ";", lex.Next().Value)
+	}
+	hello, number := "hello", 1.5
+	assert.Equal(t, []string{"a", "b"}, names)
+	assert.Equal(t, []*Value{{String: &hello}, {Number: &number}}, values)
+}
+
 type I255Grammar struct {
 	Union I255Union `@@`
 }
@@ -1924,3 +2414,1362 @@ func TestParseNumbers(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, grammar{Int: -30, Uint: 3000, Float: math.Inf(1)}, *result)
 }
+
+func TestUniqueAlternatives(t *testing.T) {
+	type grammar struct {
+		Distinct bool   `"select" { @"distinct"`
+		All      bool   `         | @"all" }!:unique`
+		Name     string `@Ident`
+	}
+	parser := mustTestParser[grammar](t)
+	assert.Equal(t, `Grammar = "select" ("distinct" | "all")!:unique <ident> .`, parser.String())
+
+	actual, err := parser.ParseString("", `select all distinct foo`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Distinct: true, All: true, Name: "foo"}, actual)
+
+	actual, err = parser.ParseString("", `select distinct foo`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Distinct: true, Name: "foo"}, actual)
+
+	actual, err = parser.ParseString("", `select foo`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Name: "foo"}, actual)
+
+	_, err = parser.ParseString("", `select distinct all distinct foo`)
+	assert.EqualError(t, err, `1:21: "distinct" can only be matched once`)
+
+	// Alternatives that match empty input are not reported as duplicates.
+	type optional struct {
+		Distinct bool     `"select" { @"distinct"?`
+		Tags     []string `         | @("#" Ident) }!:unique`
+		Name     string   `@Ident`
+	}
+	actualOptional, err := mustTestParser[optional](t).ParseString("", `select #a distinct foo`)
+	assert.NoError(t, err)
+	assert.Equal(t, &optional{Distinct: true, Tags: []string{"#", "a"}, Name: "foo"}, actualOptional)
+
+	// A token type following "!" is not mistaken for a modifier.
+	def := lexer.MustSimple([]lexer.SimpleRule{
+		{"unique", `\$`},
+		{"Ident", `\w+`},
+	})
+	type reference struct {
+		Name string `@Ident! unique`
+	}
+	assert.Equal(t, `Reference = <ident>! <unique> .`, mustTestParser[reference](t, participle.Lexer(def)).String())
+}
+
+func TestMinimumRepetitions(t *testing.T) {
+	type statement struct {
+		Name string `@Ident ";"`
+	}
+	type grammar struct {
+		Statements []*statement `"{" @@!:min(1) "}"`
+	}
+	parser := mustTestParser[grammar](t)
+	assert.Equal(t, `Grammar = "{" Statement!:min(1) "}" .
+Statement = <ident> ";" .`, parser.String())
+
+	actual, err := parser.ParseString("", `{ a; b; }`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Statements: []*statement{{"a"}, {"b"}}}, actual)
+
+	_, err = parser.ParseString("", `{ }`)
+	assert.EqualError(t, err, `1:3: expected at least one Statement`)
+
+	type pair struct {
+		Names []string `@Ident!:min(2, "a pair needs two names")`
+	}
+	_, err = mustTestParser[pair](t).ParseString("", `a`)
+	assert.EqualError(t, err, `1:2: a pair needs two names`)
+
+	type invalid struct {
+		Names []string `@Ident!:min(0)`
+	}
+	_, err = participle.Build[invalid]()
+	assert.EqualError(t, err, `Names: minimum count must be a positive integer but got "0"`)
+
+	type unknown struct {
+		Names []string `@Ident!:max(2)`
+	}
+	_, err = participle.Build[unknown]()
+	assert.EqualError(t, err, `Names: expected unique or min after !: but got "max"`)
+}
+
+func TestPredicate(t *testing.T) {
+	type statement struct {
+		Typedef     string `  "typedef" @Ident &declare ";"`
+		PointerDecl string `| @Ident &isType "*"`
+		PointerName string `  @Ident ";"`
+		Left        string `| @Ident "*"`
+		Right       string `  @Ident ";"`
+	}
+	type grammar struct {
+		Statements []*statement `@@*`
+	}
+	types := func(ctx *participle.ParseContext) map[string]bool { return ctx.State().(map[string]bool) }
+	parser := mustTestParser[grammar](t,
+		participle.Predicate("declare", func(ctx *participle.ParseContext) bool {
+			types(ctx)[ctx.Previous().Value] = true
+			return true
+		}),
+		participle.Predicate("isType", func(ctx *participle.ParseContext) bool {
+			return types(ctx)[ctx.Previous().Value]
+		}))
+	assert.Equal(t, `Grammar = Statement* .
+Statement = ("typedef" <ident> &declare ";") | (<ident> &isType "*" <ident> ";") | (<ident> "*" <ident> ";") .`, parser.String())
+
+	actual, err := parser.ParseString("", `foo * bar; typedef foo; foo * bar;`, participle.State(map[string]bool{}))
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Statements: []*statement{
+		{Left: "foo", Right: "bar"},
+		{Typedef: "foo"},
+		{PointerDecl: "foo", PointerName: "bar"},
+	}}, actual)
+
+	_, err = participle.Build[grammar](participle.Predicate("declare", func(*participle.ParseContext) bool { return true }))
+	assert.EqualError(t, err, `Statements: PointerDecl: unknown predicate "isType"`)
+}
+
+func TestTransform(t *testing.T) {
+	type grammar struct {
+		Keyword string   `parser:"@Ident" transform:"upper"`
+		Names   []string `parser:"@String*" transform:"unquote,trim,upper"`
+		Count   int      `parser:"@Ident?" transform:"digits"`
+	}
+	digits := map[string]string{"one": "1", "two": "2"}
+	options := []participle.Option{
+		participle.Transform("upper", strings.ToUpper),
+		participle.Transform("trim", strings.TrimSpace),
+		participle.Transform("unquote", strconv.Unquote),
+		participle.Transform("digits", func(s string) (string, error) {
+			if d, ok := digits[s]; ok {
+				return d, nil
+			}
+			return "", fmt.Errorf("unknown number %q", s)
+		}),
+	}
+	parser := mustTestParser[grammar](t, options...)
+
+	actual, err := parser.ParseString("", `select " a " "b" two`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Keyword: "SELECT", Names: []string{"A", "B"}, Count: 2}, actual)
+
+	_, err = parser.ParseString("", `select "a" three`)
+	assert.EqualError(t, err, `1:12: Count: unknown number "three"`)
+
+	_, err = participle.Build[grammar](participle.Transform("upper", strings.ToUpper))
+	assert.EqualError(t, err, `Names: unknown transform "unquote"`)
+
+	_, err = participle.Build[grammar](append(options, participle.Transform("upper", strings.ToLower))...)
+	assert.EqualError(t, err, `duplicate transform "upper"`)
+
+	type nested struct {
+		Inner *grammar `parser:"@@" transform:"upper"`
+	}
+	_, err = participle.Build[nested](options...)
+	assert.EqualError(t, err, `Inner: transform tag can not be used with @@`)
+}
+
+func TestBalanced(t *testing.T) {
+	type attribute struct {
+		Name string   `"#" "[" @Ident`
+		Args []string `@Balanced("(", ")", "[", "]")? "]"`
+	}
+	type grammar struct {
+		Attributes []*attribute `@@*`
+	}
+	parser := mustTestParser[grammar](t)
+	assert.Equal(t, `Grammar = Attribute* .
+Attribute = "#" "[" <ident> Balanced("(", ")", "[", "]")? "]" .`, parser.String())
+
+	actual, err := parser.ParseString("", `#[derive(Debug, f(x[1]))] #[inline] #[empty()]`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Attributes: []*attribute{
+		{Name: "derive", Args: []string{"Debug", ",", "f", "(", "x", "[", "1", "]", ")"}},
+		{Name: "inline"},
+		{Name: "empty"},
+	}}, actual)
+
+	_, err = parser.ParseString("", `#[derive(Debug]`)
+	assert.EqualError(t, err, `1:15: unexpected token "]" (expected ")")`)
+
+	_, err = parser.ParseString("", `#[derive(f(x)`)
+	assert.EqualError(t, err, `1:14: unexpected token "<EOF>" (expected ")")`)
+
+	type tokens struct {
+		Body []lexer.Token `"macro" @Balanced("{", "}")`
+	}
+	body, err := mustTestParser[tokens](t).ParseString("", `macro { a { b } }`)
+	assert.NoError(t, err)
+	values := []string{}
+	for _, token := range body.Body {
+		values = append(values, token.Value)
+	}
+	assert.Equal(t, []string{"a", "{", "b", "}"}, values)
+
+	type invalid struct {
+		Body string `@Balanced("{")`
+	}
+	_, err = participle.Build[invalid]()
+	assert.EqualError(t, err, `Body: Balanced() requires pairs of open and close delimiters`)
+}
+
+func TestParserWithCustomProductionContext(t *testing.T) {
+	type grammar struct {
+		Custom TestCustom `@@`
+	}
+
+	p := mustTestParser[grammar](t, participle.ParseTypeWithContext(func(ctx *participle.ParseContext) (TestCustom, error) {
+		peek := ctx.Peek()
+		if peek.Type != scanner.Ident {
+			return nil, participle.NextMatch
+		}
+		ctx.Tracef("custom ident %q", peek.Value)
+		checkpoint := ctx.MakeCheckpoint()
+		name := ctx.Next().Value
+		if next := ctx.Peek(); next.Value == "!" {
+			ctx.MaybeUpdateError(participle.Errorf(next.Pos, "%s cannot be followed by !", name))
+			ctx.LoadCheckpoint(checkpoint)
+			return nil, participle.NextMatch
+		}
+		return CustomIdent(name), nil
+	}))
+
+	trace := &strings.Builder{}
+	actual, err := p.ParseString("", "a", participle.Trace(trace))
+	assert.NoError(t, err)
+	assert.Equal(t, TestCustom(CustomIdent("a")), actual.Custom)
+	assert.Contains(t, trace.String(), `custom ident "a"`)
+
+	_, err = p.ParseString("", "a !")
+	assert.EqualError(t, err, `1:3: a cannot be followed by !`)
+}
+
+func TestMaxDepth(t *testing.T) {
+	type nested struct {
+		Inner *nested `  "(" @@ ")"`
+		Leaf  string  `| @Ident`
+	}
+	p := mustTestParser[nested](t, participle.MaxDepth(10))
+
+	_, err := p.ParseString("", strings.Repeat("(", 9)+"x"+strings.Repeat(")", 9))
+	assert.NoError(t, err)
+
+	_, err = p.ParseString("", strings.Repeat("(", 10)+"x"+strings.Repeat(")", 10))
+	assert.EqualError(t, err, `1:11: maximum nesting depth of 10 exceeded`)
+
+	// Input nested far beyond the limit fails just as quickly.
+	_, err = p.ParseString("", strings.Repeat("(", 100_000))
+	assert.EqualError(t, err, `1:11: maximum nesting depth of 10 exceeded`)
+
+	_, err = participle.Build[nested](participle.MaxDepth(0))
+	assert.EqualError(t, err, `MaxDepth: limit must be positive, got 0`)
+}
+
+func TestParseContextLimitsAndRecovery(t *testing.T) {
+	type grammar struct {
+		Custom TestCustom `@@`
+	}
+
+	var parseNested func(ctx *participle.ParseContext) (TestCustom, error)
+	parseNested = func(ctx *participle.ParseContext) (TestCustom, error) {
+		if err := ctx.CheckLimits(); err != nil {
+			return nil, err
+		}
+		if ctx.Peek().Value != "(" {
+			return nil, participle.NextMatch
+		}
+		if err := ctx.Enter(); err != nil {
+			return nil, err
+		}
+		defer ctx.Leave()
+		ctx.Next()
+		inner, err := parseNested(ctx)
+		if err != nil && err != participle.NextMatch {
+			return nil, err
+		}
+		if token := ctx.Peek(); token.Value == ")" {
+			ctx.Next()
+		} else if err := participle.Errorf(token.Pos, `expected ")"`); ctx.Recovering() {
+			ctx.RecordError(err)
+		} else {
+			return nil, err
+		}
+		if inner == nil {
+			return CustomIdent("()"), nil
+		}
+		return CustomIdent("(" + string(inner.(CustomIdent)) + ")"), nil
+	}
+	p := mustTestParser[grammar](t, participle.ParseTypeWithContext(parseNested), participle.MaxDepth(4))
+
+	actual, err := p.ParseString("", "(())")
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Custom: CustomIdent("(())")}, actual)
+
+	_, err = p.ParseString("", "(((())))")
+	assert.EqualError(t, err, `1:4: maximum nesting depth of 4 exceeded`)
+
+	_, err = p.ParseString("", "(()")
+	assert.EqualError(t, err, `1:4: expected ")"`)
+
+	actual, err = p.ParseString("", "(()", participle.InsertExpected())
+	assert.EqualError(t, err, `1:4: expected ")"`)
+	assert.Equal(t, &grammar{Custom: CustomIdent("(())")}, actual)
+}
+
+func TestLookbehindGroup(t *testing.T) {
+	type grammar struct {
+		Values []string `( @Ident | @Int | (?<! Ident | ")") @"-" | (?<= "(") @"x" | @"(" | @")" )*`
+	}
+	parser := mustTestParser[grammar](t)
+	assert.Equal(t, `Grammar = (<ident> | <int> | ((?<! <ident> | ")") "-") | ((?<= "(") "x") | "(" | ")")* .`, parser.String())
+
+	actual, err := parser.ParseString("", `- 1 - a ( x )`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Values: []string{"-", "1", "-", "a", "(", "x", ")"}}, actual)
+
+	_, err = parser.ParseString("", `a -`)
+	assert.EqualError(t, err, `1:3: unexpected token "-"`)
+
+	_, err = parser.ParseString("", `( ) -`)
+	assert.EqualError(t, err, `1:5: unexpected token "-"`)
+}
+
+// Declares the "Whitespace" and "Comment" symbols of a lexer as trivia.
+type triviaDefinition struct {
+	lexer.Definition
+}
+
+func (triviaDefinition) Trivia() []string { return []string{"Whitespace", "Comment"} }
+
+func TestLexerTrivia(t *testing.T) {
+	type grammar struct {
+		Words []string `@Ident+`
+	}
+
+	lex := triviaDefinition{lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\w+`},
+		{"Comment", `#[^\n]*`},
+		{"Whitespace", `\s+`},
+	})}
+	p := mustTestParser[grammar](t, participle.Lexer(lex))
+	actual, err := p.ParseString("", "hello # greeting\nworld")
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Words: []string{"hello", "world"}}, actual)
+
+	// An explicit Elide takes precedence.
+	p = mustTestParser[grammar](t, participle.Lexer(lex), participle.Elide())
+	_, err = p.ParseString("", "hello world")
+	assert.EqualError(t, err, `1:6: unexpected token " "`)
+
+	_, err = participle.Build[grammar](participle.Lexer(triviaDefinition{lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\w+`},
+		{"Whitespace", `\s+`},
+	})}))
+	assert.EqualError(t, err, `lexer trivia uses unknown token "Comment"`)
+}
+
+func TestDefaultElide(t *testing.T) {
+	type grammar struct {
+		Tokens []lexer.Token
+
+		Words []string `@Ident+`
+	}
+
+	// The stateful lexer drops lower-case rules itself, so use a lexer that does not.
+	const (
+		ident = iota + 1
+		whitespace
+	)
+	lex := lexer.Must(lexer.NewExternal(map[int]string{ident: "Ident", whitespace: "whitespace"}, nil))
+	tokens := []lexer.Token{
+		{Type: ident, Value: "hello", Pos: lexer.Position{Line: 1, Column: 1}},
+		{Type: whitespace, Value: " ", Pos: lexer.Position{Offset: 5, Line: 1, Column: 6}},
+		{Type: ident, Value: "world", Pos: lexer.Position{Offset: 6, Line: 1, Column: 7}},
+	}
+	p := mustTestParser[grammar](t, participle.Lexer(lex), participle.DefaultElide())
+	actual, err := p.ParseTokens("", tokens)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Tokens: tokens, Words: []string{"hello", "world"}}, actual)
+
+	// An explicit Elide takes precedence.
+	p = mustTestParser[grammar](t, participle.Lexer(lex), participle.DefaultElide(), participle.Elide("Ident"))
+	_, err = p.ParseTokens("", tokens)
+	assert.Error(t, err)
+}
+
+func TestCaptureAlternative(t *testing.T) {
+	type operator struct {
+		Alternative int `alternative:"true"`
+
+		Op  string `  @"+" | @"-"`
+		Mul bool   `| "*"`
+		Div bool   `| "/"`
+	}
+	type grammar struct {
+		Operators []*operator `@@*`
+	}
+	parser := mustTestParser[grammar](t)
+	actual, err := parser.ParseString("", `- * / +`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Operators: []*operator{
+		{Alternative: 1, Op: "-"},
+		{Alternative: 2},
+		{Alternative: 3},
+		{Alternative: 0, Op: "+"},
+	}}, actual)
+
+	// Untagged fields are not populated.
+	type untagged struct {
+		Alternative int
+
+		Op  string `  @"+"`
+		Mul bool   `| @"*"`
+	}
+	actualUntagged, err := mustTestParser[untagged](t).ParseString("", `*`)
+	assert.NoError(t, err)
+	assert.Equal(t, &untagged{Mul: true}, actualUntagged)
+
+	type invalid struct {
+		Alternative string `alternative:"true"`
+
+		Op string `@"+"`
+	}
+	_, err = participle.Build[invalid]()
+	assert.EqualError(t, err, "participle_test.invalid: Alternative: alternative tag requires an integer field, not string")
+}
+
+func TestSplitLiterals(t *testing.T) {
+	type grammar struct {
+		Table   string `"select" "from" @Ident`
+		OrderBy string `("order by" @Ident)?`
+		Desc    bool   `@"in descending order"?`
+	}
+	parser := mustTestParser[grammar](t, participle.SplitLiterals())
+	assert.Equal(t, `Grammar = "select" "from" <ident> ("order" "by" <ident>)? ("in" "descending" "order")? .`, parser.String())
+
+	actual, err := parser.ParseString("", `select from users order   by name in descending order`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Table: "users", OrderBy: "name", Desc: true}, actual)
+
+	_, err = mustTestParser[grammar](t).ParseString("", `select from users order by name`)
+	assert.Error(t, err)
+}
+
+func TestAdjacentLiterals(t *testing.T) {
+	type grammar struct {
+		LHS string `@Ident`
+		Op  string `@("!=" | "->" | "=")`
+		RHS string `@Ident`
+	}
+	parser := mustTestParser[grammar](t, participle.AdjacentLiterals("!=", "->"))
+	actual, err := parser.ParseString("", `a != b`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{LHS: "a", Op: "!=", RHS: "b"}, actual)
+	actual, err = parser.ParseString("", `a->b`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{LHS: "a", Op: "->", RHS: "b"}, actual)
+
+	_, err = parser.ParseString("", `a - > b`)
+	assert.EqualError(t, err, `1:3: unexpected token "-" (expected ("!=" | "->" | "=") <ident>)`)
+	_, err = parser.ParseString("", `a ! = b`)
+	assert.EqualError(t, err, `1:3: unexpected token "!" (expected ("!=" | "->" | "=") <ident>)`)
+
+	// Elided tokens between the parts also break the literal.
+	lex := lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\w+`},
+		{"Punct", `[-!=>]`},
+		{"Comment", `/\*.*?\*/`},
+		{"Whitespace", `\s+`},
+	})
+	parser = mustTestParser[grammar](t, participle.Lexer(lex), participle.Elide("Whitespace", "Comment"),
+		participle.AdjacentLiterals("!=", "->"))
+	actual, err = parser.ParseString("", `a -> b`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{LHS: "a", Op: "->", RHS: "b"}, actual)
+	_, err = parser.ParseString("", `a -/**/> b`)
+	assert.Error(t, err)
+
+	// Adjacency is determined by the input, not by mapped token values.
+	lex = lexer.MustSimple([]lexer.SimpleRule{
+		{"Ident", `\w+`},
+		{"Char", `'.'`},
+		{"Whitespace", `\s+`},
+	})
+	parser = mustTestParser[grammar](t, participle.Lexer(lex), participle.Elide("Whitespace"),
+		participle.Map(func(token lexer.Token) (lexer.Token, error) {
+			token.Value = token.Value[1:2]
+			return token, nil
+		}, "Char"),
+		participle.AdjacentLiterals("!=", "->"))
+	actual, err = parser.ParseString("", `a '-''>' b`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{LHS: "a", Op: "->", RHS: "b"}, actual)
+	_, err = parser.ParseString("", `a '-' '>' b`)
+	assert.Error(t, err)
+}
+
+func TestContextualKeyword(t *testing.T) {
+	type grammar struct {
+		Count bool   `"SELECT":Keyword ( @"COUNT":Keyword "(" "*" ")"`
+		Field string `                 | @Ident ) "FROM":Keyword`
+		Table string `@Ident`
+	}
+	def := lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "Keyword", Pattern: `\b(SELECT|FROM)\b`},
+		{Name: "Ident", Pattern: `\w+`},
+		{Name: "Punct", Pattern: `[(*)]`},
+		{Name: "Whitespace", Pattern: `\s+`},
+	})
+	// COUNT is not reserved, so it is lexed as an identifier.
+	nonReserved := map[string]bool{"COUNT": true}
+	parser := mustTestParser[grammar](t, participle.Lexer(def), participle.Elide("Whitespace"), participle.UseLookahead(2),
+		participle.ContextualKeyword(func(token lexer.Token, keyword string) bool {
+			return token.Type == def.Symbols()["Ident"] && nonReserved[keyword]
+		}))
+	actual, err := parser.ParseString("", `SELECT COUNT(*) FROM users`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Count: true, Table: "users"}, actual)
+
+	// Elsewhere it remains an identifier.
+	actual, err = parser.ParseString("", `SELECT name FROM COUNT`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Field: "name", Table: "COUNT"}, actual)
+
+	_, err = mustTestParser[grammar](t, participle.Lexer(def), participle.Elide("Whitespace"), participle.UseLookahead(2)).
+		ParseString("", `SELECT COUNT(*) FROM users`)
+	assert.Error(t, err)
+}
+
+func TestLiteralMatch(t *testing.T) {
+	type grammar struct {
+		Key   string `@"key":String "="`
+		Value string `@String`
+	}
+	unquoted := func(literal, value string) bool { return strings.Trim(value, `"`) == literal }
+	parser := mustTestParser[grammar](t, participle.LiteralMatch("String", unquoted))
+	actual, err := parser.ParseString("", `"key" = "value"`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Key: `"key"`, Value: `"value"`}, actual)
+
+	_, err = parser.ParseString("", `"other" = "value"`)
+	assert.EqualError(t, err, `1:1: unexpected token "\"other\""`)
+
+	_, err = participle.Build[grammar](participle.LiteralMatch("Unknown", unquoted))
+	assert.EqualError(t, err, `LiteralMatch() uses unknown token "Unknown"`)
+}
+
+type afterParseRange struct {
+	Name string `@Ident`
+	Min  int    `@Int "-"`
+	Max  int    `@Int`
+}
+
+func (r *afterParseRange) AfterParse() error {
+	if r.Min > r.Max {
+		return fmt.Errorf("min %d must not be greater than max %d", r.Min, r.Max)
+	}
+	r.Name = strings.ToLower(r.Name)
+	return nil
+}
+
+func TestAfterParse(t *testing.T) {
+	type grammar struct {
+		Ranges []*afterParseRange `@@*`
+	}
+	parser := mustTestParser[grammar](t)
+	actual, err := parser.ParseString("", `A 1 - 2 B 3 - 3`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Ranges: []*afterParseRange{{"a", 1, 2}, {"b", 3, 3}}}, actual)
+
+	_, err = parser.ParseString("", `A 1 - 2 B 3 - 2`)
+	assert.EqualError(t, err, `1:9: min 3 must not be greater than max 2`)
+}
+
+type beforeParseKeyword struct {
+	Keyword string `@Ident`
+}
+
+func (k *beforeParseKeyword) BeforeParse(lex *lexer.PeekingLexer) error {
+	switch lex.Peek().Value {
+	case "reserved":
+		return errors.New("reserved is a reserved word")
+	case "skip":
+		return participle.NextMatch
+	}
+	return nil
+}
+
+func TestBeforeParse(t *testing.T) {
+	type grammar struct {
+		Keyword *beforeParseKeyword `  @@`
+		Skipped string              `| @"skip"`
+	}
+	parser := mustTestParser[grammar](t)
+	actual, err := parser.ParseString("", `hello`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Keyword: &beforeParseKeyword{"hello"}}, actual)
+
+	actual, err = parser.ParseString("", `skip`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Skipped: "skip"}, actual)
+
+	_, err = parser.ParseString("", `reserved`)
+	assert.EqualError(t, err, `1:1: reserved is a reserved word`)
+}
+
+func TestParseComplex(t *testing.T) {
+	type grammar struct {
+		Single   complex128   `@Complex`
+		Split    complex64    `@(Number ("+" | "-") Number "i")`
+		Multiple []complex128 `@Complex*`
+	}
+	parser := mustTestParser[grammar](t, participle.Lexer(lexer.MustSimple([]lexer.SimpleRule{
+		{"Complex", `[-+]?\d+(\.\d+)?[-+]\d+(\.\d+)?i`},
+		{"Number", `\d+(\.\d+)?`},
+		{"Punct", `[-+i]`},
+		{"whitespace", `\s+`},
+	})))
+	result, err := parser.ParseString("", `3+4i 1.5 - 2 i 1-1i -2+0.5i`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Single: 3 + 4i, Split: 1.5 - 2i, Multiple: []complex128{1 - 1i, -2 + 0.5i}}, result)
+
+	type bad struct {
+		Value complex64 `@Ident`
+	}
+	_, err = mustTestParser[bad](t).ParseString("", `nope`)
+	assert.EqualError(t, err, `bad.Value: strconv.ParseComplex: parsing "nope": invalid syntax`)
+}
+
+type sliceRootExpression struct {
+	Name  string `@Ident`
+	Value int    `"=" @Int ";"`
+}
+
+type sliceRootExpressions []*sliceRootExpression
+
+func TestSliceRoot(t *testing.T) {
+	parser := mustTestParser[sliceRootExpressions](t)
+	assert.Equal(t, `SliceRootExpressions = SliceRootExpression* .
+SliceRootExpression = <ident> "=" <int> ";" .`, parser.String())
+
+	actual, err := parser.ParseString("", `a = 1; b = 2;`)
+	assert.NoError(t, err)
+	assert.Equal(t, &sliceRootExpressions{{"a", 1}, {"b", 2}}, actual)
+
+	actual, err = parser.ParseString("", ``)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(*actual))
+
+	_, err = parser.ParseString("", `a = 1; b`)
+	assert.EqualError(t, err, `1:9: unexpected token "<EOF>" (expected "=" <int> ";")`)
+
+	unnamed, err := mustTestParser[[]sliceRootExpression](t).ParseString("", `a = 1;`)
+	assert.NoError(t, err)
+	assert.Equal(t, &[]sliceRootExpression{{"a", 1}}, unnamed)
+}
+
+type binaryOp func(a, b int) int
+
+func TestFuncRegistry(t *testing.T) {
+	type expr struct {
+		Left  int        `@Int`
+		Op    binaryOp   `@("+" | "-" | "*" | "<" "<")`
+		Right int        `@Int`
+		Ops   []binaryOp `("," @("+" | "-"))*`
+	}
+	parser := mustTestParser[expr](t, participle.FuncRegistry("operator", map[string]binaryOp{
+		"+":  func(a, b int) int { return a + b },
+		"-":  func(a, b int) int { return a - b },
+		"<<": func(a, b int) int { return a << b },
+	}))
+
+	actual, err := parser.ParseString("", `3 << 2, +, -`)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, actual.Op(actual.Left, actual.Right))
+	assert.Equal(t, 2, len(actual.Ops))
+	assert.Equal(t, 5, actual.Ops[0](3, 2))
+	assert.Equal(t, 1, actual.Ops[1](3, 2))
+
+	_, err = parser.ParseString("", `3 * 2`)
+	assert.EqualError(t, err, `1:3: unknown operator "*"`)
+
+	_, err = participle.Build[expr](participle.FuncRegistry("operator", map[string]int{}))
+	assert.EqualError(t, err, `FuncRegistry: F must be a function type (got int)`)
+
+	_, err = participle.Build[expr]()
+	assert.EqualError(t, err, `Op: no FuncRegistry for participle_test.binaryOp`)
+}
+
+func TestSeparatedRepetition(t *testing.T) {
+	type strict struct {
+		Values []string `"[" @Ident*%"," "]"`
+	}
+	parser := mustTestParser[strict](t)
+	assert.Equal(t, `Strict = "[" <ident>*%"," "]" .`, parser.String())
+
+	actual, err := parser.ParseString("", `[a, b, c]`)
+	assert.NoError(t, err)
+	assert.Equal(t, &strict{Values: []string{"a", "b", "c"}}, actual)
+
+	actual, err = parser.ParseString("", `[]`)
+	assert.NoError(t, err)
+	assert.Equal(t, &strict{}, actual)
+
+	_, err = parser.ParseString("", `[a, b,]`)
+	assert.EqualError(t, err, `1:6: unexpected token "," (expected "]")`)
+
+	_, err = parser.ParseString("", `[a b]`)
+	assert.EqualError(t, err, `1:4: unexpected token "b" (expected "]")`)
+
+	type value struct {
+		Name string `@Ident`
+	}
+	type trailing struct {
+		Values []*value `"[" @@+%","? "]"`
+	}
+	trailingParser := mustTestParser[trailing](t)
+	assert.Equal(t, `Trailing = "[" Value+%","? "]" .
+Value = <ident> .`, trailingParser.String())
+
+	for _, input := range []string{`[a, b]`, `[a, b,]`} {
+		actual, err := trailingParser.ParseString("", input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, &trailing{Values: []*value{{"a"}, {"b"}}}, actual, input)
+	}
+
+	_, err = trailingParser.ParseString("", `[a,,]`)
+	assert.EqualError(t, err, `1:4: unexpected token "," (expected "]")`)
+
+	_, err = trailingParser.ParseString("", `[]`)
+	assert.Error(t, err)
+
+	// A separator not followed by an element is backtracked over, so it can be matched after the list.
+	type variadic struct {
+		Args     []*value `"(" @@+%","`
+		Variadic bool     `("," @"*")? ")"`
+	}
+	variadicParser := mustTestParser[variadic](t)
+	actual2, err := variadicParser.ParseString("", `(a, b, *)`)
+	assert.NoError(t, err)
+	assert.Equal(t, &variadic{Args: []*value{{"a"}, {"b"}}, Variadic: true}, actual2)
+}
+
+func TestSkipCapture(t *testing.T) {
+	type annotation struct {
+		Names []string `"(" @Ident ("," @Ident)* ")"`
+	}
+	type grammar struct {
+		Annotation *annotation `parser:"@@?" skip:"true"`
+		Name       string      `parser:"@Ident"`
+	}
+	parser := mustTestParser[grammar](t)
+	assert.Equal(t, `Grammar = Annotation? <ident> .
+Annotation = "(" <ident> ("," <ident>)* ")" .`, parser.String())
+
+	actual, err := parser.ParseString("", `(a, b) name`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Name: "name"}, actual)
+
+	_, err = parser.ParseString("", `(a b) name`)
+	assert.EqualError(t, err, `1:4: unexpected token "b" (expected ")")`)
+}
+
+func TestUniqueKey(t *testing.T) {
+	type enumCase struct {
+		Pos   lexer.Position
+		Name  string `@Ident`
+		Value int    `("=" @Int)?`
+	}
+	type enum struct {
+		Name  string      `parser:"'enum' @Ident '{'"`
+		Cases []*enumCase `parser:"@@*%',' '}'" unique:"Name"`
+	}
+	parser := mustTestParser[enum](t)
+
+	actual, err := parser.ParseString("", `enum Colour { Red, Green = 2 }`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Red", "Green"}, []string{actual.Cases[0].Name, actual.Cases[1].Name})
+
+	_, err = parser.ParseString("", `enum Colour { Red, Green, Red = 3 }`)
+	assert.EqualError(t, err, `1:27: enum.Cases: duplicate Name "Red"`)
+
+	// Pointer keys are compared by value, and absent keys are not duplicates.
+	type pointerCase struct {
+		Name  *string `@Ident?`
+		Value int     `"=" @Int`
+	}
+	type pointerEnum struct {
+		Cases []*pointerCase `parser:"@@*%','" unique:"Name"`
+	}
+	_, err = mustTestParser[pointerEnum](t).ParseString("", `= 1, = 2, Red = 3, Red = 4`)
+	assert.EqualError(t, err, `1:20: pointerEnum.Cases: duplicate Name "Red"`)
+
+	type invalidKey struct {
+		Cases []*enumCase `parser:"@@*" unique:"Missing"`
+	}
+	_, err = participle.Build[invalidKey]()
+	assert.EqualError(t, err, `Cases: unique key "Missing" is not a comparable field of participle_test.enumCase`)
+
+	type invalidField struct {
+		Names []string `parser:"@Ident*" unique:"Name"`
+	}
+	_, err = participle.Build[invalidField]()
+	assert.EqualError(t, err, `Names: unique tag requires a slice of structs, not []string`)
+}
+
+type mapOption struct {
+	Pos   lexer.Position
+	Name  string `@Ident "="`
+	Value string `@(Ident | Int)`
+}
+
+func (o *mapOption) MapKey() string { return o.Name }
+
+func TestMapField(t *testing.T) {
+	type section struct {
+		Name    string                `parser:"'[' @Ident ']'" mapkey:"true"`
+		Options map[string]*mapOption `@@*`
+	}
+	type config struct {
+		Sections map[string]section `@@*`
+	}
+	type sectionKey struct {
+		Name section `mapkey:"true"`
+	}
+	parser := mustTestParser[section](t)
+
+	actual, err := parser.ParseString("", `[server] host = localhost port = 80`)
+	assert.NoError(t, err)
+	assert.Equal(t, &section{Name: "server", Options: map[string]*mapOption{
+		"host": {Pos: lexer.Position{Offset: 9, Line: 1, Column: 10}, Name: "host", Value: "localhost"},
+		"port": {Pos: lexer.Position{Offset: 26, Line: 1, Column: 27}, Name: "port", Value: "80"},
+	}}, actual)
+
+	_, err = parser.ParseString("", `[server] host = a port = 80 host = b`)
+	assert.EqualError(t, err, `1:29: section.Options: duplicate key "host"`)
+
+	parser = mustTestParser[section](t, participle.AllowDuplicateMapKeys())
+	actual, err = parser.ParseString("", `[server] host = a host = b`)
+	assert.NoError(t, err)
+	assert.Equal(t, "b", actual.Options["host"].Value)
+
+	// Values can also be keyed by a tagged field.
+	configParser := mustTestParser[config](t)
+	actualConfig, err := configParser.ParseString("", `[a] x = 1 [b]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, []string{actualConfig.Sections["a"].Name, actualConfig.Sections["b"].Name})
+
+	type invalidValue struct {
+		Values map[string]string `@@*`
+	}
+	_, err = participle.Build[invalidValue]()
+	assert.EqualError(t, err, `Values: map values must be structs, not string`)
+
+	type invalidKey struct {
+		Keys map[string]*sectionKey `@@*`
+	}
+	_, err = participle.Build[invalidKey]()
+	assert.EqualError(t, err, `Keys: map key participle_test.section of participle_test.sectionKey is not convertible to string`)
+}
+
+func TestLiteralSet(t *testing.T) {
+	type statement struct {
+		Keyword string `@Keyword`
+		Name    string `@Ident ";"`
+	}
+	type grammar struct {
+		Statements []*statement `@@*`
+	}
+	parser := mustTestParser[grammar](t, participle.LiteralSet("Keyword", []string{"var", "const"}))
+	assert.Equal(t, `Grammar = Statement* .
+Statement = <keyword> <ident> ";" .`, parser.String())
+
+	actual, err := parser.ParseString("", `var a; const b;`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Statements: []*statement{{"var", "a"}, {"const", "b"}}}, actual)
+
+	_, err = parser.ParseString("", `let c;`)
+	assert.EqualError(t, err, `1:1: unexpected token "let"`)
+
+	// The set can vary between parsers without changing the grammar or lexer.
+	parser = mustTestParser[grammar](t, participle.LiteralSet("Keyword", []string{"let"}))
+	_, err = parser.ParseString("", `let c;`)
+	assert.NoError(t, err)
+
+	_, err = participle.Build[grammar](participle.LiteralSet("Ident", []string{"a"}))
+	assert.EqualError(t, err, `LiteralSet() name "Ident" conflicts with a lexer symbol`)
+
+	// Suggestions from the set do not depend on map iteration order.
+	parser = mustTestParser[grammar](t, participle.LiteralSet("Keyword", []string{"rat", "mat", "hat", "cat", "bat"}),
+		participle.SuggestAlternatives())
+	for i := 0; i < 20; i++ {
+		_, err = parser.ParseString("", `xat c;`)
+		assert.EqualError(t, err, `1:1: unexpected token "xat", did you mean "bat"?`)
+	}
+}
+
+type colour int
+
+const (
+	red colour = iota + 1
+	green
+	blue
+)
+
+func TestEnum(t *testing.T) {
+	type pixel struct {
+		Colour  colour   `@("red" | "green" | "blue" | "purple")`
+		Palette []colour `("[" @("red" | "green" | "blue")* "]")?`
+	}
+	colours := map[string]colour{"red": red, "green": green, "blue": blue}
+	parser := mustTestParser[pixel](t, participle.Enum("colour", colours))
+
+	actual, err := parser.ParseString("", `green [blue red]`)
+	assert.NoError(t, err)
+	assert.Equal(t, &pixel{Colour: green, Palette: []colour{blue, red}}, actual)
+
+	_, err = parser.ParseString("", `purple`)
+	assert.EqualError(t, err, `1:1: unknown colour "purple"`)
+
+	_, err = participle.Build[pixel](participle.Enum("colour", colours), participle.Enum("colour", colours))
+	assert.EqualError(t, err, `Enum: duplicate registry for participle_test.colour`)
+}
+
+type perm uint
+
+func TestFlags(t *testing.T) {
+	type file struct {
+		Name  string `@Ident`
+		Perm  perm   `@("r" | "w" | "x")*`
+		Other perm   `("/" @("r" | "w" | "x" | "s")*)?`
+	}
+	perms := map[string]perm{"r": 4, "w": 2, "x": 1}
+	parser := mustTestParser[file](t, participle.Flags("permission", perms))
+
+	actual, err := parser.ParseString("", `a x r w / r`)
+	assert.NoError(t, err)
+	assert.Equal(t, &file{Name: "a", Perm: 7, Other: 4}, actual)
+
+	actual, err = parser.ParseString("", `b`)
+	assert.NoError(t, err)
+	assert.Equal(t, &file{Name: "b"}, actual)
+
+	_, err = parser.ParseString("", `c r / s`)
+	assert.EqualError(t, err, `1:7: unknown permission "s"`)
+
+	_, err = participle.Build[file](participle.Flags("permission", map[string]string{}))
+	assert.EqualError(t, err, `Flags: F must be an integer type (got string)`)
+}
+
+func TestRuneCapture(t *testing.T) {
+	type charRange struct {
+		From  rune   `parser:"@Char" rune:"true"`
+		To    rune   `parser:"'-' @Char" rune:"true"`
+		Extra []rune `parser:"('+' @Ident)*" rune:"true"`
+	}
+	parser := mustTestParser[charRange](t)
+	actual, err := parser.ParseString("", `'a' - '\n' + x + é`)
+	assert.NoError(t, err)
+	assert.Equal(t, &charRange{From: 'a', To: '\n', Extra: []rune{'x', 'é'}}, actual)
+
+	// Unquoted character literals are also decoded.
+	parser = mustTestParser[charRange](t, participle.Unquote("Char"))
+	actual, err = parser.ParseString("", `'0' - '9'`)
+	assert.NoError(t, err)
+	assert.Equal(t, &charRange{From: '0', To: '9'}, actual)
+
+	_, err = parser.ParseString("", `'0' - '9' + xy`)
+	assert.EqualError(t, err, `charRange.Extra: expected a single character but got "xy"`)
+
+	type invalid struct {
+		Name string `parser:"@Ident" rune:"true"`
+	}
+	_, err = participle.Build[invalid]()
+	assert.EqualError(t, err, `Name: rune tag requires a rune or []rune field, not string`)
+}
+
+func TestCaptureInto(t *testing.T) {
+	type declaration struct {
+		Visibility string   `parser:"@('public' | 'private')?"`
+		Name       string   `parser:"'var' @Ident"`
+		Modifiers  []string `parser:"@('static' | 'final')*"`
+		_          struct{} `parser:"(':' @('public' | 'private'))?" into:"Visibility"`
+		_          struct{} `parser:"@('static' | 'final')*" into:"Modifiers"`
+	}
+	parser := mustTestParser[declaration](t)
+
+	actual, err := parser.ParseString("", `public var a static`)
+	assert.NoError(t, err)
+	assert.Equal(t, &declaration{Visibility: "public", Name: "a", Modifiers: []string{"static"}}, actual)
+
+	actual, err = parser.ParseString("", `var a static : private final`)
+	assert.NoError(t, err)
+	assert.Equal(t, &declaration{Visibility: "private", Name: "a", Modifiers: []string{"static", "final"}}, actual)
+
+	_, err = parser.ParseString("", `public var a : private`)
+	assert.EqualError(t, err, `declaration.Visibility: can only be captured once`)
+
+	// A field captured several times by its own tag is also the target of another.
+	type qualified struct {
+		Name string   `parser:"'var' @Ident (@'.' @Ident)*"`
+		_    struct{} `parser:"('as' @Ident)?" into:"Name"`
+	}
+	qparser := mustTestParser[qualified](t)
+	actualQualified, err := qparser.ParseString("", `var a.b`)
+	assert.NoError(t, err)
+	assert.Equal(t, &qualified{Name: "a.b"}, actualQualified)
+
+	_, err = qparser.ParseString("", `var a.b as c`)
+	assert.EqualError(t, err, `qualified.Name: can only be captured once`)
+
+	type invalid struct {
+		_ struct{} `parser:"@Ident" into:"Missing"`
+	}
+	_, err = participle.Build[invalid]()
+	assert.EqualError(t, err, `_: into field "Missing" must be a capturing field of participle_test.invalid`)
+}
+
+func TestDepthField(t *testing.T) {
+	type expr struct {
+		Depth int     `depth:"true"`
+		Ident string  `parser:"  @Ident"`
+		Group []*expr `parser:"| '(' @@* ')'"`
+	}
+	parser := mustTestParser[expr](t)
+	actual, err := parser.ParseString("", `(a (b (c)) d)`)
+	assert.NoError(t, err)
+	assert.Equal(t, &expr{Group: []*expr{
+		{Depth: 1, Ident: "a"},
+		{Depth: 1, Group: []*expr{
+			{Depth: 2, Ident: "b"},
+			{Depth: 2, Group: []*expr{{Depth: 3, Ident: "c"}}},
+		}},
+		{Depth: 1, Ident: "d"},
+	}}, actual)
+
+	type invalid struct {
+		Depth string `depth:"true"`
+		Ident string `parser:"@Ident"`
+	}
+	_, err = participle.Build[invalid]()
+	assert.EqualError(t, err, `participle_test.invalid: Depth: depth tag requires an int field, not string`)
+}
+
+func TestParseInto(t *testing.T) {
+	type grammar struct {
+		Name   *string  `parser:"(@Ident ':')?"`
+		Values []string `parser:"@Int*"`
+	}
+	parser := mustTestParser[grammar](t)
+	out := &grammar{}
+	err := parser.ParseStringInto("", `a: 1 2 3`, out)
+	assert.NoError(t, err)
+	name := "a"
+	assert.Equal(t, &grammar{Name: &name, Values: []string{"1", "2", "3"}}, out)
+
+	// Fields are reset, and the capacity of slices is reused.
+	values := out.Values
+	err = parser.ParseBytesInto("", []byte(`4 5`), out)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Values: []string{"4", "5"}}, out)
+	assert.Equal(t, &values[0], &out.Values[0])
+
+	err = parser.ParseInto("", strings.NewReader(`b: c`), out)
+	assert.EqualError(t, err, `1:4: unexpected token "c"`)
+}
+
+func TestParseStringWithTokens(t *testing.T) {
+	type grammar struct {
+		Name  string `parser:"@Ident '='"`
+		Value int    `parser:"@Int"`
+	}
+	parser := mustTestParser[grammar](t, participle.Elide("Whitespace"), participle.Lexer(lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "Ident", Pattern: `[a-z]+`},
+		{Name: "Int", Pattern: `\d+`},
+		{Name: "Punct", Pattern: `=`},
+		{Name: "Whitespace", Pattern: `\s+`},
+	})))
+	symbols := parser.Lexer().Symbols()
+	actual, tokens, err := parser.ParseStringWithTokens("", `a = 1`)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Name: "a", Value: 1}, actual)
+	assert.Equal(t, []lexer.Token{
+		{Type: symbols["Ident"], Value: "a", Pos: lexer.Position{Offset: 0, Line: 1, Column: 1}},
+		{Type: symbols["Punct"], Value: "=", Pos: lexer.Position{Offset: 2, Line: 1, Column: 3}},
+		{Type: symbols["Int"], Value: "1", Pos: lexer.Position{Offset: 4, Line: 1, Column: 5}},
+	}, tokens)
+
+	// Tokens are returned when parsing fails.
+	_, tokens, err = parser.ParseStringWithTokens("", `a = b`)
+	assert.EqualError(t, err, `1:5: unexpected token "b" (expected <int>)`)
+	assert.Equal(t, 3, len(tokens))
+
+	// And when lexing fails, up to the error.
+	_, tokens, err = parser.ParseStringWithTokens("", `a = !`)
+	assert.EqualError(t, err, `1:5: invalid input text "!"`)
+	assert.Equal(t, 2, len(tokens))
+}
+
+func TestCaptureSignAndMagnitude(t *testing.T) {
+	type signed struct {
+		Negative  bool    `parser:"@'-'?"`
+		Magnitude float64 `parser:"@(Int | Float)"`
+	}
+	parser := mustTestParser[signed](t)
+	for input, expected := range map[string]*signed{
+		`10`:    {Magnitude: 10},
+		`-10`:   {Negative: true, Magnitude: 10},
+		`- 2.5`: {Negative: true, Magnitude: 2.5},
+	} {
+		actual, err := parser.ParseString("", input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, actual, input)
+	}
+
+	// The sign is not a number, so capturing it into a numeric field is an error, not a coercion.
+	type badSign struct {
+		Sign      int `parser:"@'-'?"`
+		Magnitude int `parser:"@Int"`
+	}
+	badParser := mustTestParser[badSign](t)
+	_, err := badParser.ParseString("", `-10`)
+	assert.EqualError(t, err, `badSign.Sign: strconv.ParseInt: parsing "-": invalid syntax`)
+
+	// Whereas captures into a single numeric field are concatenated before parsing.
+	type combined struct {
+		Value int `parser:"@('-'? Int)"`
+	}
+	combinedParser := mustTestParser[combined](t)
+	actual, err := combinedParser.ParseString("", `- 10`)
+	assert.NoError(t, err)
+	assert.Equal(t, &combined{Value: -10}, actual)
+}
+
+func TestBackreference(t *testing.T) {
+	type element struct {
+		Tag      string     `parser:"'<' @Ident '>'"`
+		Children []*element `parser:"@@*"`
+		Close    string     `parser:"'<' '/' @=Tag '>'"`
+	}
+	parser := mustTestParser[element](t)
+	assert.Equal(t, `Element = "<" <ident> ">" Element* "<" "/" =Tag ">" .`, parser.String())
+
+	actual, err := parser.ParseString("", `<a><b></b><c></c></a>`)
+	assert.NoError(t, err)
+	assert.Equal(t, &element{Tag: "a", Close: "a", Children: []*element{
+		{Tag: "b", Close: "b"},
+		{Tag: "c", Close: "c"},
+	}}, actual)
+
+	_, err = parser.ParseString("", `<a><b></a>`)
+	assert.EqualError(t, err, `1:9: mismatched Tag: expected "b" but got "a"`)
+
+	type invalid struct {
+		Tag   int    `parser:"@Int"`
+		Close string `parser:"@=Tag"`
+	}
+	_, err = participle.Build[invalid]()
+	assert.EqualError(t, err, `Close: backreference "Tag" must be to a string field of participle_test.invalid`)
+}
+
+func BenchmarkParseBytes(b *testing.B) {
+	type grammar struct {
+		Idents []string `@Ident*`
+	}
+	parser := participle.MustBuild[grammar](participle.Lexer(lexer.MustStateful(lexer.Rules{"Root": {
+		{Name: "Ident", Pattern: `\w+`},
+		{Name: "Whitespace", Pattern: `\s+`},
+	}}, lexer.ZeroCopy())), participle.Elide("Whitespace"))
+	source := []byte(strings.Repeat("hello world ", 1000))
+	b.Run("Reader", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := parser.Parse("", bytes.NewReader(source)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Bytes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := parser.ParseBytes("", source); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestParseTokens(t *testing.T) {
+	type grammar struct {
+		Keys   []string `(@Ident "="`
+		Values []int    ` @Int)*`
+	}
+	def := lexer.MustSimple([]lexer.SimpleRule{
+		{Name: "Int", Pattern: `\d+`},
+		{Name: "Ident", Pattern: `\w+`},
+		{Name: "Punct", Pattern: `=`},
+		{Name: "Whitespace", Pattern: `\s+`},
+	})
+	parser := participle.MustBuild[grammar](participle.Lexer(def), participle.Elide("Whitespace"), participle.UseLookahead(2))
+	lex, err := def.LexString("", "a = 1 b = 2")
+	assert.NoError(t, err)
+	tokens, err := lexer.ConsumeAll(lex)
+	assert.NoError(t, err)
+
+	actual, err := parser.ParseTokens("", tokens)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Keys: []string{"a", "b"}, Values: []int{1, 2}}, actual)
+
+	// EOF is added if missing, after the last token.
+	_, err = parser.ParseTokens("", tokens[:len(tokens)-4])
+	assert.EqualError(t, err, `1:9: unexpected token "<EOF>" (expected "=" <int>)`)
+}
+
+func TestParseFile(t *testing.T) {
+	type grammar struct {
+		Keys   []string `(@Ident "="`
+		Values []int    ` @Int)*`
+	}
+	parser := mustTestParser[grammar](t, participle.UseLookahead(2))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "input.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("a = 1\nb = c"), 0o600))
+
+	_, err := parser.ParseFile(path)
+	assert.EqualError(t, err, `input.txt:2:5: unexpected token "c" (expected <int>)`)
+	var perr participle.Error
+	assert.True(t, errors.As(err, &perr))
+
+	assert.NoError(t, os.WriteFile(path, []byte("a = 1\nb = 2"), 0o600))
+	actual, err := parser.ParseFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, &grammar{Keys: []string{"a", "b"}, Values: []int{1, 2}}, actual)
+
+	_, err = parser.ParseFile(filepath.Join(dir, "missing.txt"))
+	var pathErr *fs.PathError
+	assert.True(t, errors.As(err, &pathErr))
+	assert.False(t, errors.As(err, &perr))
+}
+
+func TestValidate(t *testing.T) {
+	type grammar struct {
+		Keys   []string `(@Ident "="`
+		Values []int    ` @Int ";")*`
+	}
+	parser := mustTestParser[grammar](t)
+	assert.NoError(t, parser.Validate("", `a = 1; b = 2;`))
+	assert.EqualError(t, parser.Validate("", `a = 1; b = ;`), `1:12: unexpected token ";" (expected <int> ";")`)
+
+	// Values are not assigned, so errors assigning them are not reported.
+	_, err := parser.ParseString("", `a = 99999999999999999999;`)
+	assert.Error(t, err)
+	assert.NoError(t, parser.Validate("", `a = 99999999999999999999;`))
+
+	// Backreferenced fields are still populated.
+	type element struct {
+		Tag      string     `parser:"'<' @Ident '>'"`
+		Children []*element `parser:"@@*"`
+		Close    string     `parser:"'<' '/' @=Tag '>'"`
+	}
+	elements := mustTestParser[element](t)
+	assert.NoError(t, elements.Validate("", `<a><b></b><c></c></a>`))
+	assert.EqualError(t, elements.Validate("", `<a><b></a>`), `1:9: mismatched Tag: expected "b" but got "a"`)
+
+	// AfterParse hooks are not called on the unpopulated structs.
+	hooked := mustTestParser[validateAfterParse](t)
+	_, err = hooked.ParseString("", `reserved`)
+	assert.EqualError(t, err, `1:1: reserved is a reserved word`)
+	assert.NoError(t, hooked.Validate("", `reserved`))
+}
+
+type validateAfterParse struct {
+	Name *string `@Ident`
+}
+
+func (v *validateAfterParse) AfterParse() error {
+	if *v.Name == "reserved" {
+		return errors.New("reserved is a reserved word")
+	}
+	return nil
+}
+
+func BenchmarkValidate(b *testing.B) {
+	type value struct {
+		Key   string  `@Ident "="`
+		Int   *int    `( @Int`
+		Float float64 `| @Float`
+		Str   string  `| @String ) ";"`
+	}
+	type grammar struct {
+		Values []*value `@@*`
+	}
+	parser := participle.MustBuild[grammar](participle.Unquote())
+	source := strings.Repeat(`a = 1; b = 2.5; c = "three";`+"\n", 1000)
+	b.Run("ParseString", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := parser.ParseString("", source); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Validate", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := parser.Validate("", source); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+type leftRecursiveTerm struct {
+	Value int `@Int`
+}
+
+type leftRecursiveExpr struct {
+	Pos   lexer.Position
+	Left  *leftRecursiveExpr `  @@`
+	Op    string             `  @("+" | "-")`
+	Right *leftRecursiveTerm `  @@`
+	Term  *leftRecursiveTerm `| @@`
+}
+
+func (e *leftRecursiveExpr) String() string {
+	if e.Term != nil {
+		return strconv.Itoa(e.Term.Value)
+	}
+	return fmt.Sprintf("(%s %s %d)", e.Left, e.Op, e.Right.Value)
+}
+
+func TestAllowLeftRecursion(t *testing.T) {
+	parser := mustTestParser[leftRecursiveExpr](t, participle.AllowLeftRecursion())
+	assert.Equal(t, `LeftRecursiveExpr = (LeftRecursiveExpr ("+" | "-") LeftRecursiveTerm) | LeftRecursiveTerm .
+LeftRecursiveTerm = <int> .`, parser.String())
+
+	for _, test := range []struct {
+		input    string
+		expected string
+	}{
+		{`1`, `1`},
+		{`1 - 2`, `(1 - 2)`},
+		{`1 - 2 + 3 - 4`, `(((1 - 2) + 3) - 4)`},
+	} {
+		actual, err := parser.ParseString("", test.input)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, actual.String())
+		assert.Equal(t, 1, actual.Pos.Column)
+	}
+
+	_, err := parser.ParseString("", `1 - 2 +`)
+	assert.EqualError(t, err, `1:8: unexpected token "<EOF>" (expected LeftRecursiveTerm)`)
+
+	// The target of ParseInto is not reused as an operand of itself.
+	actual := &leftRecursiveExpr{}
+	err = parser.ParseStringInto("", `1 + 2 + 3`, actual)
+	assert.NoError(t, err)
+	assert.Equal(t, `((1 + 2) + 3)`, actual.String())
+}
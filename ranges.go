@@ -0,0 +1,81 @@
+package participle
+
+import (
+	"reflect"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// NodeRange is the byte range of a node in the AST, as returned by Ranges.
+type NodeRange struct {
+	// Node is a pointer to the struct, if it is addressable, otherwise the struct itself.
+	Node any
+	// Start is the byte offset of the first token of the node.
+	Start int
+	// End is the byte offset immediately after the last token of the node.
+	End int
+}
+
+// Ranges returns the byte range of every node in the AST rooted at "node", in depth-first order.
+//
+// A node is any struct with "Pos" and "EndPos" fields. Unexported fields are not traversed.
+//
+// This is intended for editor integrations, eg. to determine which nodes an edit affects when
+// reparsing incrementally.
+func Ranges(node any) []NodeRange {
+	out := []NodeRange{}
+	collectRanges(reflect.ValueOf(node), &out)
+	return out
+}
+
+func collectRanges(v reflect.Value, out *[]NodeRange) {
+	switch v.Kind() { // nolint: exhaustive
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			collectRanges(v.Elem(), out)
+		}
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem() == tokenType {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			collectRanges(v.Index(i), out)
+		}
+
+	case reflect.Struct:
+		if start, end, ok := nodeRange(v); ok {
+			r := NodeRange{Node: v.Interface(), Start: start, End: end}
+			if v.CanAddr() {
+				r.Node = v.Addr().Interface()
+			}
+			*out = append(*out, r)
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				collectRanges(v.Field(i), out)
+			}
+		}
+	}
+}
+
+func nodeRange(v reflect.Value) (start, end int, ok bool) {
+	pos, ok := positionField(v, "Pos")
+	if !ok {
+		return 0, 0, false
+	}
+	// The end of a node can not be derived from its tokens, as their values may have been mapped.
+	endPos, ok := positionField(v, "EndPos")
+	if !ok {
+		return 0, 0, false
+	}
+	return pos.Offset, endPos.Offset, true
+}
+
+func positionField(v reflect.Value, name string) (lexer.Position, bool) {
+	field, ok := v.Type().FieldByName(name)
+	if !ok || field.PkgPath != "" || !field.Type.ConvertibleTo(positionType) {
+		return lexer.Position{}, false
+	}
+	return v.FieldByIndex(field.Index).Convert(positionType).Interface().(lexer.Position), true
+}
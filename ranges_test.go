@@ -0,0 +1,68 @@
+package participle_test
+
+import (
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+type rangesValue struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Number int `@Int`
+}
+
+type rangesEntry struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Key   string       `@Ident "="`
+	Value *rangesValue `@@`
+}
+
+type rangesConfig struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+
+	Entries []*rangesEntry `@@*`
+}
+
+func TestRanges(t *testing.T) {
+	p := mustTestParser[rangesConfig](t)
+	ast, err := p.ParseString("", `a = 1  bb = 22`)
+	require.NoError(t, err)
+	expected := []participle.NodeRange{
+		{Node: ast, Start: 0, End: 14},
+		{Node: ast.Entries[0], Start: 0, End: 5},
+		{Node: ast.Entries[0].Value, Start: 4, End: 5},
+		{Node: ast.Entries[1], Start: 7, End: 14},
+		{Node: ast.Entries[1].Value, Start: 12, End: 14},
+	}
+	require.Equal(t, expected, participle.Ranges(ast))
+
+	require.Equal(t, []participle.NodeRange{}, participle.Ranges((*rangesConfig)(nil)))
+
+	// Ranges span the input, not the values captured from it.
+	type quoted struct {
+		Pos    lexer.Position
+		EndPos lexer.Position
+		Value  string `@String`
+	}
+	q, err := mustTestParser[quoted](t, participle.Unquote()).ParseString("", `"a" `)
+	require.NoError(t, err)
+	require.Equal(t, []participle.NodeRange{{Node: q, Start: 0, End: 3}}, participle.Ranges(q))
+
+	// Nodes without an EndPos field have no range.
+	type tokens struct {
+		Pos    lexer.Position
+		Tokens []lexer.Token
+		Value  string `@String`
+	}
+	tq, err := mustTestParser[tokens](t, participle.Unquote()).ParseString("", `"a"`)
+	require.NoError(t, err)
+	require.Equal(t, []participle.NodeRange{}, participle.Ranges(tq))
+}
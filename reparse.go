@@ -0,0 +1,339 @@
+package participle
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Edit describes the replacement of the bytes [Start, End) of an input with Text, eg. a change
+// made in an editor.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Reparse parses the input resulting from applying "edit" to a previously parsed input, reusing as
+// much of the previous parse as possible. It returns the new AST and tokens, which may be passed to
+// a subsequent Reparse.
+//
+// "prevTokens" must be the tokens of the previous input, as returned by Lex() (and parsed by
+// ParseTokens()) or by Reparse(). The previous input is reconstructed from their values, so they must
+// cover it contiguously. A lexer that discards input, such as the default text/scanner based lexer,
+// or a Map() that modifies token values, is not supported. With a stateful or simple lexer, give
+// rules for whitespace and comments upper case names, so that they are not discarded, and Elide()
+// them instead.
+//
+// Lexing restarts at the first token of the line containing the start of the edit, and stops once
+// the new tokens resynchronise with "prevTokens" after the edit, whose positions are then adjusted.
+// This assumes that the tokens following a token boundary depend only on the remaining input, which
+// may not hold for a stateful lexer.
+//
+// If the root of the grammar is a struct consisting solely of a repeated struct production, such
+// as `Entries []*Entry "@@*"`, only the top-level elements overlapping the changed tokens, and the
+// element preceding them, are reparsed. This requires the elements to have "Pos" and "EndPos"
+// fields (see Ranges()). The remaining elements of "prev" are reused, with their
+// positions adjusted, so "prev" must not be used after Reparse. Otherwise, or if reparsing the
+// elements fails, the new tokens are parsed in full, as by ParseTokens().
+func (p *Parser[G]) Reparse(prev *G, prevTokens []lexer.Token, edit Edit) (*G, []lexer.Token, error) {
+	source, err := tokensSource(prevTokens)
+	if err != nil {
+		return nil, nil, err
+	}
+	if edit.Start < 0 || edit.Start > edit.End || edit.End > len(source) {
+		return nil, nil, fmt.Errorf("edit [%d:%d] is outside the input of length %d", edit.Start, edit.End, len(source))
+	}
+	text := source[:edit.Start] + edit.Text + source[edit.End:]
+	relexed, err := p.relex(prevTokens, text, edit)
+	if err != nil {
+		return nil, nil, err
+	}
+	if v, ok := p.reparseElements(prev, prevTokens, text, relexed); ok {
+		return v, relexed.tokens, nil
+	}
+	v, err := p.ParseTokens(prevTokens[0].Pos.Filename, relexed.tokens, p.withSource(text, nil)...)
+	return v, relexed.tokens, err
+}
+
+// Reconstruct the input from tokens covering it contiguously.
+func tokensSource(tokens []lexer.Token) (string, error) {
+	if len(tokens) == 0 || !tokens[len(tokens)-1].EOF() {
+		return "", fmt.Errorf("tokens must end with EOF, as returned by Lex()")
+	}
+	w := strings.Builder{}
+	for _, t := range tokens {
+		if t.Pos.Offset != w.Len() {
+			return "", Errorf(t.Pos, "tokens must cover the input contiguously, but there is a gap before %q", t.Value)
+		}
+		w.WriteString(t.Value)
+	}
+	return w.String(), nil
+}
+
+// The tokens of an edited input.
+type relexedTokens struct {
+	tokens []lexer.Token
+	// The previous tokens [start, end) were replaced. Those from "end" onwards were reused, with
+	// their positions adjusted by shift(), and their offsets by delta.
+	start, end int
+	delta      int
+	shift      func(lexer.Position) lexer.Position
+}
+
+// Lex the edited input "text", reusing the previous tokens outside the lines touched by the edit.
+func (p *Parser[G]) relex(prev []lexer.Token, text string, edit Edit) (*relexedTokens, error) {
+	filename := prev[0].Pos.Filename
+	lineStart := strings.LastIndexByte(text[:edit.Start], '\n') + 1
+	start := sort.Search(len(prev), func(i int) bool { return prev[i].Pos.Offset+len(prev[i].Value) >= lineStart })
+	from := prev[start].Pos
+	var (
+		lex lexer.Lexer
+		err error
+	)
+	// Positions can only be made relative to the start of the re-lexed input if they are not mapped.
+	if p.positionMapper == nil {
+		if sl, ok := p.lex.(lexer.StringDefinition); ok {
+			lex, err = sl.LexString(filename, text[from.Offset:])
+		} else {
+			lex, err = p.lex.Lex(filename, strings.NewReader(text[from.Offset:]))
+		}
+	}
+	if lex == nil || err != nil {
+		return p.lexAll(prev, text)
+	}
+	delta := len(edit.Text) - (edit.End - edit.Start)
+	editEnd := edit.Start + len(edit.Text)
+	end := sort.Search(len(prev), func(i int) bool { return prev[i].Pos.Offset >= edit.End })
+	tokens := append([]lexer.Token{}, prev[:start]...)
+	for {
+		t, err := lex.Next()
+		if err != nil {
+			// Report the error at its position in the whole input.
+			return p.lexAll(prev, text)
+		}
+		t.Pos = relativePosition(from, t.Pos)
+		if !t.EOF() && t.Pos.Offset >= editEnd {
+			for end < len(prev)-1 && prev[end].Pos.Offset+delta < t.Pos.Offset {
+				end++
+			}
+			if old := prev[end]; !old.EOF() && old.Pos.Offset+delta == t.Pos.Offset && old.Type == t.Type && old.Value == t.Value {
+				shift := shiftPosition(old.Pos, t.Pos)
+				for _, t := range prev[end:] {
+					t.Pos = shift(t.Pos)
+					tokens = append(tokens, t)
+				}
+				return &relexedTokens{tokens: tokens, start: start, end: end, delta: delta, shift: shift}, nil
+			}
+		}
+		tokens = append(tokens, t)
+		if t.EOF() {
+			return &relexedTokens{tokens: tokens, start: start, end: len(prev)}, nil
+		}
+	}
+}
+
+func (p *Parser[G]) lexAll(prev []lexer.Token, text string) (*relexedTokens, error) {
+	tokens, err := p.Lex(prev[0].Pos.Filename, strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	return &relexedTokens{tokens: tokens, start: 0, end: len(prev)}, nil
+}
+
+// Translate a position within input lexed from "from" into a position within the whole input.
+func relativePosition(from, pos lexer.Position) lexer.Position {
+	pos.Filename = from.Filename
+	pos.Offset += from.Offset
+	if pos.Line == 1 {
+		pos.Column += from.Column - 1
+	}
+	pos.Line += from.Line - 1
+	return pos
+}
+
+// Returns a function moving positions at or after "from" by the same amount as "from" moves to "to".
+func shiftPosition(from, to lexer.Position) func(lexer.Position) lexer.Position {
+	return func(pos lexer.Position) lexer.Position {
+		if pos.Offset < from.Offset {
+			return pos
+		}
+		if pos.Line == from.Line {
+			pos.Column += to.Column - from.Column
+		}
+		pos.Line += to.Line - from.Line
+		pos.Offset += to.Offset - from.Offset
+		return pos
+	}
+}
+
+// Reparse the top-level elements of "prev" overlapping the tokens changed by an edit, reusing the
+// remaining elements. Returns false if the grammar or AST does not support this.
+func (p *Parser[G]) reparseElements(prev *G, prevTokens []lexer.Token, text string, relexed *relexedTokens) (*G, bool) {
+	if prev == nil || len(p.interleave) > 0 || p.positionMapper != nil {
+		return nil, false
+	}
+	field, elem := repeatedElements(p.typeNodes[p.rootType])
+	if elem == nil {
+		return nil, false
+	}
+	switch any(prev).(type) {
+	case BeforeParse, AfterParse, Parseable:
+		return nil, false
+	}
+	prevElements := reflect.ValueOf(prev).Elem().FieldByIndex(field.Index)
+	n := prevElements.Len()
+	starts, ends := make([]int, n), make([]int, n)
+	for i := 0; i < n; i++ {
+		var ok bool
+		if starts[i], ends[i], ok = nodeRange(reflect.Indirect(prevElements.Index(i))); !ok {
+			return nil, false
+		}
+	}
+	changedStart := prevTokens[relexed.start].Pos.Offset
+	changedEnd := math.MaxInt
+	if relexed.end < len(prevTokens) {
+		changedEnd = prevTokens[relexed.end].Pos.Offset
+	}
+	// Elements before "first" are unchanged, and those from "reuse" onwards are only moved. The
+	// element preceding the first changed one is also reparsed, as the change may extend it.
+	first := sort.Search(n, func(i int) bool { return ends[i] > changedStart })
+	if first > 0 {
+		first--
+	}
+	reuse := sort.Search(n, func(i int) bool { return starts[i] >= changedEnd })
+	resume := 0
+	if first > 0 {
+		resume = sort.Search(len(relexed.tokens), func(i int) bool { return relexed.tokens[i].Pos.Offset >= ends[first-1] })
+	}
+	peeker, err := lexer.Upgrade(lexer.FromTokens(prevTokens[0].Pos.Filename, relexed.tokens[resume:]), p.getElidedTypes()...)
+	if err != nil {
+		return nil, false
+	}
+	ctx := newParseContext(peeker, p.useLookahead, p.caseInsensitiveTokens, p.literalMatchTokens)
+	p.configure(&ctx)
+	for _, option := range p.withSource(text, nil) {
+		option(&ctx)
+	}
+	out := new(G)
+	*out = *prev
+	parent := reflect.ValueOf(out).Elem()
+	elements := reflect.AppendSlice(reflect.MakeSlice(prevElements.Type(), 0, n), prevElements.Slice(0, first))
+	for !ctx.Peek().EOF() {
+		next := ctx.Peek().Pos.Offset
+		for reuse < n && starts[reuse]+relexed.delta < next {
+			reuse++
+		}
+		if reuse < n && starts[reuse]+relexed.delta == next {
+			for i := reuse; i < n; i++ {
+				shiftPositions(prevElements.Index(i), relexed.shift)
+			}
+			elements = reflect.AppendSlice(elements, prevElements.Slice(reuse, n))
+			break
+		}
+		cursor := ctx.Cursor()
+		v, err := elem.Parse(&ctx, parent)
+		if err != nil || v == nil || ctx.Cursor() == cursor || ctx.limitError() != nil {
+			return nil, false
+		}
+		elements = reflect.Append(elements, maybeRef(prevElements.Type().Elem(), v[0]))
+	}
+	if elements.Len() == 0 {
+		return nil, false
+	}
+	parent.FieldByIndex(field.Index).Set(elements)
+	return out, true
+}
+
+// If the root production consists solely of a repeated capture of a struct production, returns the
+// field captured into and the struct.
+func repeatedElements(root node) (structLexerField, *strct) {
+	s, ok := root.(*strct)
+	if !ok || s.posFieldIndex != nil || s.endPosFieldIndex != nil || s.tokensFieldIndex != nil ||
+		s.triviaFieldIndex != nil || s.sourceFieldIndex != nil {
+		return structLexerField{}, nil
+	}
+	expr := s.expr
+	if seq, ok := expr.(*sequence); ok && seq.next == nil {
+		expr = seq.node
+	}
+	g, ok := expr.(*group)
+	if !ok || (g.mode != groupMatchZeroOrMore && g.mode != groupMatchOneOrMore) || g.sep != nil {
+		return structLexerField{}, nil
+	}
+	c, ok := g.expr.(*capture)
+	if !ok || c.skip || c.raw != nil || c.transforms != nil || c.registry != nil || c.field.Type.Kind() != reflect.Slice {
+		return structLexerField{}, nil
+	}
+	elem, ok := c.node.(*strct)
+	if !ok {
+		return structLexerField{}, nil
+	}
+	return c.field, elem
+}
+
+// Adjust every position in the AST rooted at "v", including those of tokens.
+func shiftPositions(v reflect.Value, shift func(lexer.Position) lexer.Position) {
+	switch v.Kind() { // nolint: exhaustive
+	case reflect.Ptr:
+		if !v.IsNil() {
+			shiftPositions(v.Elem(), shift)
+		}
+
+	case reflect.Interface:
+		if v.IsNil() || !v.CanSet() {
+			return
+		}
+		// Values held in interfaces are not addressable, so are adjusted in a copy.
+		e := reflect.New(v.Elem().Type()).Elem()
+		e.Set(v.Elem())
+		shiftPositions(e, shift)
+		v.Set(e)
+
+	case reflect.Slice:
+		if v.Type() == tokensType && v.CanSet() {
+			// Slices of tokens may share backing arrays, so are copied rather than adjusted in place.
+			tokens := append([]lexer.Token{}, v.Interface().([]lexer.Token)...)
+			for i := range tokens {
+				tokens[i].Pos = shift(tokens[i].Pos)
+			}
+			v.Set(reflect.ValueOf(tokens))
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			shiftPositions(v.Index(i), shift)
+		}
+
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			shiftPositions(v.Index(i), shift)
+		}
+
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(iter.Value())
+			shiftPositions(e, shift)
+			v.SetMapIndex(iter.Key(), e)
+		}
+
+	case reflect.Struct:
+		if v.Type().ConvertibleTo(positionType) {
+			if v.CanSet() {
+				pos := shift(v.Convert(positionType).Interface().(lexer.Position))
+				v.Set(reflect.ValueOf(pos).Convert(v.Type()))
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				shiftPositions(v.Field(i), shift)
+			}
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package participle_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+type reparseValue struct {
+	Pos    lexer.Position
+	Number *int    `  @Int`
+	Ident  *string `| @Ident`
+}
+
+type reparseEntry struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+	Key    string          `(   @Ident`
+	Args   []string        `    ( "(" @Ident* ")" )?`
+	Values []*reparseValue `    ( "=" @@ ( "," @@ )* ";" )?`
+	Group  []string        `  | "(" @Ident* ")" )`
+}
+
+type reparseFile struct {
+	Entries []*reparseEntry `@@*`
+}
+
+var reparseLexer = lexer.MustSimple([]lexer.SimpleRule{
+	{"Int", `\d+`},
+	{"Ident", `\w+`},
+	{"Comment", `/\*[^*]*\*/`},
+	{"Punct", `[=,;()]`},
+	{"Whitespace", `\s+`},
+})
+
+func TestReparse(t *testing.T) {
+	p := mustTestParser[reparseFile](t, participle.Lexer(reparseLexer), participle.Elide("Comment", "Whitespace"))
+	source := "a = 1, 2;\nb = x;\n\nc = 3;\nd = 4, y;\n"
+	tests := []struct {
+		name   string
+		source string // Defaults to "source".
+		edit   participle.Edit
+	}{
+		{"ChangeValue", "", participle.Edit{Start: 14, End: 15, Text: "42"}},
+		{"InsertEntry", "", participle.Edit{Start: 17, End: 17, Text: "z = 5;\n"}},
+		{"DeleteLine", "", participle.Edit{Start: 10, End: 17}},
+		{"JoinLines", "", participle.Edit{Start: 16, End: 17, Text: " "}},
+		{"SplitEntry", "", participle.Edit{Start: 6, End: 6, Text: "\n"}},
+		{"ExtendIdent", "", participle.Edit{Start: 1, End: 1, Text: "bc"}},
+		{"Comment", "", participle.Edit{Start: 17, End: 17, Text: "/* c */ "}},
+		{"UnterminatedComment", "", participle.Edit{Start: 17, End: 17, Text: "/* "}},
+		{"SyntaxError", "", participle.Edit{Start: 12, End: 13, Text: ""}},
+		{"AppendAtEnd", "", participle.Edit{Start: 35, End: 35, Text: "e = 6;"}},
+		{"ReplaceAll", "", participle.Edit{Start: 0, End: 35, Text: "f = 7;"}},
+		{"ExtendPreviousEntry", "a\nb\n", participle.Edit{Start: 2, End: 2, Text: "(x)"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			source := source
+			if test.source != "" {
+				source = test.source
+			}
+			tokens, err := p.Lex("", strings.NewReader(source))
+			assert.NoError(t, err)
+			prev, err := p.ParseTokens("", tokens)
+			assert.NoError(t, err)
+
+			edited := source[:test.edit.Start] + test.edit.Text + source[test.edit.End:]
+			expectedTokens, expectedErr := p.Lex("", strings.NewReader(edited))
+			actual, actualTokens, err := p.Reparse(prev, tokens, test.edit)
+			if expectedErr != nil {
+				assert.EqualError(t, err, expectedErr.Error())
+				return
+			}
+			expected, expectedErr := p.ParseTokens("", expectedTokens)
+			assert.Equal(t, expectedTokens, actualTokens)
+			if expectedErr != nil {
+				assert.EqualError(t, err, expectedErr.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestReparseReusesUnchangedEntries(t *testing.T) {
+	p := mustTestParser[reparseFile](t, participle.Lexer(reparseLexer), participle.Elide("Comment", "Whitespace"))
+	source := "a = 1;\nb = 2;\nc = 3;\nd = 4;\n"
+	tokens, err := p.Lex("", strings.NewReader(source))
+	assert.NoError(t, err)
+	prev, err := p.ParseTokens("", tokens)
+	assert.NoError(t, err)
+	a, d := prev.Entries[0], prev.Entries[3]
+
+	// Entry "b" precedes the edited entry, so is reparsed in case the edit extends it.
+	actual, tokens, err := p.Reparse(prev, tokens, participle.Edit{Start: 18, End: 19, Text: "30\n\n"})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(actual.Entries))
+	assert.True(t, a == actual.Entries[0])
+	assert.True(t, d == actual.Entries[3])
+	assert.Equal(t, lexer.Position{Offset: 24, Line: 6, Column: 1}, d.Pos)
+	assert.Equal(t, lexer.Position{Offset: 28, Line: 6, Column: 5}, d.Values[0].Pos)
+
+	// The returned tokens can be used for a subsequent edit.
+	actual, _, err = p.Reparse(actual, tokens, participle.Edit{Start: 0, End: 1, Text: "x"})
+	assert.NoError(t, err)
+	assert.Equal(t, "x", actual.Entries[0].Key)
+	assert.True(t, d == actual.Entries[3])
+}
+
+func TestReparseRequiresContiguousTokens(t *testing.T) {
+	// Lowercase rules are discarded by the lexer, rather than elided by the parser.
+	lex := lexer.MustSimple([]lexer.SimpleRule{
+		{"Int", `\d+`},
+		{"Ident", `\w+`},
+		{"Punct", `[=,;]`},
+		{"whitespace", `\s+`},
+	})
+	p := mustTestParser[reparseFile](t, participle.Lexer(lex))
+	tokens, err := p.Lex("", strings.NewReader("a = 1;"))
+	assert.NoError(t, err)
+	prev, err := p.ParseTokens("", tokens)
+	assert.NoError(t, err)
+	_, _, err = p.Reparse(prev, tokens, participle.Edit{Start: 4, End: 5, Text: "2"})
+	assert.EqualError(t, err, `1:3: tokens must cover the input contiguously, but there is a gap before "="`)
+}
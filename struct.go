@@ -19,8 +19,8 @@ type structLexer struct {
 	lexer   *lexer.PeekingLexer
 }
 
-func lexStruct(s reflect.Type) (*structLexer, error) {
-	indexes, err := collectFieldIndexes(s)
+func lexStruct(s reflect.Type, allowUnexported bool) (*structLexer, error) {
+	indexes, err := collectFieldIndexes(s, allowUnexported)
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +46,18 @@ func (s *structLexer) NumField() int {
 type structLexerField struct {
 	reflect.StructField
 	Index []int
+	// Exclusive is set for scalar fields that are the target of an `into:"<field>"` tag, which may
+	// only be captured by one of their capture sites.
+	Exclusive bool
+	// Site is the index of the field whose tag contains the capture, with Exclusive.
+	Site []int
+	// Flags is set for fields of a type registered with Flags(), which combine all their captures.
+	Flags bool
+	// DuplicateKeys is set for map fields with AllowDuplicateMapKeys(), where later values replace
+	// earlier values with the same key.
+	DuplicateKeys bool
+	// Strict is set for string fields with StrictStringCapture(), which may only capture one token.
+	Strict bool
 }
 
 // Field returns the field associated with the current token.
@@ -115,8 +127,39 @@ func fieldLexerTag(field reflect.StructField) string {
 	return string(field.Tag)
 }
 
+// Fields tagged with `depth:"true"` are populated with the nesting depth of their struct, rather
+// than captured by the grammar.
+func depthField(field reflect.StructField) bool {
+	_, ok := field.Tag.Lookup("parser")
+	return !ok && field.Tag.Get("depth") == "true"
+}
+
+// Fields tagged with `alternative:"true"` are populated with the index of the matched alternative of
+// their struct's top-level disjunction, rather than captured by the grammar.
+func alternativeField(field reflect.StructField) bool {
+	_, ok := field.Tag.Lookup("parser")
+	return !ok && field.Tag.Get("alternative") == "true"
+}
+
+// Fields tagged with `source:"true"` are populated with the source text their struct was parsed from
+// when the CaptureSource() option is used, rather than captured by the grammar.
+func sourceField(field reflect.StructField) bool {
+	_, ok := field.Tag.Lookup("parser")
+	return !ok && field.Tag.Get("source") == "true"
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	switch kind { // nolint: exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
 // Recursively collect flattened indices for top-level fields and embedded fields.
-func collectFieldIndexes(s reflect.Type) (out [][]int, err error) {
+//
+// Unexported fields are only collected if "allowUnexported" is true.
+func collectFieldIndexes(s reflect.Type, allowUnexported bool) (out [][]int, err error) {
 	if s.Kind() != reflect.Struct {
 		return nil, fmt.Errorf("expected a struct but got %q", s)
 	}
@@ -125,7 +168,7 @@ func collectFieldIndexes(s reflect.Type) (out [][]int, err error) {
 		f := s.Field(i)
 		switch {
 		case f.Anonymous && f.Type.Kind() == reflect.Struct: // Embedded struct.
-			children, err := collectFieldIndexes(f.Type)
+			children, err := collectFieldIndexes(f.Type, allowUnexported)
 			if err != nil {
 				return nil, err
 			}
@@ -133,9 +176,29 @@ func collectFieldIndexes(s reflect.Type) (out [][]int, err error) {
 				out = append(out, append(f.Index, idx...))
 			}
 
-		case f.PkgPath != "":
+		case f.Name == "_": // Blank fields only carry metadata, eg. doc tags, unless they capture into a sibling.
+			if f.Tag.Get("into") != "" {
+				out = append(out, f.Index)
+			}
+
+		case f.PkgPath != "" && !allowUnexported:
 			continue
 
+		case depthField(f):
+			if f.Type.Kind() != reflect.Int {
+				return nil, fmt.Errorf("%s: depth tag requires an int field, not %s", f.Name, f.Type)
+			}
+
+		case alternativeField(f):
+			if !isIntKind(f.Type.Kind()) {
+				return nil, fmt.Errorf("%s: alternative tag requires an integer field, not %s", f.Name, f.Type)
+			}
+
+		case sourceField(f):
+			if f.Type.Kind() != reflect.String {
+				return nil, fmt.Errorf("%s: source tag requires a string field, not %s", f.Name, f.Type)
+			}
+
 		case fieldLexerTag(f) != "":
 			out = append(out, f.Index)
 		}
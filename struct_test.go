@@ -15,7 +15,7 @@ func TestStructLexerTokens(t *testing.T) {
 		B string `34`
 	}
 
-	scan, err := lexStruct(reflect.TypeOf(testScanner{}))
+	scan, err := lexStruct(reflect.TypeOf(testScanner{}), false)
 	require.NoError(t, err)
 	t12 := lexer.Token{Type: scanner.Int, Value: "12", Pos: lexer.Position{Filename: "testScanner", Line: 1, Column: 1}}
 	t34 := lexer.Token{Type: scanner.Int, Value: "34", Pos: lexer.Position{Filename: "B", Line: 2, Column: 1}}
@@ -38,7 +38,7 @@ func TestStructLexer(t *testing.T) {
 	}{}
 
 	gt := reflect.TypeOf(g)
-	r, err := lexStruct(gt)
+	r, err := lexStruct(gt, false)
 	require.NoError(t, err)
 	f := []structLexerField{}
 	s := ""
@@ -70,7 +70,7 @@ func TestCollectFieldIndexes(t *testing.T) {
 		C string `@String`
 	}
 	typ := reflect.TypeOf(grammar)
-	indexes, err := collectFieldIndexes(typ)
+	indexes, err := collectFieldIndexes(typ, false)
 	require.NoError(t, err)
 	require.Equal(t, [][]int{{0, 0}, {0, 1}, {1}}, indexes)
 }
@@ -0,0 +1,138 @@
+package participle
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Suggest the expected literal closest to the unexpected token of each error, for "did you mean" hints.
+func suggestAlternatives(err error, expected *expectedLiterals) {
+	if errs, ok := err.(Errors); ok { // nolint: errorlint
+		for _, err := range errs {
+			suggestAlternatives(err, expected)
+		}
+		return
+	}
+	var uerr *UnexpectedTokenError
+	if !errors.As(err, &uerr) || uerr.Unexpected.EOF() || uerr.Unexpected.Pos.Offset != expected.offset {
+		return
+	}
+	uerr.Suggestion = closestLiteral(uerr.Unexpected.Value, expected.literals)
+}
+
+// Returns the literal closest to "value" by edit distance, or "" if none is close enough to be a
+// plausible typo.
+func closestLiteral(value string, literals []string) string {
+	best, bestDistance := "", 0
+	for _, literal := range literals {
+		distance := editDistance(strings.ToLower(value), strings.ToLower(literal))
+		// Allow roughly one edit per three characters, so that short literals are not suggested for
+		// unrelated tokens.
+		if distance == 0 || distance > (len([]rune(literal))+2)/3 {
+			continue
+		}
+		if best == "" || distance < bestDistance {
+			best, bestDistance = literal, distance
+		}
+	}
+	return best
+}
+
+// Replace the expectation of an unexpected token error with every terminal attempted at the furthest
+// token reached, moving the error to that token if it is further than the error.
+//
+// Each of multiple errors is only replaced if it is at the furthest token, as the errors preceding
+// it were followed by other attempts.
+func collectExpected(err error, expected *expectedLiterals) {
+	if errs, ok := err.(Errors); ok { // nolint: errorlint
+		for _, err := range errs {
+			var uerr *UnexpectedTokenError
+			if errors.As(err, &uerr) && uerr.Unexpected.Pos.Offset == expected.offset {
+				collectExpected(err, expected)
+			}
+		}
+		return
+	}
+	var uerr *UnexpectedTokenError
+	if !errors.As(err, &uerr) || uerr.Unexpected.Pos.Offset > expected.offset || len(expected.nodes) == 0 {
+		return
+	}
+	uerr.Unexpected = expected.token
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, n := range expected.nodes {
+		if term := n.String(); !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	uerr.expectNode = nil
+	if len(terms) == 1 {
+		uerr.Expect = terms[0]
+	} else {
+		uerr.Expect = "one of " + strings.Join(terms, ", ")
+	}
+}
+
+// The terminals attempted at the furthest token reached by a parse, shared by all branches.
+type expectedLiterals struct {
+	offset    int
+	token     lexer.Token
+	literals  []string // For SuggestAlternatives(), excluding those attempted at EOF.
+	seen      map[string]bool
+	nodes     []node // For CollectExpected().
+	seenNodes map[node]bool
+}
+
+// Record that terminal n, matching any of "literals", was attempted at token.
+func (e *expectedLiterals) add(token *lexer.Token, n node, literals ...string) {
+	if token.Pos.Offset < e.offset {
+		return
+	}
+	if token.Pos.Offset > e.offset || e.seen == nil {
+		e.offset, e.token, e.literals, e.seen = token.Pos.Offset, *token, nil, map[string]bool{}
+		e.nodes, e.seenNodes = nil, map[node]bool{}
+	}
+	if !e.seenNodes[n] {
+		e.seenNodes[n] = true
+		e.nodes = append(e.nodes, n)
+	}
+	if token.EOF() {
+		return
+	}
+	for _, literal := range literals {
+		if literal != "" && !e.seen[literal] {
+			e.seen[literal] = true
+			e.literals = append(e.literals, literal)
+		}
+	}
+}
+
+// The Levenshtein distance between a and b, in runes.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			curr[j] = prev[j-1]
+			if ar[i-1] != br[j-1] {
+				curr[j]++
+			}
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(br)]
+}
@@ -0,0 +1,62 @@
+package participle_test
+
+import (
+	"errors"
+	"testing"
+
+	require "github.com/alecthomas/assert/v2"
+
+	"github.com/alecthomas/participle/v2"
+)
+
+func TestSuggestAlternatives(t *testing.T) {
+	type statement struct {
+		Select string `  "select" @Ident`
+		Insert string `| "insert" @Ident`
+		Delete string `| "delete" @Ident`
+	}
+	type grammar struct {
+		Statements []*statement `(@@ ";")*`
+	}
+	parser := mustTestParser[grammar](t, participle.SuggestAlternatives())
+
+	_, err := parser.ParseString("", `select a; slect b;`)
+	require.EqualError(t, err, `1:11: unexpected token "slect", did you mean "select"?`)
+	uerr := &participle.UnexpectedTokenError{}
+	require.True(t, errors.As(err, &uerr))
+	require.Equal(t, "select", uerr.Suggestion)
+
+	// Tokens not resembling any expected literal have no suggestion.
+	_, err = parser.ParseString("", `update b;`)
+	require.EqualError(t, err, `1:1: unexpected token "update"`)
+
+	// Suggestions are opt-in.
+	parser = mustTestParser[grammar](t)
+	_, err = parser.ParseString("", `select a; slect b;`)
+	require.EqualError(t, err, `1:11: unexpected token "slect"`)
+}
+
+func TestCollectExpected(t *testing.T) {
+	type statement struct {
+		Add    bool   `  @"add"`
+		Remove bool   `| @"remove"`
+		Name   string `| "name" @Ident`
+	}
+	type grammar struct {
+		Statements []*statement `"{" ( @@ )* "}"`
+	}
+	parser := mustTestParser[grammar](t, participle.CollectExpected())
+	_, err := parser.ParseString("", `{ add foo }`)
+	require.EqualError(t, err, `1:7: unexpected token "foo" (expected one of "add", "remove", "name", "}")`)
+
+	_, err = parser.ParseString("", `{ name }`)
+	require.EqualError(t, err, `1:8: unexpected token "}" (expected <ident>)`)
+
+	_, err = parser.ParseString("", `{ add`)
+	require.EqualError(t, err, `1:6: unexpected token "<EOF>" (expected one of "add", "remove", "name", "}")`)
+
+	// Suggestions can be combined with collected expectations.
+	parser = mustTestParser[grammar](t, participle.CollectExpected(), participle.SuggestAlternatives())
+	_, err = parser.ParseString("", `{ remve }`)
+	require.EqualError(t, err, `1:3: unexpected token "remve" (expected one of "add", "remove", "name", "}"), did you mean "remove"?`)
+}
@@ -8,26 +8,105 @@ import (
 // Perform some post-construction validation. This currently does:
 //
 // Checks for left recursion.
+// Checks for repetitions of expressions that can match empty input.
 func validate(n node) error {
 	checked := map[*strct]bool{}
 	seen := map[node]bool{}
+	nullable := nullableChecker{memo: map[node]bool{}}
+	var production *strct
 
 	return visit(n, func(n node, next func() error) error {
-		if n, ok := n.(*strct); ok {
-			if !checked[n] && isLeftRecursive(n) {
+		switch n := n.(type) {
+		case *strct:
+			if !checked[n] && !n.leftRecursive && isLeftRecursive(n, false) {
 				return fmt.Errorf("left recursion detected on\n\n%s", indent(n.String()))
 			}
 			checked[n] = true
 			if seen[n] {
 				return nil
 			}
+			parent := production
+			production = n
+			defer func() { production = parent }()
+
+		case *group:
+			if isRepetition(n) && (n.sep == nil || nullable.check(n.sep)) && nullable.check(n.expr) {
+				return fmt.Errorf("%s: repetition of %s can match empty input, so would never terminate",
+					production.typ.Name(), n.expr)
+			}
 		}
 		seen[n] = true
 		return next()
 	})
 }
 
-func isLeftRecursive(root *strct) (found bool) {
+func isRepetition(g *group) bool {
+	return g.mode == groupMatchZeroOrMore || g.mode == groupMatchOneOrMore || g.mode == groupMatchAtLeast
+}
+
+// Determines whether nodes can successfully match without consuming any input.
+type nullableChecker struct {
+	memo map[node]bool
+}
+
+func (c *nullableChecker) check(n node) bool {
+	if nullable, ok := c.memo[n]; ok {
+		return nullable
+	}
+	// Recursive productions are assumed not to be nullable until proven otherwise.
+	c.memo[n] = false
+	nullable := c.compute(n)
+	c.memo[n] = nullable
+	return nullable
+}
+
+func (c *nullableChecker) compute(n node) bool {
+	switch n := n.(type) {
+	case *strct:
+		return c.check(n.expr)
+	case *disjunction:
+		for _, child := range n.nodes {
+			if c.check(child) {
+				return true
+			}
+		}
+		return false
+	case *union:
+		for _, member := range n.disjunction.nodes {
+			if c.check(member) {
+				return true
+			}
+		}
+		return false
+	case *sequence:
+		for ; n != nil; n = n.next {
+			if !c.check(n.node) {
+				return false
+			}
+		}
+		return true
+	case *capture:
+		return c.check(n.node)
+	case *group:
+		switch n.mode {
+		case groupMatchZeroOrOne, groupMatchZeroOrMore, groupMatchUnique:
+			return true
+		case groupMatchNonEmpty:
+			return false
+		default:
+			return c.check(n.expr)
+		}
+	default:
+		// Literals, references, negations, and custom parsers consume input. Lookaround groups and
+		// predicates typically guard input that is then consumed (eg. `(?= Field) @@?`), so are also
+		// treated as non-nullable to avoid rejecting valid grammars.
+		return false
+	}
+}
+
+// Reports whether root refers to itself before consuming input, either directly or, unless "direct"
+// is set, through other productions.
+func isLeftRecursive(root *strct, direct bool) (found bool) {
 	defer func() { _ = recover() }()
 	seen := map[node]bool{}
 	_ = visit(root.expr, func(n node, next func() error) error {
@@ -38,6 +117,8 @@ func isLeftRecursive(root *strct) (found bool) {
 		case *strct:
 			if root.typ == n.typ {
 				found = true
+			} else if direct {
+				return nil
 			}
 
 		case *sequence:
@@ -54,6 +135,21 @@ func isLeftRecursive(root *strct) (found bool) {
 	return
 }
 
+// Mark left-recursive productions to be parsed by growing a seed, with AllowLeftRecursion().
+func markLeftRecursion(n node) {
+	seen := map[node]bool{}
+	_ = visit(n, func(n node, next func() error) error {
+		if seen[n] {
+			return nil
+		}
+		seen[n] = true
+		if s, ok := n.(*strct); ok && isLeftRecursive(s, true) {
+			s.leftRecursive = true
+		}
+		return next()
+	})
+}
+
 func indent(s string) string {
 	return "  " + strings.Join(strings.Split(s, "\n"), "\n  ")
 }
@@ -38,3 +38,46 @@ func TestValidateLeftRecursionNested(t *testing.T) {
   LeftRecursionNested = <ident> | (LeftRecursionNestedInner "more") .
   LeftRecursionNestedInner = <ident> | LeftRecursionNested .`)
 }
+
+func TestValidateNullableRepetition(t *testing.T) {
+	type entry struct {
+		Key   string `@Ident?`
+		Value string `("=" @String)?`
+	}
+	type nullable struct {
+		Entries []*entry `@@*`
+	}
+	_, err := participle.Build[nullable]()
+	require.EqualError(t, err, `nullable: repetition of Entry can match empty input, so would never terminate`)
+
+	type nullableGroup struct {
+		Names []string `(@Ident | "x"?)+`
+	}
+	_, err = participle.Build[nullableGroup]()
+	require.EqualError(t, err, `nullableGroup: repetition of (<ident> | "x"?) can match empty input, so would never terminate`)
+
+	// A separator that consumes input guarantees progress, as does the ! modifier.
+	type separated struct {
+		Entries []*entry `@@*%","`
+	}
+	_, err = participle.Build[separated]()
+	require.NoError(t, err)
+
+	type nonEmpty struct {
+		Entries []*entry `(@@!)*`
+	}
+	_, err = participle.Build[nonEmpty]()
+	require.NoError(t, err)
+}
+
+func TestValidateAllowLeftRecursion(t *testing.T) {
+	_, err := participle.Build[leftRecursionSimple](participle.AllowLeftRecursion())
+	require.NoError(t, err)
+
+	// Only direct left recursion is supported.
+	_, err = participle.Build[leftRecursionNested](participle.AllowLeftRecursion())
+	require.EqualError(t, err, `left recursion detected on
+
+  LeftRecursionNested = <ident> | (LeftRecursionNestedInner "more") .
+  LeftRecursionNestedInner = <ident> | LeftRecursionNested .`)
+}
@@ -44,10 +44,28 @@ func visit(n node, visitor func(n node, next func() error) error) error {
 			return visit(n.node, visitor)
 		case *literal:
 			return nil
+		case *predicate:
+			return nil
+		case *productionRef:
+			return visit(n.node, visitor)
+		case *backreference:
+			return nil
+		case *literalSet:
+			return nil
+		case *balanced:
+			return nil
 		case *group:
-			return visit(n.expr, visitor)
+			if err := visit(n.expr, visitor); err != nil {
+				return err
+			}
+			if n.sep != nil {
+				return visit(n.sep, visitor)
+			}
+			return nil
 		case *lookaheadGroup:
 			return visit(n.expr, visitor)
+		case *lookbehindGroup:
+			return visit(n.expr, visitor)
 		default:
 			panic(fmt.Sprintf("%T", n))
 		}